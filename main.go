@@ -4,7 +4,7 @@ import (
 	"log"
 	"os"
 
-	"./service"
+	"github.com/docker-flow/docker-flow-swarm-listener/service"
 )
 
 func main() {
@@ -23,7 +23,12 @@ func main() {
 	swarmListener.NotifyServices(true)
 	swarmListener.NotifyNodes(true)
 
-	swarmListener.Run()
+	if err := swarmListener.Run(); err != nil {
+		l.Printf("Failed to start Docker Flow: Swarm Listener")
+		l.Printf("ERROR: %v", err)
+		return
+	}
+
 	serve := NewServe(swarmListener, l)
 	l.Fatal(Run(serve))
 }
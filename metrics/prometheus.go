@@ -23,8 +23,55 @@ var serviceGauge = prometheus.NewGaugeVec(
 	[]string{"service"},
 )
 
+var convergenceFailureCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Subsystem: "docker_flow",
+		Name:      "convergence_failure",
+		Help:      "Convergence failure counter",
+	},
+	[]string{"service"},
+)
+
+var eventStreamConnectedGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Subsystem: "docker_flow",
+		Name:      "event_stream_connected",
+		Help:      "Event stream connected gauge",
+	},
+	[]string{"service", "stream"},
+)
+
+var eventStreamReconnectCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Subsystem: "docker_flow",
+		Name:      "event_stream_reconnect",
+		Help:      "Event stream reconnect counter",
+	},
+	[]string{"service", "stream"},
+)
+
+var eventStreamLastEventGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Subsystem: "docker_flow",
+		Name:      "event_stream_last_event_timestamp_seconds",
+		Help:      "Time of the last event received from the event stream",
+	},
+	[]string{"service", "stream"},
+)
+
+var reconcileDriftCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Subsystem: "docker_flow",
+		Name:      "reconcile_drift",
+		Help:      "Reconciled drift counter",
+	},
+	[]string{"service", "type"},
+)
+
 func init() {
-	prometheus.MustRegister(errorCounter, serviceGauge)
+	prometheus.MustRegister(errorCounter, serviceGauge, convergenceFailureCounter,
+		eventStreamConnectedGauge, eventStreamReconnectCounter, eventStreamLastEventGauge,
+		reconcileDriftCounter)
 }
 
 // RecordError stores error information as Prometheus metric.
@@ -42,3 +89,52 @@ func RecordService(count int) {
 		"service": serviceName,
 	}).Set(float64(count))
 }
+
+// RecordConvergenceFailure stores the number of services that did not
+// converge as Prometheus metric.
+func RecordConvergenceFailure() {
+	convergenceFailureCounter.With(prometheus.Labels{
+		"service": serviceName,
+	}).Inc()
+}
+
+// RecordEventStreamConnected stores whether the docker event stream of
+// `stream` is connected as Prometheus metric.
+func RecordEventStreamConnected(stream string, connected bool) {
+	value := 0.0
+	if connected {
+		value = 1.0
+	}
+	eventStreamConnectedGauge.With(prometheus.Labels{
+		"service": serviceName,
+		"stream":  stream,
+	}).Set(value)
+}
+
+// RecordEventStreamReconnect stores reconnects of the docker event stream of
+// `stream` as Prometheus metric.
+func RecordEventStreamReconnect(stream string) {
+	eventStreamReconnectCounter.With(prometheus.Labels{
+		"service": serviceName,
+		"stream":  stream,
+	}).Inc()
+}
+
+// RecordEventStreamEvent stores the time of the last event received from
+// the docker event stream of `stream` as Prometheus metric.
+func RecordEventStreamEvent(stream string, timeNano int64) {
+	eventStreamLastEventGauge.With(prometheus.Labels{
+		"service": serviceName,
+		"stream":  stream,
+	}).Set(float64(timeNano) / 1e9)
+}
+
+// RecordReconcileDrift stores the number of services or nodes that drifted
+// from the cache and were repaired by reconciliation as Prometheus metric.
+// The `kind` argument is `service` or `node`.
+func RecordReconcileDrift(kind string, count int) {
+	reconcileDriftCounter.With(prometheus.Labels{
+		"service": serviceName,
+		"type":    kind,
+	}).Add(float64(count))
+}
@@ -4,9 +4,10 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strconv"
 
-	"./metrics"
-	"./service"
+	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
+	"github.com/docker-flow/docker-flow-swarm-listener/service"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -30,6 +31,8 @@ type server interface {
 	NotifyServices(w http.ResponseWriter, req *http.Request)
 	GetServices(w http.ResponseWriter, req *http.Request)
 	GetNodes(w http.ResponseWriter, req *http.Request)
+	GetPrometheusTargets(w http.ResponseWriter, req *http.Request)
+	GetAuditEntries(w http.ResponseWriter, req *http.Request)
 	PingHandler(w http.ResponseWriter, req *http.Request)
 }
 
@@ -53,6 +56,8 @@ func attachRoutes(s server) *http.ServeMux {
 	mux.HandleFunc("/v1/docker-flow-swarm-listener/notify-services", s.NotifyServices)
 	mux.HandleFunc("/v1/docker-flow-swarm-listener/get-nodes", s.GetNodes)
 	mux.HandleFunc("/v1/docker-flow-swarm-listener/get-services", s.GetServices)
+	mux.HandleFunc("/v1/docker-flow-swarm-listener/prometheus-targets", s.GetPrometheusTargets)
+	mux.HandleFunc("/v1/docker-flow-swarm-listener/audit", s.GetAuditEntries)
 	mux.HandleFunc("/v1/docker-flow-swarm-listener/ping", s.PingHandler)
 	mux.Handle("/metrics", prometheus.Handler())
 	return mux
@@ -109,6 +114,65 @@ func (m Serve) GetNodes(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// GetPrometheusTargets retrieves targets of services with the `com.df.scrapePort` label
+// in the Prometheus `http_sd_config` format
+func (m Serve) GetPrometheusTargets(w http.ResponseWriter, req *http.Request) {
+	targets := m.SwarmListener.GetPrometheusTargets()
+	bytes, err := json.Marshal(targets)
+	if err != nil {
+		m.Log.Printf("ERROR: Unable to prepare response: %s", err)
+		metrics.RecordError("serveGetPrometheusTargets")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	httpWriterSetContentType(w, "application/json")
+	w.Write(bytes)
+}
+
+// GetAuditEntries retrieves the last entries of the audit log
+// The `limit` query sets the number of entries (default 100). The `kind`,
+// `type`, `event`, `id`, and `status` queries filter entries
+func (m Serve) GetAuditEntries(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	limit := 100
+	if limitStr := query.Get("limit"); len(limitStr) > 0 {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		limit = l
+	}
+	filter := service.AuditFilter{
+		Kind:   query.Get("kind"),
+		Type:   query.Get("type"),
+		Event:  service.EventType(query.Get("event")),
+		ID:     query.Get("id"),
+		Status: query.Get("status"),
+	}
+
+	entries, err := m.SwarmListener.GetAuditEntries(limit, filter)
+	if err == service.ErrAuditLogDisabled {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		m.Log.Printf("ERROR: Unable to read audit log: %s", err)
+		metrics.RecordError("serveGetAuditEntries")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	bytes, err := json.Marshal(entries)
+	if err != nil {
+		m.Log.Printf("ERROR: Unable to prepare response: %s", err)
+		metrics.RecordError("serveGetAuditEntries")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	httpWriterSetContentType(w, "application/json")
+	w.Write(bytes)
+}
+
 // PingHandler is used for health checks
 func (m Serve) PingHandler(w http.ResponseWriter, req *http.Request) {
 	js, _ := json.Marshal(Response{Status: "OK"})
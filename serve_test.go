@@ -10,6 +10,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/docker-flow/docker-flow-swarm-listener/service"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
@@ -102,6 +103,32 @@ func (s *ServerTestSuite) Test_RestGetServices_RoutesTo_GetServices() {
 	sm.AssertExpectations(s.T())
 }
 
+func (s *ServerTestSuite) Test_RestGetPrometheusTargets_RoutesTo_GetPrometheusTargets() {
+
+	sm := new(serverMock)
+	sm.On("GetPrometheusTargets", mock.Anything, mock.Anything).Return(nil)
+	mux := attachRoutes(sm)
+
+	req := httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/prometheus-targets", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	sm.AssertExpectations(s.T())
+}
+
+func (s *ServerTestSuite) Test_RestAudit_RoutesTo_GetAuditEntries() {
+
+	sm := new(serverMock)
+	sm.On("GetAuditEntries", mock.Anything, mock.Anything).Return(nil)
+	mux := attachRoutes(sm)
+
+	req := httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/audit", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	sm.AssertExpectations(s.T())
+}
+
 func (s *ServerTestSuite) Test_RestPing_RoutesTo_GetPing() {
 
 	sm := new(serverMock)
@@ -194,6 +221,61 @@ func (s *ServerTestSuite) Test_GetNodes_ReturnNodes() {
 	s.Equal(mapParam, rsp)
 }
 
+// GetPrometheusTargets
+
+func (s *ServerTestSuite) Test_GetPrometheusTargets_ReturnsTargets() {
+	targets := []service.PrometheusTargetGroup{
+		{
+			Targets: []string{"10.0.0.1:8080"},
+			Labels: map[string]string{
+				"service": "demo",
+				"node":    "node1",
+				"node_id": "node1id",
+			},
+		},
+	}
+	s.SLMock.On("GetPrometheusTargets").Return(targets)
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-swarm-listener/prometheus-targets", nil)
+	srv := NewServe(s.SLMock, s.Log)
+	srv.GetPrometheusTargets(s.RWMock, req)
+
+	call := s.RWMock.GetLastMethodCall("Write")
+	value, _ := call.Arguments.Get(0).([]byte)
+	rsp := []service.PrometheusTargetGroup{}
+	json.Unmarshal(value, &rsp)
+	s.Equal(targets, rsp)
+	s.SLMock.AssertExpectations(s.T())
+}
+
+// GetAuditEntries
+
+func (s *ServerTestSuite) Test_GetAuditEntries_ReturnsFilteredEntries() {
+	entries := []service.AuditEntry{
+		{Kind: "notification", Type: "service", Event: service.EventTypeCreate, ID: "id1", Status: "error"},
+	}
+	filter := service.AuditFilter{Type: "service", Status: "error"}
+	s.SLMock.On("GetAuditEntries", 5, filter).Return(entries, nil)
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-swarm-listener/audit?limit=5&type=service&status=error", nil)
+	srv := NewServe(s.SLMock, s.Log)
+	srv.GetAuditEntries(s.RWMock, req)
+
+	call := s.RWMock.GetLastMethodCall("Write")
+	value, _ := call.Arguments.Get(0).([]byte)
+	rsp := []service.AuditEntry{}
+	json.Unmarshal(value, &rsp)
+	s.Equal(entries, rsp)
+	s.SLMock.AssertExpectations(s.T())
+}
+
+func (s *ServerTestSuite) Test_GetAuditEntries_AuditLogDisabled_ReturnsStatus404() {
+	s.SLMock.On("GetAuditEntries", 100, service.AuditFilter{}).Return(nil, service.ErrAuditLogDisabled)
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-swarm-listener/audit", nil)
+	srv := NewServe(s.SLMock, s.Log)
+	srv.GetAuditEntries(s.RWMock, req)
+
+	s.RWMock.AssertCalled(s.T(), "WriteHeader", 404)
+}
+
 // PingHandler
 
 func (s *ServerTestSuite) Test_PingHandler_ReturnsStatus200() {
@@ -247,8 +329,8 @@ type SwarmListeningMock struct {
 	mock.Mock
 }
 
-func (m *SwarmListeningMock) Run() {
-	m.Called()
+func (m *SwarmListeningMock) Run() error {
+	return m.Called().Error(0)
 }
 func (m *SwarmListeningMock) NotifyServices(ignoreCache bool) {
 	m.Called(ignoreCache)
@@ -265,6 +347,17 @@ func (m *SwarmListeningMock) GetNodesParameters(ctx context.Context) ([]map[stri
 	return args.Get(0).([]map[string]string), args.Error(1)
 }
 
+func (m *SwarmListeningMock) GetPrometheusTargets() []service.PrometheusTargetGroup {
+	args := m.Called()
+	return args.Get(0).([]service.PrometheusTargetGroup)
+}
+
+func (m *SwarmListeningMock) GetAuditEntries(limit int, filter service.AuditFilter) ([]service.AuditEntry, error) {
+	args := m.Called(limit, filter)
+	entries, _ := args.Get(0).([]service.AuditEntry)
+	return entries, args.Error(1)
+}
+
 type serverMock struct {
 	mock.Mock
 }
@@ -280,6 +373,14 @@ func (m *serverMock) GetServices(w http.ResponseWriter, req *http.Request) {
 func (m *serverMock) GetNodes(w http.ResponseWriter, req *http.Request) {
 	m.Called(w, req)
 }
+func (m *serverMock) GetPrometheusTargets(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
+func (m *serverMock) GetAuditEntries(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
 func (m *serverMock) PingHandler(w http.ResponseWriter, req *http.Request) {
 	m.Called(w, req)
 }
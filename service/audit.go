@@ -0,0 +1,321 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrAuditLogDisabled is returned when the audit log is not configured
+var ErrAuditLogDisabled = errors.New("Audit log is not enabled")
+
+const (
+	// AuditKindEvent is the kind of entries for observed changes
+	AuditKindEvent = "event"
+	// AuditKindNotification is the kind of entries for sent notifications
+	AuditKindNotification = "notification"
+
+	// AuditStatusSuccess is the status of a successful notification
+	AuditStatusSuccess = "success"
+	// AuditStatusError is the status of a failed notification
+	AuditStatusError = "error"
+	// AuditStatusCanceled is the status of a canceled notification
+	AuditStatusCanceled = "canceled"
+)
+
+// AuditEntry is a line of the audit log
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	Kind       string    `json:"kind"`
+	Type       string    `json:"type"`
+	Event      EventType `json:"event"`
+	ID         string    `json:"id"`
+	Params     string    `json:"params"`
+	Endpoint   string    `json:"endpoint,omitempty"`
+	Attempts   int       `json:"attempts,omitempty"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Status     string    `json:"status,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// AuditFilter selects audit entries. Empty fields match all entries
+type AuditFilter struct {
+	Kind   string
+	Type   string
+	Event  EventType
+	ID     string
+	Status string
+}
+
+// Match returns true when `entry` matches the filter
+func (f AuditFilter) Match(entry AuditEntry) bool {
+	return (len(f.Kind) == 0 || f.Kind == entry.Kind) &&
+		(len(f.Type) == 0 || f.Type == entry.Type) &&
+		(len(f.Event) == 0 || f.Event == entry.Event) &&
+		(len(f.ID) == 0 || f.ID == entry.ID) &&
+		(len(f.Status) == 0 || f.Status == entry.Status)
+}
+
+// AuditLogging records and reads audit entries
+type AuditLogging interface {
+	Record(entry AuditEntry) error
+	Tail(limit int, filter AuditFilter) ([]AuditEntry, error)
+}
+
+// FileAuditLog is an append-only JSON lines audit log
+// The file is rotated when it grows beyond `MaxSize` bytes or becomes older
+// than `MaxAge`. The age of the file is measured from its first entry.
+// Rotated files are renamed with a timestamp suffix and are never removed
+type FileAuditLog struct {
+	Path    string
+	MaxSize int64
+	MaxAge  time.Duration
+	file    *os.File
+	size    int64
+	created time.Time
+	mux     sync.Mutex
+}
+
+// NewFileAuditLog creates a `FileAuditLog`
+func NewFileAuditLog(path string, maxSize int64, maxAge time.Duration) (*FileAuditLog, error) {
+	a := &FileAuditLog{
+		Path:    path,
+		MaxSize: maxSize,
+		MaxAge:  maxAge,
+	}
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// NewFileAuditLogFromEnv creates a `FileAuditLog` from environment variables
+// `DF_AUDIT_LOG`, `DF_AUDIT_LOG_MAX_SIZE`, and `DF_AUDIT_LOG_MAX_AGE`.
+// Returns nil when `DF_AUDIT_LOG` is not set
+func NewFileAuditLogFromEnv() (*FileAuditLog, error) {
+	path := os.Getenv("DF_AUDIT_LOG")
+	if len(path) == 0 {
+		return nil, nil
+	}
+	maxSizeMB := 100
+	if maxSizeStr := os.Getenv("DF_AUDIT_LOG_MAX_SIZE"); len(maxSizeStr) > 0 {
+		if i, err := strconv.Atoi(maxSizeStr); err == nil {
+			maxSizeMB = i
+		}
+	}
+	maxAgeHours := 24
+	if maxAgeStr := os.Getenv("DF_AUDIT_LOG_MAX_AGE"); len(maxAgeStr) > 0 {
+		if i, err := strconv.Atoi(maxAgeStr); err == nil {
+			maxAgeHours = i
+		}
+	}
+	return NewFileAuditLog(
+		path, int64(maxSizeMB)*1024*1024, time.Duration(maxAgeHours)*time.Hour)
+}
+
+func (a *FileAuditLog) open() error {
+	file, err := os.OpenFile(a.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	a.file = file
+	a.size = info.Size()
+	a.created = time.Now()
+	if a.size > 0 {
+		if created, ok := firstAuditEntryTime(a.Path); ok {
+			a.created = created
+		}
+	}
+	return nil
+}
+
+// firstAuditEntryTime returns the time of the first entry of the file at
+// `path`, which is when the file was created
+func firstAuditEntryTime(path string) (time.Time, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	if !scanner.Scan() {
+		return time.Time{}, false
+	}
+	var entry AuditEntry
+	if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Time.IsZero() {
+		return time.Time{}, false
+	}
+	return entry.Time, true
+}
+
+// rotate renames the current file and opens a new one
+func (a *FileAuditLog) rotate() error {
+	if err := a.file.Close(); err != nil {
+		return err
+	}
+	rotated := fmt.Sprintf("%s.%s", a.Path, time.Now().UTC().Format("20060102T150405.000000000"))
+	if err := os.Rename(a.Path, rotated); err != nil {
+		return err
+	}
+	return a.open()
+}
+
+// Record appends `entry` to the audit log
+func (a *FileAuditLog) Record(entry AuditEntry) error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if a.size > 0 &&
+		((a.MaxSize > 0 && a.size+int64(len(line)) > a.MaxSize) ||
+			(a.MaxAge > 0 && time.Since(a.created) > a.MaxAge)) {
+		if err := a.rotate(); err != nil {
+			return err
+		}
+	}
+
+	if a.size == 0 {
+		a.created = time.Now()
+	}
+	n, err := a.file.Write(line)
+	a.size += int64(n)
+	return err
+}
+
+// Tail returns the last `limit` entries of the current file that match
+// `filter`. All matching entries are returned when `limit` is not positive
+// The file is scanned with its own handle, so that entries are recorded
+// while it is scanned
+func (a *FileAuditLog) Tail(limit int, filter AuditFilter) ([]AuditEntry, error) {
+	// The lock keeps the file from being opened while it is rotated
+	a.mux.Lock()
+	file, err := os.Open(a.Path)
+	a.mux.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := []AuditEntry{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if !filter.Match(entry) {
+			continue
+		}
+		entries = append(entries, entry)
+		if limit > 0 && len(entries) > limit {
+			entries = entries[1:]
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// Close closes the audit log
+func (a *FileAuditLog) Close() error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	return a.file.Close()
+}
+
+// notificationAttempt is the outcome of sending a notification to an address
+type notificationAttempt struct {
+	Attempts   int
+	StatusCode int
+	Canceled   bool
+}
+
+// notificationAttempts collects the outcomes of sending a notification to
+// every address
+type notificationAttempts struct {
+	attempts map[string]notificationAttempt
+	mux      sync.Mutex
+}
+
+func newNotificationAttempts() *notificationAttempts {
+	return &notificationAttempts{attempts: map[string]notificationAttempt{}}
+}
+
+func (na *notificationAttempts) set(addr string, attempt notificationAttempt) {
+	na.mux.Lock()
+	defer na.mux.Unlock()
+	na.attempts[addr] = attempt
+}
+
+func (na *notificationAttempts) get(addr string) (notificationAttempt, bool) {
+	na.mux.Lock()
+	defer na.mux.Unlock()
+	attempt, ok := na.attempts[addr]
+	return attempt, ok
+}
+
+type notificationAttemptsContextKey struct{}
+
+// contextWithNotificationAttempts returns a copy of `ctx` that collects
+// the outcomes of notifications into `na`
+func contextWithNotificationAttempts(ctx context.Context, na *notificationAttempts) context.Context {
+	return context.WithValue(ctx, notificationAttemptsContextKey{}, na)
+}
+
+// recordNotificationAttempt records the outcome of sending a notification
+// to `addr` when `ctx` collects outcomes
+func recordNotificationAttempt(ctx context.Context, addr string, attempt notificationAttempt) {
+	if na, ok := ctx.Value(notificationAttemptsContextKey{}).(*notificationAttempts); ok {
+		na.set(addr, attempt)
+	}
+}
+
+// newNotificationAuditEntry creates the audit entry of sending `n` to `addr`
+func newNotificationAuditEntry(ctx context.Context, notifyType string, n Notification, addr string, err error) AuditEntry {
+	entry := AuditEntry{
+		Kind:     AuditKindNotification,
+		Type:     notifyType,
+		Event:    n.EventType,
+		ID:       n.ID,
+		Params:   n.Parameters,
+		Endpoint: addr,
+		Attempts: 1,
+		Status:   AuditStatusSuccess,
+	}
+	if na, ok := ctx.Value(notificationAttemptsContextKey{}).(*notificationAttempts); ok {
+		if attempt, ok := na.get(addr); ok {
+			entry.Attempts = attempt.Attempts
+			entry.StatusCode = attempt.StatusCode
+			if attempt.Canceled {
+				entry.Status = AuditStatusCanceled
+			}
+		}
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		entry.Status = AuditStatusError
+		if ctx.Err() != nil {
+			entry.Status = AuditStatusCanceled
+		}
+	}
+	return entry
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type AuditTestSuite struct {
+	suite.Suite
+	Dir  string
+	Path string
+}
+
+func TestAuditUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(AuditTestSuite))
+}
+
+func (s *AuditTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "dfsl-audit")
+	s.Require().NoError(err)
+	s.Dir = dir
+	s.Path = filepath.Join(dir, "audit.jsonl")
+}
+
+func (s *AuditTestSuite) TearDownTest() {
+	os.RemoveAll(s.Dir)
+}
+
+func (s *AuditTestSuite) Test_Record_AppendsJSONLines() {
+	a, err := NewFileAuditLog(s.Path, 0, 0)
+	s.Require().NoError(err)
+	defer a.Close()
+
+	s.Require().NoError(a.Record(AuditEntry{Kind: AuditKindEvent, Type: "service", ID: "id1"}))
+	s.Require().NoError(a.Record(AuditEntry{Kind: AuditKindNotification, Type: "service", ID: "id1"}))
+
+	content, err := ioutil.ReadFile(s.Path)
+	s.Require().NoError(err)
+	s.Contains(string(content), `"kind":"event"`)
+	s.Contains(string(content), `"kind":"notification"`)
+
+	entries, err := a.Tail(0, AuditFilter{})
+	s.Require().NoError(err)
+	s.Require().Len(entries, 2)
+	s.False(entries[0].Time.IsZero())
+}
+
+func (s *AuditTestSuite) Test_Record_ExistingFile_Appends() {
+	a, err := NewFileAuditLog(s.Path, 0, 0)
+	s.Require().NoError(err)
+	s.Require().NoError(a.Record(AuditEntry{ID: "id1"}))
+	a.Close()
+
+	a, err = NewFileAuditLog(s.Path, 0, 0)
+	s.Require().NoError(err)
+	defer a.Close()
+	s.Require().NoError(a.Record(AuditEntry{ID: "id2"}))
+
+	entries, err := a.Tail(0, AuditFilter{})
+	s.Require().NoError(err)
+	s.Len(entries, 2)
+}
+
+func (s *AuditTestSuite) Test_Record_RotatesWhenMaxSizeIsReached() {
+	a, err := NewFileAuditLog(s.Path, 150, 0)
+	s.Require().NoError(err)
+	defer a.Close()
+
+	s.Require().NoError(a.Record(AuditEntry{ID: "id1"}))
+	s.Require().NoError(a.Record(AuditEntry{ID: "id2"}))
+
+	files, err := filepath.Glob(s.Path + "*")
+	s.Require().NoError(err)
+	s.Len(files, 2)
+
+	entries, err := a.Tail(0, AuditFilter{})
+	s.Require().NoError(err)
+	s.Require().Len(entries, 1)
+	s.Equal("id2", entries[0].ID)
+}
+
+func (s *AuditTestSuite) Test_Record_RotatesWhenMaxAgeIsReached() {
+	a, err := NewFileAuditLog(s.Path, 0, time.Hour)
+	s.Require().NoError(err)
+	defer a.Close()
+
+	s.Require().NoError(a.Record(AuditEntry{ID: "id1"}))
+	a.created = time.Now().Add(-2 * time.Hour)
+	s.Require().NoError(a.Record(AuditEntry{ID: "id2"}))
+
+	files, err := filepath.Glob(s.Path + "*")
+	s.Require().NoError(err)
+	s.Len(files, 2)
+}
+
+func (s *AuditTestSuite) Test_Record_RotatesWhenFirstEntryOfReopenedFileIsOlderThanMaxAge() {
+	a, err := NewFileAuditLog(s.Path, 0, 0)
+	s.Require().NoError(err)
+	s.Require().NoError(a.Record(AuditEntry{ID: "id1", Time: time.Now().Add(-2 * time.Hour)}))
+	a.Close()
+
+	a, err = NewFileAuditLog(s.Path, 0, time.Hour)
+	s.Require().NoError(err)
+	defer a.Close()
+	s.Require().NoError(a.Record(AuditEntry{ID: "id2"}))
+
+	files, err := filepath.Glob(s.Path + "*")
+	s.Require().NoError(err)
+	s.Len(files, 2)
+}
+
+func (s *AuditTestSuite) Test_Tail_DoesNotBlockRecord() {
+	a, err := NewFileAuditLog(s.Path, 0, 0)
+	s.Require().NoError(err)
+	defer a.Close()
+	for i := 0; i < 100; i++ {
+		s.Require().NoError(a.Record(AuditEntry{ID: "id1"}))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			a.Record(AuditEntry{ID: "id2"})
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		entries, err := a.Tail(0, AuditFilter{ID: "id1"})
+		s.Require().NoError(err)
+		s.Len(entries, 100)
+	}
+	<-done
+
+	entries, err := a.Tail(0, AuditFilter{ID: "id2"})
+	s.Require().NoError(err)
+	s.Len(entries, 100)
+}
+
+func (s *AuditTestSuite) Test_Tail_FiltersAndLimits() {
+	a, err := NewFileAuditLog(s.Path, 0, 0)
+	s.Require().NoError(err)
+	defer a.Close()
+
+	a.Record(AuditEntry{Kind: AuditKindNotification, Type: "service", ID: "id1", Status: AuditStatusError})
+	a.Record(AuditEntry{Kind: AuditKindNotification, Type: "node", ID: "id2", Status: AuditStatusError})
+	a.Record(AuditEntry{Kind: AuditKindNotification, Type: "service", ID: "id3", Status: AuditStatusSuccess})
+	a.Record(AuditEntry{Kind: AuditKindNotification, Type: "service", ID: "id4", Status: AuditStatusError})
+	a.Record(AuditEntry{Kind: AuditKindNotification, Type: "service", ID: "id5", Status: AuditStatusError})
+
+	entries, err := a.Tail(2, AuditFilter{Type: "service", Status: AuditStatusError})
+	s.Require().NoError(err)
+	s.Require().Len(entries, 2)
+	s.Equal("id4", entries[0].ID)
+	s.Equal("id5", entries[1].ID)
+}
+
+func (s *AuditTestSuite) Test_NewNotificationAuditEntry_UsesNotifierAttempts() {
+	attempt := 0
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempt++
+		if attempt < 2 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer httpSrv.Close()
+
+	n := Notification{EventType: EventTypeCreate, ID: "id1", Parameters: "a=b"}
+	ctx := contextWithNotificationAttempts(context.Background(), newNotificationAttempts())
+	notifier := NewNotifier(httpSrv.URL, "", "service", 2, 1, log.New(ioutil.Discard, "", 0))
+	err := notifier.Create(ctx, n.Parameters)
+	s.Require().NoError(err)
+
+	entry := newNotificationAuditEntry(ctx, "service", n, notifier.GetCreateAddr(), err)
+	s.Equal(AuditEntry{
+		Kind:       AuditKindNotification,
+		Type:       "service",
+		Event:      EventTypeCreate,
+		ID:         "id1",
+		Params:     "a=b",
+		Endpoint:   httpSrv.URL,
+		Attempts:   2,
+		StatusCode: 200,
+		Status:     AuditStatusSuccess,
+	}, entry)
+}
+
+func (s *AuditTestSuite) Test_NewNotificationAuditEntry_Error() {
+	n := Notification{EventType: EventTypeRemove, ID: "id1"}
+
+	entry := newNotificationAuditEntry(context.Background(), "node", n, "addr", errors.New("failed"))
+
+	s.Equal(AuditStatusError, entry.Status)
+	s.Equal("failed", entry.Error)
+	s.Equal(1, entry.Attempts)
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	cloudEventsSpecVersion = "1.0"
+	cloudEventsTypePrefix  = "com.dockerflow.swarm"
+	cloudEventsSource      = "docker-flow-swarm-listener"
+)
+
+// CloudEvent is a CloudEvents 1.0 event
+type CloudEvent struct {
+	SpecVersion     string            `json:"specversion"`
+	Type            string            `json:"type"`
+	Source          string            `json:"source"`
+	ID              string            `json:"id"`
+	Time            string            `json:"time,omitempty"`
+	Subject         string            `json:"subject,omitempty"`
+	DataContentType string            `json:"datacontenttype"`
+	Data            map[string]string `json:"data"`
+}
+
+// NewCloudEvent creates a `CloudEvent` for a notification
+// The type is `com.dockerflow.swarm.<notifyType>.created` or
+// `com.dockerflow.swarm.<notifyType>.removed` and the id is derived from
+// the ID and TimeNano of the notification. Task and update notifications
+// use their event type without the `<notifyType>-` prefix as the action,
+// such as `started` or `rollback-completed`
+func NewCloudEvent(notifyType string, eventType EventType, source string, n Notification, params string) (CloudEvent, error) {
+	values, err := url.ParseQuery(params)
+	if err != nil {
+		return CloudEvent{}, err
+	}
+	data := map[string]string{}
+	for k := range values {
+		data[k] = values.Get(k)
+	}
+
+	action := "created"
+	if eventType == EventTypeRemove {
+		action = "removed"
+	}
+	// Task and update notifications are sent as create requests, the
+	// action is taken from the notification instead
+	if prefix := notifyType + "-"; strings.HasPrefix(string(n.EventType), prefix) {
+		action = strings.TrimPrefix(string(n.EventType), prefix)
+	}
+	if len(source) == 0 {
+		source = cloudEventsSource
+	}
+
+	timeNano := n.TimeNano
+	if timeNano == 0 {
+		timeNano = time.Now().UTC().UnixNano()
+	}
+
+	return CloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		Type:            fmt.Sprintf("%s.%s.%s", cloudEventsTypePrefix, notifyType, action),
+		Source:          source,
+		ID:              fmt.Sprintf("%s-%d", n.ID, timeNano),
+		Time:            time.Unix(0, timeNano).UTC().Format(time.RFC3339Nano),
+		Subject:         n.ID,
+		DataContentType: "application/json",
+		Data:            data,
+	}, nil
+}
+
+// SetHeaders sets the binary mode headers of the event
+func (e CloudEvent) SetHeaders(header http.Header) {
+	header.Set("Content-Type", e.DataContentType)
+	header.Set("ce-specversion", e.SpecVersion)
+	header.Set("ce-type", e.Type)
+	header.Set("ce-source", e.Source)
+	header.Set("ce-id", e.ID)
+	if len(e.Time) > 0 {
+		header.Set("ce-time", e.Time)
+	}
+	if len(e.Subject) > 0 {
+		header.Set("ce-subject", e.Subject)
+	}
+}
@@ -0,0 +1,327 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
+)
+
+const (
+	consulManagedByMeta = "managed-by"
+	consulManagedBy     = "docker-flow-swarm-listener"
+	consulServiceIDMeta = "swarm-service-id"
+)
+
+// consulRegistration is the body of the Consul agent service register API
+type consulRegistration struct {
+	ID      string            `json:"ID"`
+	Name    string            `json:"Name"`
+	Tags    []string          `json:"Tags"`
+	Address string            `json:"Address"`
+	Port    int               `json:"Port"`
+	Meta    map[string]string `json:"Meta"`
+}
+
+// consulAgentService is a service returned by the Consul agent services API
+type consulAgentService struct {
+	ID      string            `json:"ID"`
+	Service string            `json:"Service"`
+	Tags    []string          `json:"Tags"`
+	Address string            `json:"Address"`
+	Port    int               `json:"Port"`
+	Meta    map[string]string `json:"Meta"`
+}
+
+func (s consulAgentService) registration() consulRegistration {
+	return consulRegistration{
+		ID:      s.ID,
+		Name:    s.Service,
+		Tags:    s.Tags,
+		Address: s.Address,
+		Port:    s.Port,
+		Meta:    s.Meta,
+	}
+}
+
+// ConsulRegistrar registers cached services with a Consul agent
+// Every task address of a service is registered as a Consul service
+// instance. The port is taken from the `com.df.port` label and `com.df.*`
+// labels are added as `key=value` tags. It implements `NotificationSender`
+// so that it can be placed on a `NotifyEndpoint`
+type ConsulRegistrar struct {
+	Addr     string
+	Token    string
+	Interval time.Duration
+	ssCache  SwarmServiceCacher
+	client   *http.Client
+	mux      sync.Mutex
+	log      *log.Logger
+}
+
+// NewConsulRegistrar creates a `ConsulRegistrar`
+func NewConsulRegistrar(addr, token string, interval time.Duration, ssCache SwarmServiceCacher, logger *log.Logger) *ConsulRegistrar {
+	return &ConsulRegistrar{
+		Addr:     strings.TrimSuffix(addr, "/"),
+		Token:    token,
+		Interval: interval,
+		ssCache:  ssCache,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		log:      logger,
+	}
+}
+
+// consulConfigFromEnv returns the address, token, and sync interval of
+// Consul. The interval defaults to one minute
+func consulConfigFromEnv() (addr, token string, interval time.Duration) {
+	seconds := 60
+	if intervalStr := os.Getenv("DF_CONSUL_SYNC_INTERVAL"); len(intervalStr) > 0 {
+		if i, err := strconv.Atoi(intervalStr); err == nil {
+			seconds = i
+		}
+	}
+	return os.Getenv("DF_CONSUL_ADDR"), os.Getenv("DF_CONSUL_TOKEN"),
+		time.Duration(seconds) * time.Second
+}
+
+// Run starts the anti-entropy loop, which synchronizes Consul with the
+// cache every `Interval`
+func (r *ConsulRegistrar) Run() {
+	if r.Interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(r.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := r.Sync(context.Background()); err != nil {
+				r.log.Printf("ERROR: Unable to sync services with consul: %v", err)
+				metrics.RecordError("consulSync")
+			}
+		}
+	}()
+}
+
+// Create registers the notified service
+func (r *ConsulRegistrar) Create(ctx context.Context, params string) error {
+	n, ok := notificationFromContext(ctx)
+	if !ok {
+		return r.Sync(ctx)
+	}
+	return r.syncService(ctx, n.ID)
+}
+
+// Remove deregisters the notified service
+func (r *ConsulRegistrar) Remove(ctx context.Context, params string) error {
+	n, ok := notificationFromContext(ctx)
+	if !ok {
+		return r.Sync(ctx)
+	}
+	return r.syncService(ctx, n.ID)
+}
+
+// GetCreateAddr returns the address of the Consul agent
+func (r *ConsulRegistrar) GetCreateAddr() string {
+	return r.Addr
+}
+
+// GetRemoveAddr returns the address of the Consul agent
+func (r *ConsulRegistrar) GetRemoveAddr() string {
+	return r.Addr
+}
+
+// Sync registers all cached services and deregisters services that were
+// registered by the listener but are no longer in the cache
+func (r *ConsulRegistrar) Sync(ctx context.Context) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	current, err := r.getRegisteredServices(ctx)
+	if err != nil {
+		return err
+	}
+	desired := map[string]consulRegistration{}
+	for _, ssm := range r.ssCache.GetAll() {
+		for _, reg := range getConsulRegistrations(ssm) {
+			desired[reg.ID] = reg
+		}
+	}
+	return r.apply(ctx, desired, current)
+}
+
+// syncService synchronizes the registrations of the service with `serviceID`
+func (r *ConsulRegistrar) syncService(ctx context.Context, serviceID string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	registered, err := r.getRegisteredServices(ctx)
+	if err != nil {
+		return err
+	}
+	current := map[string]consulAgentService{}
+	for id, s := range registered {
+		if s.Meta[consulServiceIDMeta] == serviceID {
+			current[id] = s
+		}
+	}
+	desired := map[string]consulRegistration{}
+	if ssm, ok := r.ssCache.Get(serviceID); ok {
+		for _, reg := range getConsulRegistrations(ssm) {
+			desired[reg.ID] = reg
+		}
+	}
+	return r.apply(ctx, desired, current)
+}
+
+// apply registers `desired` registrations that differ from `current` and
+// deregisters `current` services that are not desired
+func (r *ConsulRegistrar) apply(ctx context.Context, desired map[string]consulRegistration, current map[string]consulAgentService) error {
+	var lastErr error
+	for id, reg := range desired {
+		if s, ok := current[id]; ok && consulRegistrationEqual(reg, s.registration()) {
+			continue
+		}
+		r.log.Printf("Registering %s (%s) with consul", reg.Name, reg.ID)
+		if err := r.register(ctx, reg); err != nil {
+			r.log.Printf("ERROR: Unable to register %s with consul: %v", reg.ID, err)
+			metrics.RecordError("consulRegister")
+			lastErr = err
+		}
+	}
+	for id, s := range current {
+		if _, ok := desired[id]; ok {
+			continue
+		}
+		r.log.Printf("Deregistering %s (%s) from consul", s.Service, id)
+		if err := r.deregister(ctx, id); err != nil {
+			r.log.Printf("ERROR: Unable to deregister %s from consul: %v", id, err)
+			metrics.RecordError("consulDeregister")
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// getRegisteredServices returns the services registered by the listener
+func (r *ConsulRegistrar) getRegisteredServices(ctx context.Context) (map[string]consulAgentService, error) {
+	body, err := r.do(ctx, "GET", "/v1/agent/services", nil)
+	if err != nil {
+		return nil, err
+	}
+	services := map[string]consulAgentService{}
+	if err := json.Unmarshal(body, &services); err != nil {
+		return nil, err
+	}
+	for id, s := range services {
+		if s.Meta[consulManagedByMeta] != consulManagedBy {
+			delete(services, id)
+		}
+	}
+	return services, nil
+}
+
+func (r *ConsulRegistrar) register(ctx context.Context, reg consulRegistration) error {
+	b, err := json.Marshal(reg)
+	if err != nil {
+		return err
+	}
+	_, err = r.do(ctx, "PUT", "/v1/agent/service/register", b)
+	return err
+}
+
+func (r *ConsulRegistrar) deregister(ctx context.Context, id string) error {
+	_, err := r.do(ctx, "PUT", "/v1/agent/service/deregister/"+id, nil)
+	return err
+}
+
+func (r *ConsulRegistrar) do(ctx context.Context, method, path string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, r.Addr+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if len(body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if len(r.Token) > 0 {
+		req.Header.Set("X-Consul-Token", r.Token)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Consul %s %s returned status code %d: %s",
+			method, path, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return respBody, nil
+}
+
+// getConsulRegistrations returns the Consul registrations of a service
+// There is one registration per task address. When the service does not
+// have node info, the service name is registered as the address
+func getConsulRegistrations(ssm SwarmServiceMini) []consulRegistration {
+	serviceName := GetSwarmServiceMiniCreateParameters(ssm)["serviceName"]
+	port, _ := strconv.Atoi(ssm.Labels["com.df.port"])
+
+	tags := []string{}
+	for k, v := range ssm.Labels {
+		if key, ok := labelParamKey(k); ok {
+			tags = append(tags, fmt.Sprintf("%s=%s", key, v))
+		}
+	}
+	sort.Strings(tags)
+
+	newRegistration := func(id, addr string) consulRegistration {
+		return consulRegistration{
+			ID:      id,
+			Name:    serviceName,
+			Tags:    tags,
+			Address: addr,
+			Port:    port,
+			Meta: map[string]string{
+				consulManagedByMeta: consulManagedBy,
+				consulServiceIDMeta: ssm.ID,
+			},
+		}
+	}
+
+	if ssm.NodeInfo.Cardinality() == 0 {
+		return []consulRegistration{newRegistration(ssm.ID, serviceName)}
+	}
+
+	regs := []consulRegistration{}
+	for nodeIP := range ssm.NodeInfo {
+		regs = append(regs, newRegistration(
+			fmt.Sprintf("%s-%s", ssm.ID, nodeIP.Addr), nodeIP.Addr))
+	}
+	sort.Slice(regs, func(i, j int) bool { return regs[i].ID < regs[j].ID })
+	return regs
+}
+
+func consulRegistrationEqual(a, b consulRegistration) bool {
+	tagsA := append([]string{}, a.Tags...)
+	tagsB := append([]string{}, b.Tags...)
+	sort.Strings(tagsA)
+	sort.Strings(tagsB)
+	return a.ID == b.ID && a.Name == b.Name && a.Address == b.Address &&
+		a.Port == b.Port && reflect.DeepEqual(tagsA, tagsB) &&
+		reflect.DeepEqual(a.Meta, b.Meta)
+}
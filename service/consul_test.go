@@ -0,0 +1,213 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeConsul implements the agent service endpoints of the Consul HTTP API
+type fakeConsul struct {
+	services map[string]consulAgentService
+	requests []string
+	mux      sync.Mutex
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/v1/agent/services":
+		json.NewEncoder(w).Encode(f.services)
+	case r.Method == "PUT" && r.URL.Path == "/v1/agent/service/register":
+		var reg consulRegistration
+		if err := json.NewDecoder(r.Body).Decode(&reg); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.services[reg.ID] = consulAgentService{
+			ID: reg.ID, Service: reg.Name, Tags: reg.Tags,
+			Address: reg.Address, Port: reg.Port, Meta: reg.Meta,
+		}
+	case r.Method == "PUT" && strings.HasPrefix(r.URL.Path, "/v1/agent/service/deregister/"):
+		delete(f.services, strings.TrimPrefix(r.URL.Path, "/v1/agent/service/deregister/"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeConsul) countRequests(request string) int {
+	count := 0
+	for _, r := range f.requests {
+		if r == request {
+			count++
+		}
+	}
+	return count
+}
+
+type ConsulRegistrarTestSuite struct {
+	suite.Suite
+	Consul    *fakeConsul
+	Server    *httptest.Server
+	SSCache   *SwarmServiceCache
+	Registrar *ConsulRegistrar
+	Logger    *log.Logger
+	LogBytes  *bytes.Buffer
+}
+
+func TestConsulRegistrarUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ConsulRegistrarTestSuite))
+}
+
+func (s *ConsulRegistrarTestSuite) SetupTest() {
+	s.Consul = &fakeConsul{services: map[string]consulAgentService{}}
+	s.Server = httptest.NewServer(s.Consul)
+	s.SSCache = NewSwarmServiceCache()
+	s.LogBytes = new(bytes.Buffer)
+	s.Logger = log.New(s.LogBytes, "", 0)
+	s.Registrar = NewConsulRegistrar(s.Server.URL, "", time.Minute, s.SSCache, s.Logger)
+}
+
+func (s *ConsulRegistrarTestSuite) TearDownTest() {
+	s.Server.Close()
+}
+
+func (s *ConsulRegistrarTestSuite) Test_GetConsulRegistrations_OnePerTaskAddress() {
+	ssm := getNewSwarmServiceMini()
+	ssm.Labels["com.df.port"] = "8080"
+	ssm.NodeInfo.Add("node-2", "1.0.0.2", "id2")
+
+	regs := getConsulRegistrations(ssm)
+
+	s.Require().Len(regs, 2)
+	s.Equal(consulRegistration{
+		ID:      "serviceID-1.0.0.1",
+		Name:    "demo-go",
+		Tags:    []string{"hello=nyc", "port=8080"},
+		Address: "1.0.0.1",
+		Port:    8080,
+		Meta: map[string]string{
+			"managed-by":       "docker-flow-swarm-listener",
+			"swarm-service-id": "serviceID",
+		},
+	}, regs[0])
+	s.Equal("serviceID-1.0.0.2", regs[1].ID)
+	s.Equal("1.0.0.2", regs[1].Address)
+}
+
+func (s *ConsulRegistrarTestSuite) Test_GetConsulRegistrations_SkipsReservedParamLabels() {
+	ssm := getNewSwarmServiceMini()
+	ssm.Labels["com.df.swarmListener.event"] = "service-scaled"
+	ssm.Labels["com.df."] = "empty"
+	ssm.Labels["other.label"] = "value"
+
+	regs := getConsulRegistrations(ssm)
+
+	s.Require().Len(regs, 1)
+	s.Equal([]string{"hello=nyc"}, regs[0].Tags)
+}
+
+func (s *ConsulRegistrarTestSuite) Test_GetConsulRegistrations_NoNodeInfo_RegistersServiceName() {
+	ssm := getNewSwarmServiceMini()
+	ssm.NodeInfo = nil
+
+	regs := getConsulRegistrations(ssm)
+
+	s.Require().Len(regs, 1)
+	s.Equal("serviceID", regs[0].ID)
+	s.Equal("demo-go", regs[0].Address)
+	s.Equal(0, regs[0].Port)
+}
+
+func (s *ConsulRegistrarTestSuite) Test_Create_RegistersNotifiedService() {
+	s.SSCache.InsertAndCheck(getNewSwarmServiceMini())
+	other := getNewSwarmServiceMini()
+	other.ID = "otherID"
+	s.SSCache.InsertAndCheck(other)
+
+	ctx := contextWithNotification(context.Background(), Notification{ID: "serviceID"})
+	err := s.Registrar.Create(ctx, "")
+	s.Require().NoError(err)
+
+	s.Len(s.Consul.services, 1)
+	s.Contains(s.Consul.services, "serviceID-1.0.0.1")
+}
+
+func (s *ConsulRegistrarTestSuite) Test_Create_UnchangedService_DoesNotRegisterAgain() {
+	s.SSCache.InsertAndCheck(getNewSwarmServiceMini())
+	ctx := contextWithNotification(context.Background(), Notification{ID: "serviceID"})
+
+	s.Require().NoError(s.Registrar.Create(ctx, ""))
+	s.Require().NoError(s.Registrar.Create(ctx, ""))
+
+	s.Equal(1, s.Consul.countRequests("PUT /v1/agent/service/register"))
+}
+
+func (s *ConsulRegistrarTestSuite) Test_Create_RemovedTaskAddress_Deregisters() {
+	ssm := getNewSwarmServiceMini()
+	ssm.NodeInfo.Add("node-2", "1.0.0.2", "id2")
+	s.SSCache.InsertAndCheck(ssm)
+	ctx := contextWithNotification(context.Background(), Notification{ID: "serviceID"})
+	s.Require().NoError(s.Registrar.Create(ctx, ""))
+	s.Require().Len(s.Consul.services, 2)
+
+	s.SSCache.InsertAndCheck(getNewSwarmServiceMini())
+	s.Require().NoError(s.Registrar.Create(ctx, ""))
+
+	s.Len(s.Consul.services, 1)
+	s.Contains(s.Consul.services, "serviceID-1.0.0.1")
+}
+
+func (s *ConsulRegistrarTestSuite) Test_Remove_DeregistersService() {
+	s.SSCache.InsertAndCheck(getNewSwarmServiceMini())
+	ctx := contextWithNotification(context.Background(), Notification{ID: "serviceID"})
+	s.Require().NoError(s.Registrar.Create(ctx, ""))
+	s.Require().Len(s.Consul.services, 1)
+
+	s.SSCache.Delete("serviceID")
+	err := s.Registrar.Remove(ctx, "")
+	s.Require().NoError(err)
+
+	s.Empty(s.Consul.services)
+	s.Contains(s.LogBytes.String(), "Deregistering demo-go (serviceID-1.0.0.1) from consul")
+}
+
+func (s *ConsulRegistrarTestSuite) Test_Sync_FixesDrift() {
+	s.SSCache.InsertAndCheck(getNewSwarmServiceMini())
+	s.Consul.services["stale"] = consulAgentService{
+		ID: "stale", Service: "old",
+		Meta: map[string]string{"managed-by": "docker-flow-swarm-listener"},
+	}
+	s.Consul.services["external"] = consulAgentService{ID: "external", Service: "db"}
+	s.Consul.services["serviceID-1.0.0.1"] = consulAgentService{
+		ID: "serviceID-1.0.0.1", Service: "demo-go", Address: "1.0.0.1", Port: 1234,
+		Meta: map[string]string{"managed-by": "docker-flow-swarm-listener"},
+	}
+
+	err := s.Registrar.Sync(context.Background())
+	s.Require().NoError(err)
+
+	s.Len(s.Consul.services, 2)
+	s.Contains(s.Consul.services, "external")
+	s.Equal(0, s.Consul.services["serviceID-1.0.0.1"].Port)
+	s.Equal("serviceID", s.Consul.services["serviceID-1.0.0.1"].Meta["swarm-service-id"])
+}
+
+func (s *ConsulRegistrarTestSuite) Test_Sync_ConsulError_ReturnsError() {
+	s.Server.Close()
+
+	err := s.Registrar.Sync(context.Background())
+	s.Error(err)
+}
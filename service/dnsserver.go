@@ -0,0 +1,331 @@
+package service
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	maxUDPMessageSize = 512
+	maxTCPMessageSize = 65535
+	dnsTCPTimeout     = 10 * time.Second
+)
+
+// DNSServer answers DNS queries for services and nodes from the caches
+// The following names are resolved in `Domain`:
+// `<service>.service.` A records with the task addresses of the service
+// `<service>.service.` SRV records with the `com.df.port` of the service
+// `<a-b-c-d>.addr.` A record for the task address `a.b.c.d` of a cached service
+// `<hostname>.node.` A record with the address of the node
+// Queries are answered over UDP and TCP. Clients retry truncated UDP
+// answers over TCP
+type DNSServer struct {
+	Addr      string
+	Domain    string
+	TTL       uint32
+	ssCache   SwarmServiceCacher
+	nodeCache NodeCacher
+	log       *log.Logger
+}
+
+// NewDNSServer creates a `DNSServer`
+func NewDNSServer(addr, domain string, ssCache SwarmServiceCacher, nodeCache NodeCacher, logger *log.Logger) *DNSServer {
+	return &DNSServer{
+		Addr:      addr,
+		Domain:    strings.ToLower(strings.Trim(domain, ".")) + ".",
+		TTL:       5,
+		ssCache:   ssCache,
+		nodeCache: nodeCache,
+		log:       logger,
+	}
+}
+
+// Run answers DNS queries over UDP and TCP on `Addr`
+// An error is returned when either of them can not listen on `Addr`
+func (s *DNSServer) Run() error {
+	conn, err := net.ListenPacket("udp", s.Addr)
+	if err != nil {
+		return err
+	}
+	lis, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	go func() {
+		if err := s.Serve(conn); err != nil {
+			s.log.Printf("ERROR: DNS server stopped: %v", err)
+			metrics.RecordError("dnsServe")
+		}
+	}()
+	go func() {
+		if err := s.ServeTCP(lis); err != nil {
+			s.log.Printf("ERROR: DNS server stopped: %v", err)
+			metrics.RecordError("dnsServe")
+		}
+	}()
+	return nil
+}
+
+// Serve answers DNS queries received on `conn`
+// Answers that do not fit into a UDP message are truncated
+func (s *DNSServer) Serve(conn net.PacketConn) error {
+	s.log.Printf("Serving DNS for %s on %s", s.Domain, conn.LocalAddr())
+	buf := make([]byte, maxUDPMessageSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		resp, err := s.handleQuery(buf[:n], maxUDPMessageSize)
+		if err != nil {
+			metrics.RecordError("dnsHandleQuery")
+			continue
+		}
+		if _, err := conn.WriteTo(resp, addr); err != nil {
+			s.log.Printf("ERROR: Unable to write DNS response to %s: %v", addr, err)
+			metrics.RecordError("dnsWriteResponse")
+		}
+	}
+}
+
+// ServeTCP answers DNS queries received on connections accepted by `lis`
+func (s *DNSServer) ServeTCP(lis net.Listener) error {
+	s.log.Printf("Serving DNS for %s on %s/tcp", s.Domain, lis.Addr())
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serveTCPConn(conn)
+	}
+}
+
+// serveTCPConn answers the length prefixed queries of `conn` until it is
+// closed or idle for `dnsTCPTimeout`
+func (s *DNSServer) serveTCPConn(conn net.Conn) {
+	defer conn.Close()
+	for {
+		conn.SetDeadline(time.Now().Add(dnsTCPTimeout))
+		var length uint16
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+			return
+		}
+		query := make([]byte, length)
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+		resp, err := s.handleQuery(query, maxTCPMessageSize)
+		if err != nil {
+			metrics.RecordError("dnsHandleQuery")
+			return
+		}
+		msg := make([]byte, 2+len(resp))
+		binary.BigEndian.PutUint16(msg, uint16(len(resp)))
+		copy(msg[2:], resp)
+		if _, err := conn.Write(msg); err != nil {
+			s.log.Printf("ERROR: Unable to write DNS response to %s: %v", conn.RemoteAddr(), err)
+			metrics.RecordError("dnsWriteResponse")
+			return
+		}
+	}
+}
+
+// handleQuery answers `query`. Answers longer than `maxSize` are truncated
+// Queries without exactly one valid question are answered with a format
+// error. An error is returned when the header of `query` can not be parsed
+func (s *DNSServer) handleQuery(query []byte, maxSize int) ([]byte, error) {
+	var p dnsmessage.Parser
+	header, err := p.Start(query)
+	if err != nil {
+		return nil, err
+	}
+	resp := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:               header.ID,
+			Response:         true,
+			Authoritative:    true,
+			RecursionDesired: header.RecursionDesired,
+		},
+	}
+	questions, err := p.AllQuestions()
+	if err != nil || len(questions) != 1 {
+		metrics.RecordError("dnsFormatError")
+		resp.RCode = dnsmessage.RCodeFormatError
+		return resp.Pack()
+	}
+	resp.Questions = questions
+	resp.Answers, resp.Additionals, resp.RCode = s.resolve(questions[0])
+
+	packed, err := resp.Pack()
+	if err != nil {
+		return nil, err
+	}
+	if len(packed) <= maxSize {
+		return packed, nil
+	}
+	resp.Truncated = true
+	resp.Answers = nil
+	resp.Additionals = nil
+	return resp.Pack()
+}
+
+// resolve returns the answers and additional records for `q`
+func (s *DNSServer) resolve(q dnsmessage.Question) ([]dnsmessage.Resource, []dnsmessage.Resource, dnsmessage.RCode) {
+	name := strings.ToLower(q.Name.String())
+	if !strings.HasSuffix(name, "."+s.Domain) {
+		return nil, nil, dnsmessage.RCodeRefused
+	}
+	rest := strings.TrimSuffix(name, "."+s.Domain)
+	idx := strings.LastIndex(rest, ".")
+	if idx <= 0 {
+		return nil, nil, dnsmessage.RCodeNameError
+	}
+	label, kind := rest[:idx], rest[idx+1:]
+
+	switch kind {
+	case "service":
+		return s.resolveService(q, label)
+	case "node":
+		return s.resolveNode(q, label)
+	case "addr":
+		return s.resolveAddr(q, label)
+	}
+	return nil, nil, dnsmessage.RCodeNameError
+}
+
+// resolveAddr answers only for task addresses of cached services, so that
+// the server does not resolve arbitrary addresses
+func (s *DNSServer) resolveAddr(q dnsmessage.Question, label string) ([]dnsmessage.Resource, []dnsmessage.Resource, dnsmessage.RCode) {
+	ip := net.ParseIP(strings.Replace(label, "-", ".", -1)).To4()
+	if ip == nil || !s.isTaskAddr(ip) {
+		return nil, nil, dnsmessage.RCodeNameError
+	}
+	if !wantsType(q, dnsmessage.TypeA) {
+		return nil, nil, dnsmessage.RCodeSuccess
+	}
+	return []dnsmessage.Resource{s.aResource(q.Name, ip)}, nil, dnsmessage.RCodeSuccess
+}
+
+func (s *DNSServer) isTaskAddr(ip net.IP) bool {
+	for _, ssm := range s.ssCache.GetAll() {
+		for nodeIP := range ssm.NodeInfo {
+			if ip.Equal(net.ParseIP(nodeIP.Addr)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *DNSServer) resolveService(q dnsmessage.Question, label string) ([]dnsmessage.Resource, []dnsmessage.Resource, dnsmessage.RCode) {
+	ssm, ok := s.findService(label)
+	if !ok {
+		return nil, nil, dnsmessage.RCodeNameError
+	}
+
+	ips := []net.IP{}
+	for nodeIP := range ssm.NodeInfo {
+		if ip := net.ParseIP(nodeIP.Addr).To4(); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	sort.Slice(ips, func(i, j int) bool { return ips[i].String() < ips[j].String() })
+
+	answers := []dnsmessage.Resource{}
+	additionals := []dnsmessage.Resource{}
+	if wantsType(q, dnsmessage.TypeA) {
+		for _, ip := range ips {
+			answers = append(answers, s.aResource(q.Name, ip))
+		}
+	}
+	port, err := strconv.ParseUint(ssm.Labels["com.df.port"], 10, 16)
+	if err == nil && wantsType(q, dnsmessage.TypeSRV) {
+		for _, ip := range ips {
+			target, err := dnsmessage.NewName(fmt.Sprintf(
+				"%s.addr.%s", strings.Replace(ip.String(), ".", "-", -1), s.Domain))
+			if err != nil {
+				continue
+			}
+			answers = append(answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{
+					Name:  q.Name,
+					Type:  dnsmessage.TypeSRV,
+					Class: dnsmessage.ClassINET,
+					TTL:   s.TTL,
+				},
+				Body: &dnsmessage.SRVResource{
+					Priority: 1,
+					Weight:   1,
+					Port:     uint16(port),
+					Target:   target,
+				},
+			})
+			additionals = append(additionals, s.aResource(target, ip))
+		}
+	}
+	return answers, additionals, dnsmessage.RCodeSuccess
+}
+
+// findService returns the service named `label`. Services are matched by
+// their short `serviceName` only when no service has the full name and the
+// short name is unique
+func (s *DNSServer) findService(label string) (SwarmServiceMini, bool) {
+	shortNameMatches := []SwarmServiceMini{}
+	for _, ssm := range s.ssCache.GetAll() {
+		if strings.EqualFold(ssm.Name, label) {
+			return ssm, true
+		}
+		serviceName := GetSwarmServiceMiniCreateParameters(ssm)["serviceName"]
+		if strings.EqualFold(serviceName, label) {
+			shortNameMatches = append(shortNameMatches, ssm)
+		}
+	}
+	if len(shortNameMatches) != 1 {
+		return SwarmServiceMini{}, false
+	}
+	return shortNameMatches[0], true
+}
+
+func (s *DNSServer) resolveNode(q dnsmessage.Question, label string) ([]dnsmessage.Resource, []dnsmessage.Resource, dnsmessage.RCode) {
+	for _, nm := range s.nodeCache.GetAll() {
+		if !strings.EqualFold(nm.Hostname, label) {
+			continue
+		}
+		ip := net.ParseIP(nm.Addr).To4()
+		if ip == nil || !wantsType(q, dnsmessage.TypeA) {
+			return nil, nil, dnsmessage.RCodeSuccess
+		}
+		return []dnsmessage.Resource{s.aResource(q.Name, ip)}, nil, dnsmessage.RCodeSuccess
+	}
+	return nil, nil, dnsmessage.RCodeNameError
+}
+
+func (s *DNSServer) aResource(name dnsmessage.Name, ip net.IP) dnsmessage.Resource {
+	var a [4]byte
+	copy(a[:], ip.To4())
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{
+			Name:  name,
+			Type:  dnsmessage.TypeA,
+			Class: dnsmessage.ClassINET,
+			TTL:   s.TTL,
+		},
+		Body: &dnsmessage.AResource{A: a},
+	}
+}
+
+func wantsType(q dnsmessage.Question, t dnsmessage.Type) bool {
+	return q.Type == t || q.Type == dnsmessage.TypeALL
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+type DNSServerTestSuite struct {
+	suite.Suite
+	SSCache   *SwarmServiceCache
+	NodeCache *NodeCache
+	Server    *DNSServer
+	LogBytes  *bytes.Buffer
+}
+
+func TestDNSServerUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(DNSServerTestSuite))
+}
+
+func (s *DNSServerTestSuite) SetupTest() {
+	s.SSCache = NewSwarmServiceCache()
+	s.NodeCache = NewNodeCache()
+	s.LogBytes = new(bytes.Buffer)
+	s.Server = NewDNSServer("127.0.0.1:0", "swarm.", s.SSCache, s.NodeCache, log.New(s.LogBytes, "", 0))
+
+	ssm := getNewSwarmServiceMini()
+	ssm.Labels["com.df.port"] = "8080"
+	ssm.NodeInfo.Add("node-2", "1.0.0.2", "id2")
+	s.SSCache.InsertAndCheck(ssm)
+
+	nm := getNewNodeMini()
+	nm.Addr = "10.0.0.1"
+	s.NodeCache.InsertAndCheck(nm)
+}
+
+func (s *DNSServerTestSuite) Test_Service_A() {
+	resp := s.query("demo-go.service.swarm.", dnsmessage.TypeA)
+
+	s.Equal(dnsmessage.RCodeSuccess, resp.RCode)
+	s.True(resp.Authoritative)
+	s.Equal([]string{"1.0.0.1", "1.0.0.2"}, aRecords(resp.Answers))
+}
+
+func (s *DNSServerTestSuite) Test_Service_SRV() {
+	resp := s.query("demo-go.service.swarm.", dnsmessage.TypeSRV)
+
+	s.Equal(dnsmessage.RCodeSuccess, resp.RCode)
+	s.Require().Len(resp.Answers, 2)
+	srv, ok := resp.Answers[0].Body.(*dnsmessage.SRVResource)
+	s.Require().True(ok)
+	s.Equal(uint16(8080), srv.Port)
+	s.Equal("1-0-0-1.addr.swarm.", srv.Target.String())
+	s.Equal([]string{"1.0.0.1", "1.0.0.2"}, aRecords(resp.Additionals))
+}
+
+func (s *DNSServerTestSuite) Test_Service_ShortName() {
+	s.insertStackService("stackA", "api", "1.0.1.1")
+	s.insertStackService("stackB", "api", "1.0.2.1")
+	s.insertStackService("stackA", "web", "1.0.1.2")
+
+	resp := s.query("web.service.swarm.", dnsmessage.TypeA)
+	s.Equal(dnsmessage.RCodeSuccess, resp.RCode)
+	s.Equal([]string{"1.0.1.2"}, aRecords(resp.Answers))
+
+	// Short names of more than one service are ambiguous
+	resp = s.query("api.service.swarm.", dnsmessage.TypeA)
+	s.Equal(dnsmessage.RCodeNameError, resp.RCode)
+	s.Empty(resp.Answers)
+
+	resp = s.query("stackB_api.service.swarm.", dnsmessage.TypeA)
+	s.Equal(dnsmessage.RCodeSuccess, resp.RCode)
+	s.Equal([]string{"1.0.2.1"}, aRecords(resp.Answers))
+}
+
+func (s *DNSServerTestSuite) Test_Service_FullNameTakesPrecedenceOverShortName() {
+	s.insertStackService("stackA", "demo-go", "1.0.1.1")
+
+	resp := s.query("demo-go.service.swarm.", dnsmessage.TypeA)
+
+	s.Equal(dnsmessage.RCodeSuccess, resp.RCode)
+	s.Equal([]string{"1.0.0.1", "1.0.0.2"}, aRecords(resp.Answers))
+}
+
+func (s *DNSServerTestSuite) Test_Addr_A() {
+	resp := s.query("1-0-0-2.addr.swarm.", dnsmessage.TypeA)
+
+	s.Equal(dnsmessage.RCodeSuccess, resp.RCode)
+	s.Equal([]string{"1.0.0.2"}, aRecords(resp.Answers))
+}
+
+func (s *DNSServerTestSuite) Test_Addr_UnknownAddress_ReturnsNameError() {
+	resp := s.query("8-8-8-8.addr.swarm.", dnsmessage.TypeA)
+
+	s.Equal(dnsmessage.RCodeNameError, resp.RCode)
+	s.Empty(resp.Answers)
+}
+
+func (s *DNSServerTestSuite) Test_Node_A() {
+	resp := s.query("NodeHostname.node.swarm.", dnsmessage.TypeA)
+
+	s.Equal(dnsmessage.RCodeSuccess, resp.RCode)
+	s.Equal([]string{"10.0.0.1"}, aRecords(resp.Answers))
+}
+
+func (s *DNSServerTestSuite) Test_UnknownService_ReturnsNameError() {
+	resp := s.query("unknown.service.swarm.", dnsmessage.TypeA)
+
+	s.Equal(dnsmessage.RCodeNameError, resp.RCode)
+	s.Empty(resp.Answers)
+}
+
+func (s *DNSServerTestSuite) Test_OutsideDomain_ReturnsRefused() {
+	resp := s.query("demo-go.service.example.com.", dnsmessage.TypeA)
+
+	s.Equal(dnsmessage.RCodeRefused, resp.RCode)
+}
+
+func (s *DNSServerTestSuite) Test_HandleQuery_MultipleQuestions_ReturnsFormatError() {
+	req := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: 42},
+		Questions: []dnsmessage.Question{
+			{Name: dnsmessage.MustNewName("demo-go.service.swarm."), Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET},
+			{Name: dnsmessage.MustNewName("demo-go.service.swarm."), Type: dnsmessage.TypeSRV, Class: dnsmessage.ClassINET},
+		},
+	}
+	query, err := req.Pack()
+	s.Require().NoError(err)
+
+	resp := s.handleQuery(query)
+
+	s.Equal(dnsmessage.RCodeFormatError, resp.RCode)
+	s.Empty(resp.Answers)
+}
+
+func (s *DNSServerTestSuite) Test_HandleQuery_MalformedQuestion_ReturnsFormatError() {
+	query := s.packQuery("demo-go.service.swarm.", dnsmessage.TypeA)
+
+	resp := s.handleQuery(query[:len(query)-3])
+
+	s.Equal(dnsmessage.RCodeFormatError, resp.RCode)
+	s.Empty(resp.Questions)
+}
+
+func (s *DNSServerTestSuite) Test_HandleQuery_MalformedHeader_ReturnsError() {
+	_, err := s.Server.handleQuery([]byte{0, 42, 1}, maxUDPMessageSize)
+
+	s.Error(err)
+}
+
+func (s *DNSServerTestSuite) Test_Serve_AnswersOverUDP() {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer conn.Close()
+	go s.Server.Serve(conn)
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	s.Require().NoError(err)
+	defer client.Close()
+
+	_, err = client.Write(s.packQuery("demo-go.service.swarm.", dnsmessage.TypeA))
+	s.Require().NoError(err)
+
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, maxUDPMessageSize)
+	n, err := client.Read(buf)
+	s.Require().NoError(err)
+
+	var resp dnsmessage.Message
+	s.Require().NoError(resp.Unpack(buf[:n]))
+	s.Equal(uint16(42), resp.ID)
+	s.Equal([]string{"1.0.0.1", "1.0.0.2"}, aRecords(resp.Answers))
+}
+
+func (s *DNSServerTestSuite) Test_ServeTCP_AnswersTruncatedUDPAnswers() {
+	ssm := getNewSwarmServiceMini()
+	ssm.ID = "manyID"
+	ssm.Name = "many"
+	for i := 0; i < 50; i++ {
+		ssm.NodeInfo.Add("node-3", fmt.Sprintf("1.0.1.%d", i), "id3")
+	}
+	s.SSCache.InsertAndCheck(ssm)
+
+	udpResp := s.query("many.service.swarm.", dnsmessage.TypeA)
+	s.True(udpResp.Truncated)
+	s.Empty(udpResp.Answers)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer lis.Close()
+	go s.Server.ServeTCP(lis)
+
+	client, err := net.Dial("tcp", lis.Addr().String())
+	s.Require().NoError(err)
+	defer client.Close()
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+
+	query := s.packQuery("many.service.swarm.", dnsmessage.TypeA)
+	s.Require().NoError(binary.Write(client, binary.BigEndian, uint16(len(query))))
+	_, err = client.Write(query)
+	s.Require().NoError(err)
+
+	var length uint16
+	s.Require().NoError(binary.Read(client, binary.BigEndian, &length))
+	buf := make([]byte, length)
+	_, err = io.ReadFull(client, buf)
+	s.Require().NoError(err)
+
+	var resp dnsmessage.Message
+	s.Require().NoError(resp.Unpack(buf))
+	s.Equal(uint16(42), resp.ID)
+	s.False(resp.Truncated)
+	s.Len(resp.Answers, 51)
+}
+
+func (s *DNSServerTestSuite) insertStackService(stack, name, addr string) {
+	ssm := getNewSwarmServiceMini()
+	ssm.ID = stack + name
+	ssm.Name = stack + "_" + name
+	ssm.Labels["com.df.shortName"] = "true"
+	ssm.Labels["com.docker.stack.namespace"] = stack
+	ssm.NodeInfo = NodeIPSet{}
+	ssm.NodeInfo.Add("node-1", addr, "id1")
+	s.SSCache.InsertAndCheck(ssm)
+}
+
+func (s *DNSServerTestSuite) query(name string, qType dnsmessage.Type) dnsmessage.Message {
+	return s.handleQuery(s.packQuery(name, qType))
+}
+
+func (s *DNSServerTestSuite) handleQuery(query []byte) dnsmessage.Message {
+	packed, err := s.Server.handleQuery(query, maxUDPMessageSize)
+	s.Require().NoError(err)
+
+	var resp dnsmessage.Message
+	s.Require().NoError(resp.Unpack(packed))
+	s.Equal(uint16(42), resp.ID)
+	s.True(resp.Response)
+	return resp
+}
+
+func (s *DNSServerTestSuite) packQuery(name string, qType dnsmessage.Type) []byte {
+	req := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: 42, RecursionDesired: true},
+		Questions: []dnsmessage.Question{
+			{
+				Name:  dnsmessage.MustNewName(name),
+				Type:  qType,
+				Class: dnsmessage.ClassINET,
+			},
+		},
+	}
+	packed, err := req.Pack()
+	s.Require().NoError(err)
+	return packed
+}
+
+func aRecords(resources []dnsmessage.Resource) []string {
+	ips := []string{}
+	for _, r := range resources {
+		if a, ok := r.Body.(*dnsmessage.AResource); ok {
+			ips = append(ips, net.IP(a.A[:]).String())
+		}
+	}
+	return ips
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
+)
+
+// NetworkListening listens to network events
+type NetworkListening interface {
+	ListenForNetworkEvents(eventChan chan<- Event)
+}
+
+// NetworkListener listens for docker network events
+type NetworkListener struct {
+	dockerClient *client.Client
+	log          *log.Logger
+}
+
+// NewNetworkListener creates a `NetworkListener`
+func NewNetworkListener(c *client.Client, logger *log.Logger) *NetworkListener {
+	return &NetworkListener{dockerClient: c, log: logger}
+}
+
+// ListenForNetworkEvents listens for events and places them on channels
+func (s NetworkListener) ListenForNetworkEvents(
+	eventChan chan<- Event) {
+
+	go func() {
+		filter := filters.NewArgs()
+		filter.Add("type", "network")
+		msgStream, msgErrs := s.dockerClient.Events(
+			context.Background(), types.EventsOptions{Filters: filter})
+
+		for {
+			select {
+			case msg := <-msgStream:
+				if !s.validEventNetwork(msg) {
+					continue
+				}
+				eventType := EventTypeCreate
+				if msg.Action == "destroy" {
+					eventType = EventTypeRemove
+				}
+				eventChan <- Event{
+					Type:     eventType,
+					ID:       msg.Actor.ID,
+					TimeNano: msg.TimeNano,
+				}
+			case err := <-msgErrs:
+				s.log.Printf("%v, Restarting docker event stream", err)
+				metrics.RecordError("ListenForNetworkEvents")
+				time.Sleep(time.Second)
+				// Reopen event stream
+				msgStream, msgErrs = s.dockerClient.Events(
+					context.Background(), types.EventsOptions{Filters: filter})
+			}
+		}
+	}()
+
+}
+
+// validEventNetwork returns true when event is valid (should be passed through)
+// Only overlay networks are created. Destroyed networks are always passed
+// through, since the cache only holds overlay networks
+func (s NetworkListener) validEventNetwork(msg events.Message) bool {
+	if len(msg.Actor.ID) == 0 {
+		return false
+	}
+	switch msg.Action {
+	case "destroy":
+		return true
+	case "create":
+		return msg.Actor.Attributes["type"] == "overlay"
+	}
+	return false
+}
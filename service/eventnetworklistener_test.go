@@ -0,0 +1,58 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/stretchr/testify/suite"
+)
+
+type EventNetworkListenerTestSuite struct {
+	suite.Suite
+	Listener *NetworkListener
+}
+
+func TestEventNetworkListenerUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(EventNetworkListenerTestSuite))
+}
+
+func (s *EventNetworkListenerTestSuite) SetupTest() {
+	s.Listener = NewNetworkListener(nil, nil)
+}
+
+func (s *EventNetworkListenerTestSuite) Test_ValidEventNetwork_OverlayCreate() {
+	s.True(s.Listener.validEventNetwork(events.Message{
+		Action: "create",
+		Actor: events.Actor{
+			ID:         "networkID",
+			Attributes: map[string]string{"name": "proxy", "type": "overlay"},
+		},
+	}))
+}
+
+func (s *EventNetworkListenerTestSuite) Test_ValidEventNetwork_BridgeCreate() {
+	s.False(s.Listener.validEventNetwork(events.Message{
+		Action: "create",
+		Actor: events.Actor{
+			ID:         "networkID",
+			Attributes: map[string]string{"name": "docker_gwbridge", "type": "bridge"},
+		},
+	}))
+}
+
+func (s *EventNetworkListenerTestSuite) Test_ValidEventNetwork_Destroy() {
+	s.True(s.Listener.validEventNetwork(events.Message{
+		Action: "destroy",
+		Actor:  events.Actor{ID: "networkID"},
+	}))
+}
+
+func (s *EventNetworkListenerTestSuite) Test_ValidEventNetwork_ConnectIsIgnored() {
+	s.False(s.Listener.validEventNetwork(events.Message{
+		Action: "connect",
+		Actor: events.Actor{
+			ID:         "networkID",
+			Attributes: map[string]string{"name": "proxy", "type": "overlay"},
+		},
+	}))
+}
@@ -1,16 +1,11 @@
 package service
 
 import (
-	"context"
 	"log"
 	"time"
 
-	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/events"
-	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
-
-	"../metrics"
 )
 
 // NodeListening listens to node events
@@ -19,52 +14,49 @@ type NodeListening interface {
 }
 
 // NodeListener listens for docker node events
+// When the event stream was down for longer than `MaxGap`, `OnGap` is
+// called to reconcile the nodes that changed in the meantime
 type NodeListener struct {
 	dockerClient *client.Client
 	log          *log.Logger
+	MaxGap       time.Duration
+	OnGap        func()
 }
 
 // NewNodeListener creates a `NodeListener``
 func NewNodeListener(c *client.Client, logger *log.Logger) *NodeListener {
-	return &NodeListener{dockerClient: c, log: logger}
+	return &NodeListener{
+		dockerClient: c, log: logger, MaxGap: defaultEventStreamMaxGap}
 }
 
 // ListenForNodeEvents listens for events and places them on channels
 func (s NodeListener) ListenForNodeEvents(
 	eventChan chan<- Event) {
 
-	go func() {
-		filter := filters.NewArgs()
-		filter.Add("type", "node")
-		msgStream, msgErrs := s.dockerClient.Events(
-			context.Background(), types.EventsOptions{Filters: filter})
-
-		for {
-			select {
-			case msg := <-msgStream:
-				if !s.validEventNode(msg) {
-					continue
-				}
-				eventType := EventTypeCreate
-				if msg.Action == "remove" {
-					eventType = EventTypeRemove
-				}
-				eventChan <- Event{
-					Type:     eventType,
-					ID:       msg.Actor.ID,
-					TimeNano: msg.TimeNano,
-				}
-			case err := <-msgErrs:
-				s.log.Printf("%v, Restarting docker event stream", err)
-				metrics.RecordError("ListenForNodeEvents")
-				time.Sleep(time.Second)
-				// Reopen event stream
-				msgStream, msgErrs = s.dockerClient.Events(
-					context.Background(), types.EventsOptions{Filters: filter})
-			}
+	stream := eventStream{
+		client:     s.dockerClient,
+		eventType:  "node",
+		operation:  "ListenForNodeEvents",
+		maxGap:     s.MaxGap,
+		minBackoff: defaultEventStreamMinBackoff,
+		maxBackoff: defaultEventStreamMaxBackoff,
+		onGap:      s.OnGap,
+		log:        s.log,
+	}
+	go stream.run(func(msg events.Message) {
+		if !s.validEventNode(msg) {
+			return
 		}
-	}()
-
+		eventType := EventTypeCreate
+		if msg.Action == "remove" {
+			eventType = EventTypeRemove
+		}
+		eventChan <- Event{
+			Type:     eventType,
+			ID:       msg.Actor.ID,
+			TimeNano: msg.TimeNano,
+		}
+	})
 }
 
 // validEventNode returns true when event is valid (should be passed through)
@@ -1,14 +1,10 @@
 package service
 
 import (
-	"context"
 	"log"
 	"time"
 
-	"../metrics"
-	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/events"
-	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
 )
 
@@ -18,49 +14,47 @@ type SwarmServiceListening interface {
 }
 
 // SwarmServiceListener listens for docker service events
+// When the event stream was down for longer than `MaxGap`, `OnGap` is
+// called to reconcile the services that changed in the meantime
 type SwarmServiceListener struct {
 	dockerClient *client.Client
 	log          *log.Logger
+	MaxGap       time.Duration
+	OnGap        func()
 }
 
 // NewSwarmServiceListener creates a `SwarmServiceListener`
 func NewSwarmServiceListener(c *client.Client, logger *log.Logger) *SwarmServiceListener {
-	return &SwarmServiceListener{dockerClient: c, log: logger}
+	return &SwarmServiceListener{
+		dockerClient: c, log: logger, MaxGap: defaultEventStreamMaxGap}
 }
 
 // ListenForServiceEvents listens for events and places them on channels
 func (s SwarmServiceListener) ListenForServiceEvents(eventChan chan<- Event) {
-	go func() {
-		filter := filters.NewArgs()
-		filter.Add("type", "service")
-		msgStream, msgErrs := s.dockerClient.Events(
-			context.Background(), types.EventsOptions{Filters: filter})
-
-		for {
-			select {
-			case msg := <-msgStream:
-				if !s.validEventNode(msg) {
-					continue
-				}
-				eventType := EventTypeCreate
-				if msg.Action == "remove" {
-					eventType = EventTypeRemove
-				}
-				eventChan <- Event{
-					Type:     eventType,
-					ID:       msg.Actor.ID,
-					TimeNano: msg.TimeNano,
-				}
-			case err := <-msgErrs:
-				s.log.Printf("%v, Restarting docker event stream", err)
-				metrics.RecordError("ListenForServiceEvents")
-				time.Sleep(time.Second)
-				// Reopen event stream
-				msgStream, msgErrs = s.dockerClient.Events(
-					context.Background(), types.EventsOptions{Filters: filter})
-			}
+	stream := eventStream{
+		client:     s.dockerClient,
+		eventType:  "service",
+		operation:  "ListenForServiceEvents",
+		maxGap:     s.MaxGap,
+		minBackoff: defaultEventStreamMinBackoff,
+		maxBackoff: defaultEventStreamMaxBackoff,
+		onGap:      s.OnGap,
+		log:        s.log,
+	}
+	go stream.run(func(msg events.Message) {
+		if !s.validEventNode(msg) {
+			return
+		}
+		eventType := EventTypeCreate
+		if msg.Action == "remove" {
+			eventType = EventTypeRemove
+		}
+		eventChan <- Event{
+			Type:     eventType,
+			ID:       msg.Actor.ID,
+			TimeNano: msg.TimeNano,
 		}
-	}()
+	})
 }
 
 // validEventNode returns true when event is valid (should be passed through)
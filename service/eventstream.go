@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
+)
+
+const (
+	defaultEventStreamMaxGap     = time.Minute
+	defaultEventStreamMinBackoff = time.Second
+	defaultEventStreamMaxBackoff = 30 * time.Second
+)
+
+// dockerEventsClient is able to stream docker events
+type dockerEventsClient interface {
+	Events(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error)
+}
+
+// eventStream streams the docker events of `eventType`
+// When the stream errors, it is reopened with `Since` set to the time of the
+// last received event, so that events during the reconnect are replayed.
+// `Since` includes the last received event, so replayed events that are
+// not newer than it are skipped.
+// Reconnects are retried with exponential backoff. When the stream was down
+// for longer than `maxGap`, the events can not be replayed reliably and
+// `onGap` is called instead. The stream is down from the first of
+// consecutive errors until it is reopened
+type eventStream struct {
+	client     dockerEventsClient
+	eventType  string
+	operation  string
+	maxGap     time.Duration
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	onGap      func()
+	log        *log.Logger
+}
+
+// run passes the events of the stream to `handle`
+func (s eventStream) run(handle func(events.Message)) {
+	filter := filters.NewArgs()
+	filter.Add("type", s.eventType)
+
+	lastTimeNano := time.Now().UTC().UnixNano()
+	backoff := s.minBackoff
+	var connectedAt, downSince time.Time
+	replaying := false
+	msgStream, msgErrs := s.client.Events(
+		context.Background(), types.EventsOptions{Filters: filter})
+	connectedAt = time.Now()
+	metrics.RecordEventStreamConnected(s.eventType, true)
+
+	for {
+		select {
+		case msg := <-msgStream:
+			backoff = s.minBackoff
+			downSince = time.Time{}
+			if replaying && msg.TimeNano <= lastTimeNano {
+				continue
+			}
+			if msg.TimeNano > lastTimeNano {
+				lastTimeNano = msg.TimeNano
+			}
+			metrics.RecordEventStreamEvent(s.eventType, msg.TimeNano)
+			handle(msg)
+		case err := <-msgErrs:
+			// A stream that errors right after it was reopened did not
+			// recover, the stream is still down since the previous error
+			if downSince.IsZero() || time.Since(connectedAt) > backoff {
+				downSince = time.Now()
+			}
+			s.log.Printf("%v, Restarting docker event stream", err)
+			metrics.RecordError(s.operation)
+			metrics.RecordEventStreamConnected(s.eventType, false)
+			time.Sleep(backoff)
+			backoff *= 2
+			if backoff > s.maxBackoff {
+				backoff = s.maxBackoff
+			}
+
+			// Reopen event stream
+			metrics.RecordEventStreamReconnect(s.eventType)
+			options := types.EventsOptions{Filters: filter}
+			gap := time.Since(downSince)
+			if s.maxGap > 0 && gap > s.maxGap {
+				s.log.Printf("Docker %s event stream was down for %s, reconciling", s.eventType, gap)
+				lastTimeNano = time.Now().UTC().UnixNano()
+				downSince = time.Time{}
+				replaying = false
+				if s.onGap != nil {
+					go s.onGap()
+				}
+			} else {
+				options.Since = formatEventsTime(lastTimeNano)
+				replaying = true
+			}
+			msgStream, msgErrs = s.client.Events(context.Background(), options)
+			connectedAt = time.Now()
+			metrics.RecordEventStreamConnected(s.eventType, true)
+		}
+	}
+}
+
+// formatEventsTime formats `timeNano` as the `seconds.nanoseconds`
+// timestamp used by the docker events api
+func formatEventsTime(timeNano int64) string {
+	return fmt.Sprintf("%d.%09d", timeNano/int64(time.Second), timeNano%int64(time.Second))
+}
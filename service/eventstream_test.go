@@ -0,0 +1,243 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/stretchr/testify/suite"
+)
+
+type fakeEventsClient struct {
+	streams []chan events.Message
+	errs    []chan error
+	calls   chan types.EventsOptions
+	mux     sync.Mutex
+}
+
+func newFakeEventsClient() *fakeEventsClient {
+	return &fakeEventsClient{calls: make(chan types.EventsOptions, 10)}
+}
+
+func (c *fakeEventsClient) Events(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	msgStream := make(chan events.Message)
+	msgErrs := make(chan error, 1)
+	c.streams = append(c.streams, msgStream)
+	c.errs = append(c.errs, msgErrs)
+	c.calls <- options
+	return msgStream, msgErrs
+}
+
+func (c *fakeEventsClient) stream(i int) (chan events.Message, chan error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.streams[i], c.errs[i]
+}
+
+type EventStreamTestSuite struct {
+	suite.Suite
+	Logger *log.Logger
+}
+
+func TestEventStreamUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(EventStreamTestSuite))
+}
+
+func (s *EventStreamTestSuite) SetupTest() {
+	s.Logger = log.New(bytes.NewBuffer(nil), "", 0)
+}
+
+func (s *EventStreamTestSuite) Test_Run_ReopensWithSince() {
+	client := newFakeEventsClient()
+	stream := eventStream{
+		client:     client,
+		eventType:  "service",
+		operation:  "ListenForServiceEvents",
+		maxGap:     time.Hour,
+		minBackoff: time.Millisecond,
+		maxBackoff: time.Millisecond,
+		log:        s.Logger,
+	}
+	handled := make(chan events.Message)
+	go stream.run(func(msg events.Message) {
+		handled <- msg
+	})
+
+	options := s.waitForCall(client)
+	s.Empty(options.Since)
+	s.Equal([]string{"service"}, options.Filters.Get("type"))
+
+	timeNano := time.Now().UTC().UnixNano() + int64(time.Second)
+	msgStream, msgErrs := client.stream(0)
+	msgStream <- events.Message{Action: "create", TimeNano: timeNano}
+	s.Equal(timeNano, (<-handled).TimeNano)
+	msgErrs <- errors.New("unexpected EOF")
+
+	options = s.waitForCall(client)
+	s.Equal(formatEventsTime(timeNano), options.Since)
+	s.Equal([]string{"service"}, options.Filters.Get("type"))
+
+	msgStream, _ = client.stream(1)
+	msgStream <- events.Message{Action: "remove", TimeNano: timeNano + 1}
+	s.Equal(timeNano+1, (<-handled).TimeNano)
+}
+
+func (s *EventStreamTestSuite) Test_Run_AfterReconnect_SkipsReplayedEvents() {
+	client := newFakeEventsClient()
+	stream := eventStream{
+		client:     client,
+		eventType:  "service",
+		operation:  "ListenForServiceEvents",
+		maxGap:     time.Hour,
+		minBackoff: time.Millisecond,
+		maxBackoff: time.Millisecond,
+		log:        s.Logger,
+	}
+	handled := make(chan events.Message, 10)
+	go stream.run(func(msg events.Message) {
+		handled <- msg
+	})
+
+	s.waitForCall(client)
+	timeNano := time.Now().UTC().UnixNano() + int64(time.Second)
+	msgStream, msgErrs := client.stream(0)
+	msgStream <- events.Message{Action: "create", TimeNano: timeNano}
+	s.Equal(timeNano, (<-handled).TimeNano)
+	msgErrs <- errors.New("unexpected EOF")
+
+	s.waitForCall(client)
+	// Since is inclusive, the last event is sent again
+	msgStream, _ = client.stream(1)
+	msgStream <- events.Message{Action: "create", TimeNano: timeNano}
+	msgStream <- events.Message{Action: "update", TimeNano: timeNano - 1}
+	msgStream <- events.Message{Action: "remove", TimeNano: timeNano + 1}
+
+	msg := <-handled
+	s.Equal("remove", msg.Action)
+	s.Equal(timeNano+1, msg.TimeNano)
+	s.Empty(handled)
+}
+
+func (s *EventStreamTestSuite) Test_Run_GapTooLarge_CallsOnGap() {
+	client := newFakeEventsClient()
+	gapCalled := make(chan struct{}, 1)
+	stream := eventStream{
+		client:     client,
+		eventType:  "node",
+		operation:  "ListenForNodeEvents",
+		maxGap:     time.Millisecond,
+		minBackoff: 10 * time.Millisecond,
+		maxBackoff: 10 * time.Millisecond,
+		onGap: func() {
+			gapCalled <- struct{}{}
+		},
+		log: s.Logger,
+	}
+	go stream.run(func(msg events.Message) {})
+
+	s.waitForCall(client)
+	_, msgErrs := client.stream(0)
+	msgErrs <- errors.New("unexpected EOF")
+
+	options := s.waitForCall(client)
+	s.Empty(options.Since)
+
+	select {
+	case <-gapCalled:
+	case <-time.NewTimer(time.Second * 5).C:
+		s.Fail("Timeout")
+	}
+}
+
+func (s *EventStreamTestSuite) Test_Run_IdleLongerThanMaxGap_ReopensWithSince() {
+	client := newFakeEventsClient()
+	gapCalled := make(chan struct{}, 1)
+	stream := eventStream{
+		client:     client,
+		eventType:  "service",
+		operation:  "ListenForServiceEvents",
+		maxGap:     100 * time.Millisecond,
+		minBackoff: time.Millisecond,
+		maxBackoff: time.Millisecond,
+		onGap: func() {
+			gapCalled <- struct{}{}
+		},
+		log: s.Logger,
+	}
+	startedAt := time.Now().UTC().UnixNano()
+	go stream.run(func(msg events.Message) {})
+
+	s.waitForCall(client)
+	// The cluster is idle for longer than `maxGap` before the stream
+	// errors and is reopened right away
+	time.Sleep(200 * time.Millisecond)
+	_, msgErrs := client.stream(0)
+	msgErrs <- errors.New("unexpected EOF")
+
+	options := s.waitForCall(client)
+	s.NotEmpty(options.Since)
+	s.True(options.Since >= formatEventsTime(startedAt))
+	s.Empty(gapCalled)
+}
+
+func (s *EventStreamTestSuite) Test_Run_ConsecutiveErrors_AddUpToGap() {
+	client := newFakeEventsClient()
+	gapCalled := make(chan struct{}, 1)
+	stream := eventStream{
+		client:     client,
+		eventType:  "service",
+		operation:  "ListenForServiceEvents",
+		maxGap:     150 * time.Millisecond,
+		minBackoff: 100 * time.Millisecond,
+		maxBackoff: 100 * time.Millisecond,
+		onGap: func() {
+			gapCalled <- struct{}{}
+		},
+		log: s.Logger,
+	}
+	go stream.run(func(msg events.Message) {})
+
+	s.waitForCall(client)
+	_, msgErrs := client.stream(0)
+	msgErrs <- errors.New("unexpected EOF")
+
+	// The first reconnect is within `maxGap`
+	options := s.waitForCall(client)
+	s.NotEmpty(options.Since)
+
+	// The reopened stream fails right away, so the stream is down since
+	// the first error
+	_, msgErrs = client.stream(1)
+	msgErrs <- errors.New("connection refused")
+
+	options = s.waitForCall(client)
+	s.Empty(options.Since)
+	select {
+	case <-gapCalled:
+	case <-time.NewTimer(time.Second * 5).C:
+		s.Fail("Timeout")
+	}
+}
+
+func (s *EventStreamTestSuite) Test_FormatEventsTime() {
+	s.Equal("1514764800.000000005", formatEventsTime(int64(1514764800000000005)))
+	s.Equal("1514764800.123456789", formatEventsTime(int64(1514764800123456789)))
+}
+
+func (s *EventStreamTestSuite) waitForCall(client *fakeEventsClient) types.EventsOptions {
+	select {
+	case options := <-client.calls:
+		return options
+	case <-time.NewTimer(time.Second * 5).C:
+		s.FailNow("Timeout")
+	}
+	return types.EventsOptions{}
+}
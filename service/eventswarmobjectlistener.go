@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
+)
+
+// SwarmObjectListening listens to config or secret events
+type SwarmObjectListening interface {
+	ListenForSwarmObjectEvents(eventChan chan<- Event)
+}
+
+// SwarmObjectListener listens for docker config or secret events
+type SwarmObjectListener struct {
+	dockerClient *client.Client
+	kind         NotifyType
+	log          *log.Logger
+}
+
+// NewConfigListener creates a `SwarmObjectListener` for configs
+func NewConfigListener(c *client.Client, logger *log.Logger) *SwarmObjectListener {
+	return &SwarmObjectListener{dockerClient: c, kind: NotifyTypeConfig, log: logger}
+}
+
+// NewSecretListener creates a `SwarmObjectListener` for secrets
+func NewSecretListener(c *client.Client, logger *log.Logger) *SwarmObjectListener {
+	return &SwarmObjectListener{dockerClient: c, kind: NotifyTypeSecret, log: logger}
+}
+
+// ListenForSwarmObjectEvents listens for events and places them on channels
+func (s SwarmObjectListener) ListenForSwarmObjectEvents(eventChan chan<- Event) {
+
+	go func() {
+		filter := filters.NewArgs()
+		filter.Add("type", string(s.kind))
+		msgStream, msgErrs := s.dockerClient.Events(
+			context.Background(), types.EventsOptions{Filters: filter})
+
+		for {
+			select {
+			case msg := <-msgStream:
+				event, ok := swarmObjectEventFromMessage(msg)
+				if !ok {
+					continue
+				}
+				eventChan <- event
+			case err := <-msgErrs:
+				s.log.Printf("%v, Restarting docker event stream", err)
+				metrics.RecordError("ListenForSwarmObjectEvents")
+				time.Sleep(time.Second)
+				// Reopen event stream
+				msgStream, msgErrs = s.dockerClient.Events(
+					context.Background(), types.EventsOptions{Filters: filter})
+			}
+		}
+	}()
+}
+
+// swarmObjectEventFromMessage converts a config or secret event message
+// into an `Event`. Create and update actions are create events
+func swarmObjectEventFromMessage(msg events.Message) (Event, bool) {
+	var eventType EventType
+	switch msg.Action {
+	case "create", "update":
+		eventType = EventTypeCreate
+	case "remove":
+		eventType = EventTypeRemove
+	default:
+		return Event{}, false
+	}
+	if len(msg.Actor.ID) == 0 {
+		return Event{}, false
+	}
+	return Event{
+		Type:     eventType,
+		ID:       msg.Actor.ID,
+		TimeNano: msg.TimeNano,
+	}, true
+}
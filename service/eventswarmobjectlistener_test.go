@@ -0,0 +1,43 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/stretchr/testify/suite"
+)
+
+type EventSwarmObjectListenerTestSuite struct {
+	suite.Suite
+}
+
+func TestEventSwarmObjectListenerUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(EventSwarmObjectListenerTestSuite))
+}
+
+func (s *EventSwarmObjectListenerTestSuite) Test_SwarmObjectEventFromMessage() {
+	for action, expected := range map[string]EventType{
+		"create": EventTypeCreate,
+		"update": EventTypeCreate,
+		"remove": EventTypeRemove,
+	} {
+		event, ok := swarmObjectEventFromMessage(events.Message{
+			Type:     "config",
+			Action:   action,
+			Actor:    events.Actor{ID: "configID1"},
+			TimeNano: 10,
+		})
+		s.True(ok)
+		s.Equal(Event{Type: expected, ID: "configID1", TimeNano: 10}, event)
+	}
+}
+
+func (s *EventSwarmObjectListenerTestSuite) Test_SwarmObjectEventFromMessage_Invalid() {
+	_, ok := swarmObjectEventFromMessage(events.Message{
+		Type: "secret", Action: "attach", Actor: events.Actor{ID: "secretID1"},
+	})
+	s.False(ok)
+
+	_, ok = swarmObjectEventFromMessage(events.Message{Type: "secret", Action: "create"})
+	s.False(ok)
+}
@@ -1,8 +1,10 @@
 package service
 
 import (
+	"sort"
 	"strings"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/swarm"
 )
 
@@ -35,6 +37,22 @@ func MinifyNode(n swarm.Node) NodeMini {
 	}
 }
 
+// MinifyNetwork minifies `types.NetworkResource`
+// all labels are kept, since they are sent as the `labels` parameter
+func MinifyNetwork(n types.NetworkResource) NetworkMini {
+	labels := map[string]string{}
+	for k, v := range n.Labels {
+		labels[k] = v
+	}
+	return NetworkMini{
+		ID:     n.ID,
+		Name:   n.Name,
+		Driver: n.Driver,
+		Scope:  n.Scope,
+		Labels: labels,
+	}
+}
+
 // MinifySwarmService minifies `SwarmService`
 // only labels prefixed with `com.df.` will be used
 // `ignoreKey` wll be ignored from labels
@@ -63,3 +81,53 @@ func MinifySwarmService(ss SwarmService, ignoreKey string, includeKey string) Sw
 	}
 	return ssm
 }
+
+// MinifyEndpoint minifies `swarm.Endpoint`
+// Ports and virtual IPs are sorted, so that they can be compared
+func MinifyEndpoint(e swarm.Endpoint) *EndpointMini {
+	em := EndpointMini{
+		Mode:       e.Spec.Mode,
+		Ports:      []PortMini{},
+		VirtualIPs: []VirtualIPMini{},
+	}
+	if len(em.Mode) == 0 {
+		em.Mode = swarm.ResolutionModeVIP
+	}
+	for _, p := range e.Ports {
+		em.Ports = append(em.Ports, PortMini{
+			Protocol:      p.Protocol,
+			TargetPort:    p.TargetPort,
+			PublishedPort: p.PublishedPort,
+			PublishMode:   p.PublishMode,
+		})
+	}
+	for _, vip := range e.VirtualIPs {
+		em.VirtualIPs = append(em.VirtualIPs, VirtualIPMini{
+			NetworkID: vip.NetworkID,
+			Addr:      vip.Addr,
+		})
+	}
+	sort.Slice(em.Ports, func(i, j int) bool {
+		if em.Ports[i].TargetPort != em.Ports[j].TargetPort {
+			return em.Ports[i].TargetPort < em.Ports[j].TargetPort
+		}
+		if em.Ports[i].PublishedPort != em.Ports[j].PublishedPort {
+			return em.Ports[i].PublishedPort < em.Ports[j].PublishedPort
+		}
+		return em.Ports[i].Protocol < em.Ports[j].Protocol
+	})
+	sort.Slice(em.VirtualIPs, func(i, j int) bool {
+		return em.VirtualIPs[i].NetworkID < em.VirtualIPs[j].NetworkID
+	})
+	return &em
+}
+
+// minifySwarmService minifies `SwarmService` like `MinifySwarmService`
+// The endpoint is only included when `includeEndpoint` is true
+func minifySwarmService(ss SwarmService, ignoreKey, includeKey string, includeEndpoint bool) SwarmServiceMini {
+	ssm := MinifySwarmService(ss, ignoreKey, includeKey)
+	if includeEndpoint {
+		ssm.Endpoint = MinifyEndpoint(ss.Endpoint)
+	}
+	return ssm
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"testing"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/stretchr/testify/suite"
 )
@@ -167,3 +168,79 @@ func (s *MinifyUnitTestSuite) Test_MinifySwarmService_Replicas() {
 
 	s.Equal(expectMini, ssMini)
 }
+
+func (s *MinifyUnitTestSuite) Test_MinifyEndpoint() {
+	endpoint := swarm.Endpoint{
+		Spec: swarm.EndpointSpec{Mode: swarm.ResolutionModeDNSRR},
+		Ports: []swarm.PortConfig{
+			{Name: "https", Protocol: swarm.PortConfigProtocolTCP, TargetPort: 443,
+				PublishedPort: 8443, PublishMode: swarm.PortConfigPublishModeIngress},
+			{Name: "http", Protocol: swarm.PortConfigProtocolTCP, TargetPort: 80,
+				PublishedPort: 8080, PublishMode: swarm.PortConfigPublishModeHost},
+		},
+		VirtualIPs: []swarm.EndpointVirtualIP{
+			{NetworkID: "network2", Addr: "10.0.1.5/24"},
+			{NetworkID: "network1", Addr: "10.0.0.5/24"},
+		},
+	}
+
+	expected := &EndpointMini{
+		Mode: swarm.ResolutionModeDNSRR,
+		Ports: []PortMini{
+			{Protocol: swarm.PortConfigProtocolTCP, TargetPort: 80,
+				PublishedPort: 8080, PublishMode: swarm.PortConfigPublishModeHost},
+			{Protocol: swarm.PortConfigProtocolTCP, TargetPort: 443,
+				PublishedPort: 8443, PublishMode: swarm.PortConfigPublishModeIngress},
+		},
+		VirtualIPs: []VirtualIPMini{
+			{NetworkID: "network1", Addr: "10.0.0.5/24"},
+			{NetworkID: "network2", Addr: "10.0.1.5/24"},
+		},
+	}
+	s.Equal(expected, MinifyEndpoint(endpoint))
+}
+
+func (s *MinifyUnitTestSuite) Test_MinifyEndpoint_DefaultsToVIP() {
+	expected := &EndpointMini{
+		Mode:       swarm.ResolutionModeVIP,
+		Ports:      []PortMini{},
+		VirtualIPs: []VirtualIPMini{},
+	}
+	s.Equal(expected, MinifyEndpoint(swarm.Endpoint{}))
+}
+
+func (s *MinifyUnitTestSuite) Test_MinifySwarmService_IncludeEndpoint() {
+	service := swarm.Service{
+		ID: "serviceID",
+		Spec: swarm.ServiceSpec{
+			Annotations: swarm.Annotations{Name: "serviceName"},
+		},
+		Endpoint: swarm.Endpoint{
+			Spec: swarm.EndpointSpec{Mode: swarm.ResolutionModeVIP},
+		},
+	}
+
+	ssm := minifySwarmService(SwarmService{service, nil}, "com.df.notify", "", false)
+	s.Nil(ssm.Endpoint)
+
+	ssm = minifySwarmService(SwarmService{service, nil}, "com.df.notify", "", true)
+	s.Require().NotNil(ssm.Endpoint)
+	s.Equal(swarm.ResolutionModeVIP, ssm.Endpoint.Mode)
+}
+
+func (s *MinifyUnitTestSuite) Test_MinifyNetwork() {
+	n := types.NetworkResource{
+		ID:         "networkID",
+		Name:       "proxy",
+		Driver:     "overlay",
+		Scope:      "swarm",
+		Attachable: true,
+		Labels: map[string]string{
+			"com.df.proxy":               "true",
+			"com.docker.stack.namespace": "proxy",
+		},
+	}
+
+	nm := MinifyNetwork(n)
+	s.Equal(getNewNetworkMini(), nm)
+}
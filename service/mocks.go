@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/stretchr/testify/mock"
 )
@@ -71,6 +72,11 @@ func (m *swarmServiceCacherMock) Get(ID string) (SwarmServiceMini, bool) {
 	return args.Get(0).(SwarmServiceMini), args.Bool(1)
 }
 
+func (m *swarmServiceCacherMock) GetAll() []SwarmServiceMini {
+	args := m.Called()
+	return args.Get(0).([]SwarmServiceMini)
+}
+
 func (m *swarmServiceCacherMock) Len() int {
 	args := m.Called()
 	return args.Int(0)
@@ -98,6 +104,77 @@ func (m *nodeInspectorMock) NodeList(ctx context.Context) ([]swarm.Node, error)
 	return args.Get(0).([]swarm.Node), args.Error(1)
 }
 
+type taskInspectorMock struct {
+	mock.Mock
+}
+
+func (m *taskInspectorMock) TaskList(ctx context.Context, serviceID string) ([]swarm.Task, error) {
+	args := m.Called(ctx, serviceID)
+	return args.Get(0).([]swarm.Task), args.Error(1)
+}
+
+type networkListeningMock struct {
+	mock.Mock
+}
+
+func (m *networkListeningMock) ListenForNetworkEvents(eventChan chan<- Event) {
+	m.Called(eventChan)
+}
+
+type networkInspectorMock struct {
+	mock.Mock
+}
+
+func (m *networkInspectorMock) NetworkInspect(ctx context.Context, networkID string) (types.NetworkResource, error) {
+	args := m.Called(ctx, networkID)
+	return args.Get(0).(types.NetworkResource), args.Error(1)
+}
+
+func (m *networkInspectorMock) NetworkList(ctx context.Context) ([]types.NetworkResource, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]types.NetworkResource), args.Error(1)
+}
+
+type networkCacherMock struct {
+	mock.Mock
+}
+
+func (m *networkCacherMock) InsertAndCheck(n NetworkMini) bool {
+	args := m.Called(n)
+	return args.Bool(0)
+}
+
+func (m *networkCacherMock) Delete(ID string) {
+	m.Called(ID)
+}
+
+func (m *networkCacherMock) Get(ID string) (NetworkMini, bool) {
+	args := m.Called(ID)
+	return args.Get(0).(NetworkMini), args.Bool(1)
+}
+
+func (m *networkCacherMock) GetAll() []NetworkMini {
+	args := m.Called()
+	return args.Get(0).([]NetworkMini)
+}
+
+type swarmObjectListeningMock struct {
+	mock.Mock
+}
+
+func (m *swarmObjectListeningMock) ListenForSwarmObjectEvents(eventChan chan<- Event) {
+	m.Called(eventChan)
+}
+
+type swarmObjectInspectorMock struct {
+	mock.Mock
+}
+
+func (m *swarmObjectInspectorMock) SwarmObjectInspect(ctx context.Context, kind NotifyType, ID string) (SwarmObjectMini, error) {
+	args := m.Called(ctx, kind, ID)
+	return args.Get(0).(SwarmObjectMini), args.Error(1)
+}
+
 type nodeCacherMock struct {
 	mock.Mock
 }
@@ -116,6 +193,11 @@ func (m *nodeCacherMock) Get(ID string) (NodeMini, bool) {
 	return args.Get(0).(NodeMini), args.Bool(1)
 }
 
+func (m *nodeCacherMock) GetAll() []NodeMini {
+	args := m.Called()
+	return args.Get(0).([]NodeMini)
+}
+
 type notifyDistributorMock struct {
 	mock.Mock
 }
@@ -131,3 +213,30 @@ func (m *notifyDistributorMock) HasServiceListeners() bool {
 func (m *notifyDistributorMock) HasNodeListeners() bool {
 	return m.Called().Bool(0)
 }
+
+func (m *notifyDistributorMock) RunType(notifyType NotifyType, notiChan <-chan Notification) {
+	m.Called(notifyType, notiChan)
+}
+
+func (m *notifyDistributorMock) HasListeners(notifyType NotifyType) bool {
+	return m.Called(notifyType).Bool(0)
+}
+
+type auditLoggingMock struct {
+	mock.Mock
+}
+
+func (m *auditLoggingMock) Record(entry AuditEntry) error {
+	return m.Called(entry).Error(0)
+}
+
+func (m *auditLoggingMock) Tail(limit int, filter AuditFilter) ([]AuditEntry, error) {
+	args := m.Called(limit, filter)
+	return args.Get(0).([]AuditEntry), args.Error(1)
+}
+
+func (m *notifyDistributorMock) Subscribe(filter NotificationFilter, fn func(Notification)) func() {
+	args := m.Called(filter, fn)
+	unsubscribe, _ := args.Get(0).(func())
+	return unsubscribe
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+// NetworkInspector is able to inspect a swarm network
+type NetworkInspector interface {
+	NetworkInspect(ctx context.Context, networkID string) (types.NetworkResource, error)
+	NetworkList(ctx context.Context) ([]types.NetworkResource, error)
+}
+
+// NetworkClient implementes `NetworkInspector` for docker
+type NetworkClient struct {
+	DockerClient *client.Client
+}
+
+// NewNetworkClient creates a `NetworkClient`
+func NewNetworkClient(c *client.Client) *NetworkClient {
+	return &NetworkClient{DockerClient: c}
+}
+
+// NetworkInspect returns `types.NetworkResource` from its ID
+func (c NetworkClient) NetworkInspect(ctx context.Context, networkID string) (types.NetworkResource, error) {
+	return c.DockerClient.NetworkInspect(ctx, networkID, types.NetworkInspectOptions{})
+}
+
+// NetworkList returns a list of all overlay networks
+func (c NetworkClient) NetworkList(ctx context.Context) ([]types.NetworkResource, error) {
+	filter := filters.NewArgs()
+	filter.Add("driver", "overlay")
+	return c.DockerClient.NetworkList(ctx, types.NetworkListOptions{Filters: filter})
+}
@@ -0,0 +1,62 @@
+package service
+
+import "sync"
+
+// NetworkCacher caches networks
+type NetworkCacher interface {
+	InsertAndCheck(n NetworkMini) bool
+	Delete(ID string)
+	Get(ID string) (NetworkMini, bool)
+	GetAll() []NetworkMini
+}
+
+// NetworkCache implements `NetworkCacher`
+type NetworkCache struct {
+	cache map[string]NetworkMini
+	mux   sync.RWMutex
+}
+
+// NewNetworkCache creates a new `NetworkCache`
+func NewNetworkCache() *NetworkCache {
+	return &NetworkCache{
+		cache: map[string]NetworkMini{},
+	}
+}
+
+// InsertAndCheck inserts `NetworkMini` into cache
+// If the network is new or updated `InsertAndCheck` returns true.
+func (c *NetworkCache) InsertAndCheck(n NetworkMini) bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	cachedNetwork, ok := c.cache[n.ID]
+	c.cache[n.ID] = n
+
+	return !ok || !n.Equal(cachedNetwork)
+}
+
+// Delete removes network from cache
+func (c *NetworkCache) Delete(ID string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	delete(c.cache, ID)
+}
+
+// Get gets network from cache
+func (c *NetworkCache) Get(ID string) (NetworkMini, bool) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	v, ok := c.cache[ID]
+	return v, ok
+}
+
+// GetAll returns all networks in cache
+func (c *NetworkCache) GetAll() []NetworkMini {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	networks := make([]NetworkMini, 0, len(c.cache))
+	for _, v := range c.cache {
+		networks = append(networks, v)
+	}
+	return networks
+}
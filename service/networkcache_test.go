@@ -0,0 +1,83 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type NetworkCacheTestSuite struct {
+	suite.Suite
+	Cache *NetworkCache
+	NMini NetworkMini
+}
+
+func TestNetworkCacheUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(NetworkCacheTestSuite))
+}
+
+func (s *NetworkCacheTestSuite) SetupTest() {
+	s.Cache = NewNetworkCache()
+	s.NMini = getNewNetworkMini()
+}
+
+func (s *NetworkCacheTestSuite) Test_InsertAndCheck_NewNetwork_ReturnsTrue() {
+	isUpdated := s.Cache.InsertAndCheck(s.NMini)
+	s.True(isUpdated)
+	s.AssertInCache(s.NMini)
+}
+
+func (s *NetworkCacheTestSuite) Test_InsertAndCheck_SameNetwork_ReturnsFalse() {
+	isUpdated := s.Cache.InsertAndCheck(s.NMini)
+	s.True(isUpdated)
+
+	isUpdated = s.Cache.InsertAndCheck(getNewNetworkMini())
+	s.False(isUpdated)
+	s.AssertInCache(s.NMini)
+}
+
+func (s *NetworkCacheTestSuite) Test_InsertAndCheck_NewLabel_ReturnsTrue() {
+	isUpdated := s.Cache.InsertAndCheck(s.NMini)
+	s.True(isUpdated)
+
+	newNMini := getNewNetworkMini()
+	newNMini.Labels["com.df.wow"] = "yup"
+
+	isUpdated = s.Cache.InsertAndCheck(newNMini)
+	s.True(isUpdated)
+	s.AssertInCache(newNMini)
+}
+
+func (s *NetworkCacheTestSuite) Test_GetAndRemove_InCache_ReturnsNetworkMini_RemovesFromCache() {
+	s.Cache.InsertAndCheck(s.NMini)
+
+	removedNMini, ok := s.Cache.Get(s.NMini.ID)
+	s.True(ok)
+	s.Cache.Delete(s.NMini.ID)
+	s.AssertNotInCache(s.NMini)
+	s.Equal(s.NMini, removedNMini)
+}
+
+func (s *NetworkCacheTestSuite) Test_GetAll_ReturnsAllNetworks() {
+	s.Cache.InsertAndCheck(s.NMini)
+
+	newNMini := getNewNetworkMini()
+	newNMini.ID = "networkID2"
+	s.Cache.InsertAndCheck(newNMini)
+
+	networks := s.Cache.GetAll()
+	s.Len(networks, 2)
+	s.Contains(networks, s.NMini)
+	s.Contains(networks, newNMini)
+}
+
+func (s *NetworkCacheTestSuite) AssertInCache(nm NetworkMini) {
+	cachedNM, ok := s.Cache.Get(nm.ID)
+	s.True(ok)
+	s.Equal(nm, cachedNM)
+}
+
+func (s *NetworkCacheTestSuite) AssertNotInCache(nm NetworkMini) {
+	_, ok := s.Cache.Get(nm.ID)
+	s.False(ok)
+}
@@ -1,16 +1,19 @@
 package service
 
+import "sync"
+
 // NodeCacher caches sevices
 type NodeCacher interface {
 	InsertAndCheck(n NodeMini) bool
 	Delete(ID string)
 	Get(ID string) (NodeMini, bool)
+	GetAll() []NodeMini
 }
 
 // NodeCache implements `NodeCacher`
-// Not threadsafe!
 type NodeCache struct {
 	cache map[string]NodeMini
+	mux   sync.RWMutex
 }
 
 // NewNodeCache creates a new `NewNodeCache`
@@ -23,6 +26,9 @@ func NewNodeCache() *NodeCache {
 // InsertAndCheck inserts `NodeMini` into cache
 // If the node is new or updated `InsertAndCheck` returns true.
 func (c *NodeCache) InsertAndCheck(n NodeMini) bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
 	cachedNode, ok := c.cache[n.ID]
 	c.cache[n.ID] = n
 
@@ -31,11 +37,26 @@ func (c *NodeCache) InsertAndCheck(n NodeMini) bool {
 
 // Delete removes node from cache
 func (c *NodeCache) Delete(ID string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
 	delete(c.cache, ID)
 }
 
 // Get gets node from cache
-func (c NodeCache) Get(ID string) (NodeMini, bool) {
+func (c *NodeCache) Get(ID string) (NodeMini, bool) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
 	v, ok := c.cache[ID]
 	return v, ok
 }
+
+// GetAll returns all nodes in cache
+func (c *NodeCache) GetAll() []NodeMini {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	nodes := make([]NodeMini, 0, len(c.cache))
+	for _, v := range c.cache {
+		nodes = append(nodes, v)
+	}
+	return nodes
+}
@@ -149,6 +149,19 @@ func (s *NodeCacheTestSuite) Test_GetAndRemove_NotInCache_ReturnsFalse() {
 	s.False(ok)
 }
 
+func (s *NodeCacheTestSuite) Test_GetAll_ReturnsAllNodes() {
+	s.Cache.InsertAndCheck(s.NMini)
+
+	newNMini := getNewNodeMini()
+	newNMini.ID = "nodeID2"
+	s.Cache.InsertAndCheck(newNMini)
+
+	nodes := s.Cache.GetAll()
+	s.Len(nodes, 2)
+	s.Contains(nodes, s.NMini)
+	s.Contains(nodes, newNMini)
+}
+
 func (s *NodeCacheTestSuite) AssertInCache(nm NodeMini) {
 	ss, ok := s.Cache.Get(nm.ID)
 	s.True(ok)
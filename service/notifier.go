@@ -1,8 +1,11 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -10,12 +13,47 @@ import (
 	"strings"
 	"time"
 
-	"../metrics"
+	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
 )
 
 // NotifyType is the type of notification to send
 type NotifyType string
 
+// NotificationFormat is the format notifications are sent in
+type NotificationFormat string
+
+const (
+	// NotificationFormatQuery sends parameters as the query of a GET request
+	NotificationFormatQuery NotificationFormat = "query"
+	// NotificationFormatCloudEventsBinary sends a CloudEvents 1.0 POST request
+	// in binary mode, with the event attributes as headers
+	NotificationFormatCloudEventsBinary NotificationFormat = "cloudevents-binary"
+	// NotificationFormatCloudEventsStructured sends a CloudEvents 1.0 POST
+	// request in structured mode, with the whole event as the JSON body
+	NotificationFormatCloudEventsStructured NotificationFormat = "cloudevents-structured"
+)
+
+// notificationFormats are the formats notifications can be sent in
+var notificationFormats = []NotificationFormat{
+	NotificationFormatQuery,
+	NotificationFormatCloudEventsBinary,
+	NotificationFormatCloudEventsStructured,
+}
+
+// parseNotificationFormat returns the notification format named `name`
+// The error of unknown names lists the accepted names
+func parseNotificationFormat(name string) (NotificationFormat, error) {
+	accepted := []string{}
+	for _, format := range notificationFormats {
+		if string(format) == name {
+			return format, nil
+		}
+		accepted = append(accepted, string(format))
+	}
+	return "", fmt.Errorf("Unknown notification format %s, accepted formats are %s",
+		name, strings.Join(accepted, ", "))
+}
+
 // NotificationSender sends notifications to listeners
 type NotificationSender interface {
 	Create(ctx context.Context, params string) error
@@ -33,6 +71,12 @@ type Notifier struct {
 	interval          int
 	createErrorMetric string
 	removeErrorMetric string
+	format            NotificationFormat
+	source            string
+	createTemplate    *RequestTemplate
+	removeTemplate    *RequestTemplate
+	createClient      *http.Client
+	removeClient      *http.Client
 	log               *log.Logger
 }
 
@@ -40,6 +84,16 @@ type Notifier struct {
 func NewNotifier(
 	createAddr, removeAddr, notifyType string,
 	retries int, interval int, logger *log.Logger) *Notifier {
+	return NewFormattedNotifier(createAddr, removeAddr, notifyType,
+		NotificationFormatQuery, "", retries, interval, logger)
+}
+
+// NewFormattedNotifier returns a `Notifier` that sends notifications in
+// `format`. `source` is used as the CloudEvents source
+func NewFormattedNotifier(
+	createAddr, removeAddr, notifyType string,
+	format NotificationFormat, source string,
+	retries int, interval int, logger *log.Logger) *Notifier {
 	return &Notifier{
 		createAddr:        createAddr,
 		removeAddr:        removeAddr,
@@ -48,6 +102,10 @@ func NewNotifier(
 		interval:          interval,
 		createErrorMetric: fmt.Sprintf("notificationSendCreate%sRequest", notifyType),
 		removeErrorMetric: fmt.Sprintf("notificationSendRemove%sRequest", notifyType),
+		format:            format,
+		source:            source,
+		createClient:      newNotificationClient(createAddr),
+		removeClient:      newNotificationClient(removeAddr),
 		log:               logger,
 	}
 }
@@ -67,150 +125,207 @@ func (n Notifier) Create(ctx context.Context, params string) error {
 	if len(n.createAddr) == 0 {
 		return nil
 	}
+	return n.send(ctx, EventTypeCreate, n.createAddr, params)
+}
+
+// Remove sends remove notifications to listeners
+func (n Notifier) Remove(ctx context.Context, params string) error {
+	if len(n.removeAddr) == 0 {
+		return nil
+	}
+	return n.send(ctx, EventTypeRemove, n.removeAddr, params)
+}
+
+// send sends a notification to `addr` and retries until a successful
+// response is received
+func (n Notifier) send(ctx context.Context, eventType EventType, addr string, params string) error {
+	// Wording used in logs
+	action, actionPast, errorMetric := "create", "created", n.createErrorMetric
+	if eventType == EventTypeRemove {
+		action, actionPast, errorMetric = "remove", "removed", n.removeErrorMetric
+	}
 
-	urlObj, err := url.Parse(n.createAddr)
+	// Unix socket addresses are sent as http requests over the socket
+	_, httpAddr, _ := splitUnixSocketAddr(addr)
+	urlObj, err := url.Parse(httpAddr)
 	if err != nil {
 		n.log.Printf("ERROR: %v", err)
-		metrics.RecordError(n.createErrorMetric)
+		metrics.RecordError(errorMetric)
 		return err
 	}
-	urlObj.RawQuery = params
+	if n.format == NotificationFormatCloudEventsBinary ||
+		n.format == NotificationFormatCloudEventsStructured {
+		urlObj.RawQuery = ""
+	} else {
+		urlObj.RawQuery = params
+	}
 	fullURL := urlObj.String()
-	req, err := http.NewRequest(http.MethodGet, fullURL, nil)
+	// The request is rendered once and recreated from the rendered request
+	// for every attempt since its body can only be read once
+	request, err := n.renderRequest(ctx, eventType, httpAddr, fullURL, params)
 	if err != nil {
-		n.log.Printf("ERROR: Incorrect fullURL: %s", fullURL)
-		metrics.RecordError(n.createErrorMetric)
+		if n.requestTemplate(eventType) != nil {
+			n.log.Printf("ERROR: Unable to render request template for %s: %v", addr, err)
+		} else {
+			n.log.Printf("ERROR: Incorrect fullURL: %s", fullURL)
+		}
+		metrics.RecordError(errorMetric)
 		return err
 	}
-	req = req.WithContext(ctx)
+	fullURL = request.url
 
-	n.log.Printf("Sending %s created notification to %s", n.notifyType, fullURL)
+	n.log.Printf("Sending %s %s notification to %s", n.notifyType, actionPast, fullURL)
+	var attempt notificationAttempt
+	defer func() {
+		recordNotificationAttempt(ctx, addr, attempt)
+	}()
 	retryChan := make(chan int, 1)
 	retryChan <- 1
 	for {
 		select {
 		case i := <-retryChan:
-			resp, err := http.DefaultClient.Do(req)
+			attempt.Attempts = i
+			req, err := request.newHTTPRequest(ctx)
+			if err != nil {
+				n.log.Printf("ERROR: %v", err)
+				metrics.RecordError(errorMetric)
+				return err
+			}
+			resp, err := n.client(eventType).Do(req)
 			if err != nil {
 				if strings.Contains(err.Error(), "context") {
-					n.log.Printf("Canceling %s create notification to %s", n.notifyType, fullURL)
+					n.log.Printf("Canceling %s %s notification to %s", n.notifyType, action, fullURL)
+					attempt.Canceled = true
 					return nil
 				}
 				if i <= n.retries && n.interval > 0 {
-					n.log.Printf("Retrying %s created notification to %s (%d try)", n.notifyType, fullURL, i)
+					n.log.Printf("Retrying %s %s notification to %s (%d try)", n.notifyType, actionPast, fullURL, i)
 					time.Sleep(time.Second * time.Duration(n.interval))
 					retryChan <- i + 1
 					continue
 				} else {
 					n.log.Printf("ERROR: %v", err)
-					metrics.RecordError(n.createErrorMetric)
+					metrics.RecordError(errorMetric)
 					return err
 				}
 			}
 			defer resp.Body.Close()
+			attempt.StatusCode = resp.StatusCode
 
-			if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusConflict {
+			if resp.StatusCode == http.StatusOK ||
+				(eventType == EventTypeCreate && resp.StatusCode == http.StatusConflict) {
 				return nil
 			} else if i <= n.retries && n.interval > 0 {
-				n.log.Printf("Retrying %s created notification to %s (%d try)", n.notifyType, fullURL, i)
+				n.log.Printf("Retrying %s %s notification to %s (%d try)", n.notifyType, actionPast, fullURL, i)
 				time.Sleep(time.Second * time.Duration(n.interval))
 				retryChan <- i + 1
 				continue
-			} else if resp.StatusCode == http.StatusConflict || resp.StatusCode != http.StatusOK {
-				body, err := ioutil.ReadAll(resp.Body)
-				if err != nil {
-					err = fmt.Errorf("Failed at retrying request to %s returned status code %d", fullURL, resp.StatusCode)
-					n.log.Printf("ERROR: %v", err)
-					metrics.RecordError(n.createErrorMetric)
-					return err
-				}
-				err = fmt.Errorf("Failed at retrying request to %s returned status code %d\n%s", fullURL, resp.StatusCode, string(body[:]))
+			}
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				err = fmt.Errorf("Failed at retrying request to %s returned status code %d", fullURL, resp.StatusCode)
 				n.log.Printf("ERROR: %v", err)
-				metrics.RecordError(n.createErrorMetric)
+				metrics.RecordError(errorMetric)
 				return err
 			}
-			err = fmt.Errorf("Failed at retrying request to %s returned status code %d", fullURL, resp.StatusCode)
+			err = fmt.Errorf("Failed at retrying request to %s returned status code %d\n%s", fullURL, resp.StatusCode, string(body[:]))
 			n.log.Printf("ERROR: %v", err)
-			metrics.RecordError(n.createErrorMetric)
+			metrics.RecordError(errorMetric)
 			return err
 		case <-ctx.Done():
-			n.log.Printf("Canceling %s create notification to %s", n.notifyType, fullURL)
+			n.log.Printf("Canceling %s %s notification to %s", n.notifyType, action, fullURL)
+			attempt.Canceled = true
 			return nil
 		}
+	}
+}
 
+// requestTemplate returns the request template of `eventType`
+func (n Notifier) requestTemplate(eventType EventType) *RequestTemplate {
+	if eventType == EventTypeRemove {
+		return n.removeTemplate
 	}
+	return n.createTemplate
 }
 
-// Remove sends remove notifications to listeners
-func (n Notifier) Remove(ctx context.Context, params string) error {
-	if len(n.removeAddr) == 0 {
-		return nil
+// client returns the http client of `eventType`
+func (n Notifier) client(eventType EventType) *http.Client {
+	client := n.createClient
+	if eventType == EventTypeRemove {
+		client = n.removeClient
 	}
+	if client == nil {
+		return http.DefaultClient
+	}
+	return client
+}
 
-	urlObj, err := url.Parse(n.removeAddr)
-	if err != nil {
-		n.log.Printf("ERROR: %v", err)
-		metrics.RecordError(n.removeErrorMetric)
-		return err
+// notificationRequest is a rendered notification request
+type notificationRequest struct {
+	method string
+	url    string
+	header http.Header
+	body   []byte
+}
+
+// newHTTPRequest creates an http request from the rendered request
+func (r notificationRequest) newHTTPRequest(ctx context.Context) (*http.Request, error) {
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
 	}
-	urlObj.RawQuery = params
-	fullURL := urlObj.String()
-	req, err := http.NewRequest(http.MethodGet, fullURL, nil)
+	req, err := http.NewRequest(r.method, r.url, body)
 	if err != nil {
-		n.log.Printf("ERROR: Incorrect fullURL: %s", fullURL)
-		metrics.RecordError(n.removeErrorMetric)
-		return err
+		return nil, err
 	}
-	req = req.WithContext(ctx)
-
-	n.log.Printf("Sending %s removed notification to %s", n.notifyType, fullURL)
-	retryChan := make(chan int, 1)
-	retryChan <- 1
-	for {
-		select {
-		case i := <-retryChan:
-			resp, err := http.DefaultClient.Do(req)
-			if err != nil {
-				if strings.Contains(err.Error(), "context") {
-					n.log.Printf("Canceling %s remove notification to %s", n.notifyType, fullURL)
-					return nil
-				}
-				if i <= n.retries && n.interval > 0 {
-					n.log.Printf("Retrying %s removed notification to %s (%d try)", n.notifyType, fullURL, i)
-					time.Sleep(time.Second * time.Duration(n.interval))
-					retryChan <- i + 1
-					continue
-				} else {
-					n.log.Printf("ERROR: %v", err)
-					metrics.RecordError(n.removeErrorMetric)
-					return err
-				}
-			}
-			defer resp.Body.Close()
+	for k, v := range r.header {
+		req.Header[k] = append([]string(nil), v...)
+	}
+	return req.WithContext(ctx), nil
+}
 
-			if resp.StatusCode == http.StatusOK {
-				return nil
-			} else if i <= n.retries && n.interval > 0 {
-				n.log.Printf("Retrying %s removed notification to %s (%d try)", n.notifyType, fullURL, i)
-				time.Sleep(time.Second * time.Duration(n.interval))
-				retryChan <- i + 1
-				continue
-			} else {
-				body, err := ioutil.ReadAll(resp.Body)
-				if err != nil {
-					err = fmt.Errorf("Failed at retrying request to %s returned status code %d", fullURL, resp.StatusCode)
-					n.log.Printf("ERROR: %v", err)
-					metrics.RecordError(n.removeErrorMetric)
-					return err
-				}
-				err = fmt.Errorf("Failed at retrying request to %s returned status code %d\n%s", fullURL, resp.StatusCode, string(body[:]))
-				n.log.Printf("ERROR: %v", err)
-				metrics.RecordError(n.removeErrorMetric)
-				return err
-			}
-		case <-ctx.Done():
-			n.log.Printf("Canceling %s remove notification to %s", n.notifyType, fullURL)
-			return nil
+// renderRequest renders the notification request in the format of
+// `Notifier`. When the notifier has a request template for `eventType`, the
+// request is rendered from the template instead
+func (n Notifier) renderRequest(ctx context.Context, eventType EventType, httpAddr string, fullURL string, params string) (notificationRequest, error) {
+	request := notificationRequest{
+		method: http.MethodGet,
+		url:    fullURL,
+		header: http.Header{},
+	}
+	if tmpl := n.requestTemplate(eventType); tmpl != nil {
+		rendered, err := tmpl.Render(ctx, eventType, httpAddr, params)
+		if err != nil {
+			return notificationRequest{}, err
 		}
+		request.method = rendered.Method
+		request.url = rendered.URL
+		request.body = []byte(rendered.Body)
+		for k, v := range rendered.Headers {
+			request.header.Set(k, v)
+		}
+	} else if n.format == NotificationFormatCloudEventsBinary ||
+		n.format == NotificationFormatCloudEventsStructured {
+		notification, _ := notificationFromContext(ctx)
+		event, err := NewCloudEvent(n.notifyType, eventType, n.source, notification, params)
+		if err != nil {
+			return notificationRequest{}, err
+		}
+		if n.format == NotificationFormatCloudEventsStructured {
+			request.body, err = json.Marshal(event)
+			request.header.Set("Content-Type", "application/cloudevents+json")
+		} else {
+			request.body, err = json.Marshal(event.Data)
+			event.SetHeaders(request.header)
+		}
+		if err != nil {
+			return notificationRequest{}, err
+		}
+		request.method = http.MethodPost
+	}
+	// Invalid methods or URLs are reported before the first attempt
+	if _, err := request.newHTTPRequest(ctx); err != nil {
+		return notificationRequest{}, err
 	}
+	return request, nil
 }
@@ -3,11 +3,16 @@ package service
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -276,6 +281,209 @@ func (s *NotifierTestSuite) Test_Remove_Cancels() {
 	s.Contains(logMsgs, expMsg)
 }
 
+// CloudEvents
+
+func (s *NotifierTestSuite) Test_Create_CloudEventsBinary_SendsEventInHeaders() {
+	var method, query string
+	var header http.Header
+	var body map[string]string
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		query = r.URL.RawQuery
+		header = r.Header
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer httpSrv.Close()
+
+	n := NewFormattedNotifier(
+		httpSrv.URL, "", "service", NotificationFormatCloudEventsBinary, "cluster1", 1, 0, s.Logger)
+	ctx := contextWithNotification(context.Background(), Notification{
+		EventType: EventTypeCreate, ID: "serviceID1", TimeNano: 1000000000,
+	})
+	err := n.Create(ctx, s.Params)
+	s.Require().NoError(err)
+
+	s.Equal("POST", method)
+	s.Empty(query)
+	s.Equal("application/json", header.Get("Content-Type"))
+	s.Equal("1.0", header.Get("ce-specversion"))
+	s.Equal("com.dockerflow.swarm.service.created", header.Get("ce-type"))
+	s.Equal("cluster1", header.Get("ce-source"))
+	s.Equal("serviceID1-1000000000", header.Get("ce-id"))
+	s.Equal("1970-01-01T00:00:01Z", header.Get("ce-time"))
+	s.Equal(map[string]string{"serviceName": "hello"}, body)
+}
+
+func (s *NotifierTestSuite) Test_Remove_CloudEventsStructured_SendsEventInBody() {
+	var method string
+	var header http.Header
+	var event CloudEvent
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		header = r.Header
+		json.NewDecoder(r.Body).Decode(&event)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer httpSrv.Close()
+
+	n := NewFormattedNotifier(
+		"", httpSrv.URL, "node", NotificationFormatCloudEventsStructured, "cluster1", 1, 0, s.Logger)
+	ctx := contextWithNotification(context.Background(), Notification{
+		EventType: EventTypeRemove, ID: "nodeID1", TimeNano: 1000000000,
+	})
+	err := n.Remove(ctx, s.Params)
+	s.Require().NoError(err)
+
+	s.Equal("POST", method)
+	s.Equal("application/cloudevents+json", header.Get("Content-Type"))
+	s.Equal(CloudEvent{
+		SpecVersion:     "1.0",
+		Type:            "com.dockerflow.swarm.node.removed",
+		Source:          "cluster1",
+		ID:              "nodeID1-1000000000",
+		Time:            "1970-01-01T00:00:01Z",
+		Subject:         "nodeID1",
+		DataContentType: "application/json",
+		Data:            map[string]string{"serviceName": "hello"},
+	}, event)
+}
+
+func (s *NotifierTestSuite) Test_Create_CloudEventsTask_UsesTaskEventType() {
+	var event CloudEvent
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&event)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer httpSrv.Close()
+
+	n := NewFormattedNotifier(
+		httpSrv.URL, "", "task", NotificationFormatCloudEventsStructured, "cluster1", 1, 0, s.Logger)
+	ctx := contextWithNotification(context.Background(), Notification{
+		EventType: EventTypeTaskFailed, ID: "taskID1", TimeNano: 1000000000,
+	})
+	err := n.Create(ctx, s.Params)
+	s.Require().NoError(err)
+
+	s.Equal("com.dockerflow.swarm.task.failed", event.Type)
+	s.Equal("taskID1", event.Subject)
+}
+
+func (s *NotifierTestSuite) Test_Create_CloudEventsUpdate_UsesUpdateEventType() {
+	var event CloudEvent
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&event)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer httpSrv.Close()
+
+	n := NewFormattedNotifier(
+		httpSrv.URL, "", "update", NotificationFormatCloudEventsStructured, "cluster1", 1, 0, s.Logger)
+	ctx := contextWithNotification(context.Background(), Notification{
+		EventType: EventTypeUpdateRollbackCompleted, ID: "serviceID1", TimeNano: 1000000000,
+	})
+	err := n.Create(ctx, s.Params)
+	s.Require().NoError(err)
+
+	s.Equal("com.dockerflow.swarm.update.rollback-completed", event.Type)
+	s.Equal("serviceID1", event.Subject)
+}
+
+// Unix sockets
+
+func (s *NotifierTestSuite) Test_Create_UnixSocket_SendsRequestOverSocket() {
+	tmpDir, err := ioutil.TempDir("", "notifier")
+	s.Require().NoError(err)
+	defer os.RemoveAll(tmpDir)
+	socketPath := filepath.Join(tmpDir, "receiver.sock")
+	listener, err := net.Listen("unix", socketPath)
+	s.Require().NoError(err)
+
+	var path, query string
+	httpSrv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		query = r.URL.RawQuery
+		w.WriteHeader(http.StatusOK)
+	}))
+	httpSrv.Listener = listener
+	httpSrv.Start()
+	defer httpSrv.Close()
+
+	n := NewNotifier(fmt.Sprintf("unix://%s:/v1/reconfigure", socketPath), "", "service", 1, 0, s.Logger)
+	err = n.Create(context.Background(), s.Params)
+	s.Require().NoError(err)
+
+	s.Equal("/v1/reconfigure", path)
+	s.Equal(s.Params, query)
+}
+
+func (s *NotifierTestSuite) Test_Remove_UnixSocketMissing_ReturnsError() {
+	n := NewNotifier("", "unix:///does/not/exist.sock:/v1/remove", "service", 1, 0, s.Logger)
+	err := n.Remove(context.Background(), s.Params)
+	s.Error(err)
+}
+
+// Request templates
+
+func (s *NotifierTestSuite) Test_Create_RequestTemplate_SendsRenderedRequest() {
+	var method, path, contentType string
+	var body map[string]string
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		path = r.URL.Path
+		contentType = r.Header.Get("Content-Type")
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer httpSrv.Close()
+
+	n := NewNotifier(httpSrv.URL+"/v1/reconfigure", "", "service", 1, 0, s.Logger)
+	n.createTemplate = &RequestTemplate{
+		Path:    "/deploy/{{.serviceName}}",
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Body:    `{"name": {{json .serviceName}}, "id": "{{.ID}}"}`,
+	}
+	ctx := contextWithNotification(context.Background(), Notification{
+		EventType: EventTypeCreate, ID: "serviceID1",
+	})
+	err := n.Create(ctx, s.Params)
+	s.Require().NoError(err)
+
+	s.Equal("POST", method)
+	s.Equal("/deploy/hello", path)
+	s.Equal("application/json", contentType)
+	s.Equal(map[string]string{"name": "hello", "id": "serviceID1"}, body)
+}
+
+func (s *NotifierTestSuite) Test_Create_RequestTemplate_RetriesWithSameBody() {
+	bodies := []string{}
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer httpSrv.Close()
+
+	n := NewNotifier(httpSrv.URL, "", "service", 2, 1, s.Logger)
+	n.createTemplate = &RequestTemplate{Body: `{"name": {{json .serviceName}}}`}
+	err := n.Create(context.Background(), s.Params)
+	s.Require().NoError(err)
+
+	s.Equal([]string{`{"name": "hello"}`, `{"name": "hello"}`}, bodies)
+}
+
+func (s *NotifierTestSuite) Test_Remove_RequestTemplateInvalid_ReturnsError() {
+	n := NewNotifier("", "http://localhost:8080", "service", 1, 0, s.Logger)
+	n.removeTemplate = &RequestTemplate{Body: "{{.serviceName.field}}"}
+	err := n.Remove(context.Background(), s.Params)
+	s.Error(err)
+	s.Contains(s.LogBytes.String(), "ERROR: Unable to render request template for http://localhost:8080")
+}
+
 func (s *NotifierTestSuite) EqualURLValues(expected, actual url.Values) {
 	for k := range expected {
 		expV, expA := expected[k], actual[k]
@@ -7,6 +7,8 @@ import (
 	"os"
 	"strings"
 	"sync"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
 )
 
 // Notification is a node notification
@@ -17,6 +19,25 @@ type Notification struct {
 	TimeNano   int64
 	Context    context.Context
 	Done       chan struct{}
+	// Service is the cached service of a service notification
+	Service *SwarmServiceMini
+	// Diff is the change of an updated service
+	Diff *ServiceDiff
+	// Node is the cached node of a node notification
+	Node *NodeMini
+}
+
+type notificationContextKey struct{}
+
+// contextWithNotification returns a copy of `ctx` that carries `n`
+func contextWithNotification(ctx context.Context, n Notification) context.Context {
+	return context.WithValue(ctx, notificationContextKey{}, n)
+}
+
+// notificationFromContext returns the `Notification` carried by `ctx`
+func notificationFromContext(ctx context.Context) (Notification, bool) {
+	n, ok := ctx.Value(notificationContextKey{}).(Notification)
+	return n, ok
 }
 
 type internalNotification struct {
@@ -30,22 +51,33 @@ type NotifyEndpoint struct {
 	ServiceNotifier NotificationSender
 	NodeChan        chan internalNotification
 	NodeNotifier    NotificationSender
+	// Notifiers receive notifications of other types, such as tasks,
+	// configs, and secrets
+	Notifiers map[NotifyType]NotificationSender
 }
 
 // NotifyDistributing takes a stream of `Notification` and
 // NodeNotifiction and distributes it listeners
 type NotifyDistributing interface {
 	Run(serviceChan <-chan Notification, nodeChan <-chan Notification)
+	RunType(notifyType NotifyType, notiChan <-chan Notification)
 	HasServiceListeners() bool
 	HasNodeListeners() bool
+	HasListeners(notifyType NotifyType) bool
+	Subscribe(filter NotificationFilter, fn func(Notification)) func()
 }
 
 // NotifyDistributor distributes service and node notifications to `NotifyEndpoints`
 // `NotifyEndpoints` are keyed by hostname to send notifications to
+// `Sinks` are built-in endpoints, such as templates or Redis, that are not
+// reached through a notification URL
 type NotifyDistributor struct {
 	NotifyEndpoints      map[string]NotifyEndpoint
+	Sinks                []NotifyEndpoint
 	ServiceCancelManager CancelManaging
 	NodeCancelManager    CancelManaging
+	Audit                AuditLogging
+	subscriptions        *subscriptions
 	log                  *log.Logger
 	interval             int
 }
@@ -57,12 +89,19 @@ func newNotifyDistributor(notifyEndpoints map[string]NotifyEndpoint,
 		NotifyEndpoints:      notifyEndpoints,
 		ServiceCancelManager: serviceCancelManager,
 		NodeCancelManager:    nodeCancelManager,
+		subscriptions:        newSubscriptions(),
 		interval:             interval,
 		log:                  logger,
 	}
 }
 
 func newNotifyDistributorfromStrings(serviceCreateAddrs, serviceRemoveAddrs, nodeCreateAddrs, nodeRemoveAddrs string, retries, interval int, logger *log.Logger) *NotifyDistributor {
+	return newFormattedNotifyDistributorfromStrings(
+		serviceCreateAddrs, serviceRemoveAddrs, nodeCreateAddrs, nodeRemoveAddrs,
+		NotificationFormatQuery, "", retries, interval, logger)
+}
+
+func newFormattedNotifyDistributorfromStrings(serviceCreateAddrs, serviceRemoveAddrs, nodeCreateAddrs, nodeRemoveAddrs string, format NotificationFormat, source string, retries, interval int, logger *log.Logger) *NotifyDistributor {
 	tempNotifyEP := map[string]map[string]string{}
 
 	insertAddrStringIntoMap(tempNotifyEP, "createService", serviceCreateAddrs)
@@ -76,10 +115,12 @@ func newNotifyDistributorfromStrings(serviceCreateAddrs, serviceRemoveAddrs, nod
 		ep := NotifyEndpoint{}
 		if len(addrMap["createService"]) > 0 || len(addrMap["removeService"]) > 0 {
 			ep.ServiceChan = make(chan internalNotification)
-			ep.ServiceNotifier = NewNotifier(
+			ep.ServiceNotifier = NewFormattedNotifier(
 				addrMap["createService"],
 				addrMap["removeService"],
 				"service",
+				format,
+				source,
 				retries,
 				interval,
 				logger,
@@ -87,10 +128,12 @@ func newNotifyDistributorfromStrings(serviceCreateAddrs, serviceRemoveAddrs, nod
 		}
 		if len(addrMap["createNode"]) > 0 || len(addrMap["removeNode"]) > 0 {
 			ep.NodeChan = make(chan internalNotification)
-			ep.NodeNotifier = NewNotifier(
+			ep.NodeNotifier = NewFormattedNotifier(
 				addrMap["createNode"],
 				addrMap["removeNode"],
 				"node",
+				format,
+				source,
 				retries,
 				interval,
 				logger,
@@ -114,6 +157,10 @@ func insertAddrStringIntoMap(tempEP map[string]map[string]string, key, addrs str
 			continue
 		}
 		host := urlObj.Host
+		// Unix socket endpoints are identified by their socket path
+		if socketPath, _, ok := splitUnixSocketAddr(v); ok {
+			host = socketPath
+		}
 		if len(host) == 0 {
 			continue
 		}
@@ -124,29 +171,59 @@ func insertAddrStringIntoMap(tempEP map[string]map[string]string, key, addrs str
 	}
 }
 
-// NewNotifyDistributorFromEnv creates `NotifyDistributor` from environment variables
-func NewNotifyDistributorFromEnv(retries, interval int, logger *log.Logger) *NotifyDistributor {
-	var createServiceAddr, removeServiceAddr string
+// serviceNotifyAddrsFromEnv returns the comma separated create and remove
+// addresses of service notifications
+// `DF_NOTIF_*` and `DF_NOTIFY_*` variables take precedence over
+// `DF_NOTIFICATION_URL`
+func serviceNotifyAddrsFromEnv() (createAddrs, removeAddrs string) {
 	if len(os.Getenv("DF_NOTIF_CREATE_SERVICE_URL")) > 0 {
-		createServiceAddr = os.Getenv("DF_NOTIF_CREATE_SERVICE_URL")
+		createAddrs = os.Getenv("DF_NOTIF_CREATE_SERVICE_URL")
 	} else if len(os.Getenv("DF_NOTIFY_CREATE_SERVICE_URL")) > 0 {
-		createServiceAddr = os.Getenv("DF_NOTIFY_CREATE_SERVICE_URL")
+		createAddrs = os.Getenv("DF_NOTIFY_CREATE_SERVICE_URL")
 	} else {
-		createServiceAddr = os.Getenv("DF_NOTIFICATION_URL")
+		createAddrs = os.Getenv("DF_NOTIFICATION_URL")
 	}
 	if len(os.Getenv("DF_NOTIF_REMOVE_SERVICE_URL")) > 0 {
-		removeServiceAddr = os.Getenv("DF_NOTIF_REMOVE_SERVICE_URL")
+		removeAddrs = os.Getenv("DF_NOTIF_REMOVE_SERVICE_URL")
 	} else if len(os.Getenv("DF_NOTIFY_REMOVE_SERVICE_URL")) > 0 {
-		removeServiceAddr = os.Getenv("DF_NOTIFY_REMOVE_SERVICE_URL")
+		removeAddrs = os.Getenv("DF_NOTIFY_REMOVE_SERVICE_URL")
 	} else {
-		removeServiceAddr = os.Getenv("DF_NOTIFICATION_URL")
+		removeAddrs = os.Getenv("DF_NOTIFICATION_URL")
 	}
-	createNodeAddr := os.Getenv("DF_NOTIFY_CREATE_NODE_URL")
-	removeNodeAddr := os.Getenv("DF_NOTIFY_REMOVE_NODE_URL")
+	return createAddrs, removeAddrs
+}
 
-	return newNotifyDistributorfromStrings(
-		createServiceAddr, removeServiceAddr, createNodeAddr, removeNodeAddr, retries, interval, logger)
+// addEndpoints adds notifiers of `notifyType` for the comma separated
+// `createAddrs` and `removeAddrs`
+func (d *NotifyDistributor) addEndpoints(notifyType NotifyType, createAddrs, removeAddrs string, format NotificationFormat, source string, retries, interval int, logger *log.Logger) {
+	tempNotifyEP := map[string]map[string]string{}
+	insertAddrStringIntoMap(tempNotifyEP, "create", createAddrs)
+	insertAddrStringIntoMap(tempNotifyEP, "remove", removeAddrs)
+	for hostname, addrMap := range tempNotifyEP {
+		ep := d.NotifyEndpoints[hostname]
+		if ep.Notifiers == nil {
+			ep.Notifiers = map[NotifyType]NotificationSender{}
+		}
+		ep.Notifiers[notifyType] = NewFormattedNotifier(
+			addrMap["create"], addrMap["remove"], string(notifyType),
+			format, source, retries, interval, logger)
+		d.NotifyEndpoints[hostname] = ep
+	}
+}
 
+// AddSink adds a built-in endpoint that receives notifications alongside
+// `NotifyEndpoints`
+func (d *NotifyDistributor) AddSink(endpoint NotifyEndpoint) {
+	d.Sinks = append(d.Sinks, endpoint)
+}
+
+// endpoints returns `NotifyEndpoints` and `Sinks`
+func (d NotifyDistributor) endpoints() []NotifyEndpoint {
+	endpoints := make([]NotifyEndpoint, 0, len(d.NotifyEndpoints)+len(d.Sinks))
+	for _, endpoint := range d.NotifyEndpoints {
+		endpoints = append(endpoints, endpoint)
+	}
+	return append(endpoints, d.Sinks...)
 }
 
 // Run starts the distributor
@@ -155,8 +232,10 @@ func (d NotifyDistributor) Run(serviceChan <-chan Notification, nodeChan <-chan
 	if serviceChan != nil {
 		go func() {
 			for n := range serviceChan {
+				d.recordAudit(newEventAuditEntry("service", n))
 				// Use time as request id
-				ctx := d.ServiceCancelManager.Add(context.Background(), n.ID, n.TimeNano)
+				ctx := d.ServiceCancelManager.Add(
+					d.notificationContext(n), n.ID, n.TimeNano)
 				go d.distributeServiceNotification(ctx, n)
 			}
 		}()
@@ -164,26 +243,87 @@ func (d NotifyDistributor) Run(serviceChan <-chan Notification, nodeChan <-chan
 	if nodeChan != nil {
 		go func() {
 			for n := range nodeChan {
+				d.recordAudit(newEventAuditEntry("node", n))
 				// Use time as request id
-				ctx := d.NodeCancelManager.Add(context.Background(), n.ID, n.TimeNano)
+				ctx := d.NodeCancelManager.Add(
+					d.notificationContext(n), n.ID, n.TimeNano)
 				go d.distributeNodeNotification(ctx, n)
 			}
 		}()
 	}
 }
 
+// RunType starts distributing notifications of `notifyType` to the
+// `Notifiers` of the endpoints
+func (d NotifyDistributor) RunType(notifyType NotifyType, notiChan <-chan Notification) {
+	if notiChan == nil {
+		return
+	}
+	go func() {
+		for n := range notiChan {
+			d.recordAudit(newEventAuditEntry(string(notifyType), n))
+			go d.distributeNotification(d.notificationContext(n), notifyType, n)
+		}
+	}()
+}
+
+// notificationContext returns the root context of notification `n`
+func (d NotifyDistributor) notificationContext(n Notification) context.Context {
+	ctx := contextWithNotification(context.Background(), n)
+	if d.Audit != nil {
+		ctx = contextWithNotificationAttempts(ctx, newNotificationAttempts())
+	}
+	return ctx
+}
+
+// newEventAuditEntry creates the audit entry of an observed change
+func newEventAuditEntry(notifyType string, n Notification) AuditEntry {
+	return AuditEntry{
+		Kind:   AuditKindEvent,
+		Type:   notifyType,
+		Event:  n.EventType,
+		ID:     n.ID,
+		Params: n.Parameters,
+	}
+}
+
+// recordAudit records `entry` when the audit log is enabled
+func (d NotifyDistributor) recordAudit(entry AuditEntry) {
+	if d.Audit == nil {
+		return
+	}
+	if err := d.Audit.Record(entry); err != nil {
+		d.log.Printf("ERROR: Unable to record audit entry: %v", err)
+		metrics.RecordError("auditRecord")
+	}
+}
+
+// recordNotificationAudit records the outcome of sending `n` to the
+// address returned by `getAddr` when the audit log is enabled
+func (d NotifyDistributor) recordNotificationAudit(
+	ctx context.Context, notifyType string, n Notification, getAddr func() string, err error) {
+	if d.Audit == nil {
+		return
+	}
+	d.recordAudit(newNotificationAuditEntry(ctx, notifyType, n, getAddr(), err))
+}
+
 func (d NotifyDistributor) distributeServiceNotification(
 	ctx context.Context, n Notification) {
 	defer d.ServiceCancelManager.Delete(n.ID, n.TimeNano)
 	var wg sync.WaitGroup
 
-	for _, endpoint := range d.NotifyEndpoints {
+	for _, endpoint := range d.endpoints() {
+		if endpoint.ServiceNotifier == nil {
+			continue
+		}
 		wg.Add(1)
 		go func(endpoint NotifyEndpoint) {
 			defer wg.Done()
 			d.processServiceNotification(ctx, n, endpoint)
 		}(endpoint)
 	}
+	d.subscriptions.publish(ctx, NotifyTypeService, n)
 	wg.Wait()
 
 	if n.Done != nil {
@@ -196,13 +336,50 @@ func (d NotifyDistributor) distributeNodeNotification(
 	defer d.NodeCancelManager.Delete(n.ID, n.TimeNano)
 	var wg sync.WaitGroup
 
-	for _, endpoint := range d.NotifyEndpoints {
+	for _, endpoint := range d.endpoints() {
+		if endpoint.NodeNotifier == nil {
+			continue
+		}
 		wg.Add(1)
 		go func(endpoint NotifyEndpoint) {
 			defer wg.Done()
 			d.processNodeNotification(ctx, n, endpoint)
 		}(endpoint)
 	}
+	d.subscriptions.publish(ctx, NotifyTypeNode, n)
+	wg.Wait()
+	if n.Done != nil {
+		n.Done <- struct{}{}
+	}
+}
+
+// distributeNotification sends `n` to the notifiers of `notifyType`
+// Remove events are sent with `Remove`, all other events with `Create`
+func (d NotifyDistributor) distributeNotification(
+	ctx context.Context, notifyType NotifyType, n Notification) {
+	var wg sync.WaitGroup
+
+	for _, endpoint := range d.endpoints() {
+		notifier, ok := endpoint.Notifiers[notifyType]
+		if !ok || notifier == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(notifier NotificationSender) {
+			defer wg.Done()
+			send, getAddr := notifier.Create, notifier.GetCreateAddr
+			if n.EventType == EventTypeRemove {
+				send, getAddr = notifier.Remove, notifier.GetRemoveAddr
+			}
+			err := send(ctx, n.Parameters)
+			d.recordNotificationAudit(ctx, string(notifyType), n, getAddr, err)
+			if err != nil {
+				d.log.Printf("ERROR: Unable to send %s %s notification to %s, params: %s",
+					notifyType, n.EventType, getAddr(), n.Parameters)
+			}
+		}(notifier)
+	}
+	d.subscriptions.publish(ctx, notifyType, n)
 	wg.Wait()
 	if n.Done != nil {
 		n.Done <- struct{}{}
@@ -212,14 +389,18 @@ func (d NotifyDistributor) distributeNodeNotification(
 func (d NotifyDistributor) processServiceNotification(
 	ctx context.Context, n Notification, endpoint NotifyEndpoint) {
 
-	if n.EventType == EventTypeCreate {
+	if n.EventType != EventTypeRemove {
 		err := endpoint.ServiceNotifier.Create(ctx, n.Parameters)
+		d.recordNotificationAudit(
+			ctx, "service", n, endpoint.ServiceNotifier.GetCreateAddr, err)
 		if err != nil && !strings.Contains(err.Error(), "context canceled") {
 			d.log.Printf("ERROR: Unable to send ServiceCreateNotify to %s, params: %s", endpoint.ServiceNotifier.GetCreateAddr(), n.Parameters)
 		}
-	} else if n.EventType == EventTypeRemove {
+	} else {
 		err := endpoint.ServiceNotifier.Remove(ctx, n.Parameters)
 		d.ServiceCancelManager.Delete(n.ID, n.TimeNano)
+		d.recordNotificationAudit(
+			ctx, "service", n, endpoint.ServiceNotifier.GetRemoveAddr, err)
 		if err != nil && !strings.Contains(err.Error(), "context canceled") {
 			d.log.Printf("ERROR: Unable to send ServiceRemoveNotify to %s, params: %s", endpoint.ServiceNotifier.GetRemoveAddr(), n.Parameters)
 		}
@@ -231,6 +412,8 @@ func (d NotifyDistributor) processNodeNotification(
 	if n.EventType == EventTypeCreate {
 		err := endpoint.NodeNotifier.Create(ctx, n.Parameters)
 		d.NodeCancelManager.Delete(n.ID, n.TimeNano)
+		d.recordNotificationAudit(
+			ctx, "node", n, endpoint.NodeNotifier.GetCreateAddr, err)
 		if err != nil {
 			d.log.Printf("ERROR: Unable to send NodeCreateNotify to %s, params: %s",
 				endpoint.NodeNotifier.GetCreateAddr(), n.Parameters)
@@ -238,6 +421,8 @@ func (d NotifyDistributor) processNodeNotification(
 	} else if n.EventType == EventTypeRemove {
 		err := endpoint.NodeNotifier.Remove(ctx, n.Parameters)
 		d.NodeCancelManager.Delete(n.ID, n.TimeNano)
+		d.recordNotificationAudit(
+			ctx, "node", n, endpoint.NodeNotifier.GetRemoveAddr, err)
 		if err != nil {
 			d.log.Printf("ERROR: Unable to send NodeRemoveNotify to %s, params: %s",
 				endpoint.NodeNotifier.GetRemoveAddr(), n.Parameters)
@@ -247,7 +432,10 @@ func (d NotifyDistributor) processNodeNotification(
 
 // HasServiceListeners when there exists service listeners
 func (d NotifyDistributor) HasServiceListeners() bool {
-	for _, endpoint := range d.NotifyEndpoints {
+	if d.subscriptions.has(NotifyTypeService) {
+		return true
+	}
+	for _, endpoint := range d.endpoints() {
 		if endpoint.ServiceNotifier != nil {
 			return true
 		}
@@ -257,10 +445,38 @@ func (d NotifyDistributor) HasServiceListeners() bool {
 
 // HasNodeListeners when there exists node listeners
 func (d NotifyDistributor) HasNodeListeners() bool {
-	for _, endpoint := range d.NotifyEndpoints {
+	if d.subscriptions.has(NotifyTypeNode) {
+		return true
+	}
+	for _, endpoint := range d.endpoints() {
 		if endpoint.NodeNotifier != nil {
 			return true
 		}
 	}
 	return false
 }
+
+// HasListeners when there exists listeners of `notifyType`
+func (d NotifyDistributor) HasListeners(notifyType NotifyType) bool {
+	if d.subscriptions.has(notifyType) {
+		return true
+	}
+	for _, endpoint := range d.endpoints() {
+		if endpoint.Notifiers[notifyType] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscribe calls `fn` with every notification that matches `filter`
+// Subscribers receive the same create and remove notifications as
+// `NotifyEndpoints` and should return quickly. The returned function
+// removes the subscription. Subscriptions must be added before the listener
+// runs, since notifications without listeners are not produced
+func (d *NotifyDistributor) Subscribe(filter NotificationFilter, fn func(Notification)) func() {
+	if d.subscriptions == nil {
+		d.subscriptions = newSubscriptions()
+	}
+	return d.subscriptions.add(filter, fn)
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"log"
-	"os"
 	"testing"
 	"time"
 
@@ -164,115 +163,28 @@ func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromStrings_JustNo
 	s.True(notifyD.HasNodeListeners())
 }
 
-func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromEnv_ServiceCreate() {
-	envKeys := []string{"DF_NOTIFY_CREATE_SERVICE_URL",
-		"DF_NOTIF_CREATE_SERVICE_URL",
-		"DF_NOTIFICATION_URL"}
-	for _, envKey := range envKeys {
-		oldHost := os.Getenv(envKey)
-		os.Setenv(envKey, "http://host1,http://host2")
-
-		notifyD := NewNotifyDistributorFromEnv(5, 10, s.log)
-
-		if notifyD == nil {
-			s.Fail("%s returns nil", envKey)
-			os.Setenv(envKey, oldHost)
-			continue
-		}
-
-		s.Len(notifyD.NotifyEndpoints, 2)
-
-		ep1, ok1 := notifyD.NotifyEndpoints["host1"]
-		s.True(ok1)
-
-		if ep1.ServiceNotifier == nil {
-			s.Fail("%s nil host1 ServiceNotifier", envKey)
-			os.Setenv(envKey, oldHost)
-			continue
-		}
-
-		s.Equal("http://host1", ep1.ServiceNotifier.GetCreateAddr())
-
-		ep2, ok2 := notifyD.NotifyEndpoints["host2"]
-		s.True(ok2)
-
-		if ep2.ServiceNotifier == nil {
-			s.Fail("%s is nil host2 ServiceNotifier", envKey)
-			os.Setenv(envKey, oldHost)
-			continue
-		}
-		s.Equal("http://host2", ep2.ServiceNotifier.GetCreateAddr())
-		os.Setenv(envKey, oldHost)
-	}
-}
-
-func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromEnv_ServiceRemove() {
-	envKeys := []string{"DF_NOTIFY_REMOVE_SERVICE_URL",
-		"DF_NOTIF_REMOVE_SERVICE_URL",
-		"DF_NOTIFICATION_URL"}
-	for _, envKey := range envKeys {
-		oldHost := os.Getenv(envKey)
-		os.Setenv(envKey, "http://host1,http://host2")
-
-		notifyD := NewNotifyDistributorFromEnv(5, 10, s.log)
-
-		if notifyD == nil {
-			s.Fail("%s returns nil", envKey)
-			os.Setenv(envKey, oldHost)
-			continue
-		}
-
-		s.Len(notifyD.NotifyEndpoints, 2)
-
-		ep1, ok1 := notifyD.NotifyEndpoints["host1"]
-		s.True(ok1)
-
-		if ep1.ServiceNotifier == nil {
-			s.Fail("%s nil host1 ServiceNotifier", envKey)
-			os.Setenv(envKey, oldHost)
-			continue
-		}
-
-		s.Equal("http://host1", ep1.ServiceNotifier.GetRemoveAddr())
-
-		ep2, ok2 := notifyD.NotifyEndpoints["host2"]
-		s.True(ok2)
-
-		if ep2.ServiceNotifier == nil {
-			s.Fail("%s nil host2 ServiceNotifier", envKey)
-			os.Setenv(envKey, oldHost)
-			continue
-		}
-		s.Equal("http://host2", ep2.ServiceNotifier.GetRemoveAddr())
-		os.Setenv(envKey, oldHost)
-	}
-}
-
-func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromEnv_Node() {
-	defer func() {
-		os.Unsetenv("DF_NOTIFY_CREATE_NODE_URL")
-		os.Unsetenv("DF_NOTIFY_REMOVE_NODE_URL")
-	}()
-	os.Setenv("DF_NOTIFY_CREATE_NODE_URL", "http://host1/create,http://host2/create")
-	os.Setenv("DF_NOTIFY_REMOVE_NODE_URL", "http://host1/remove,http://host2/remove")
-
-	notifyD := NewNotifyDistributorFromEnv(5, 10, s.log)
-	s.Require().NotNil(notifyD)
+func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromStrings_UnixSocketListeners() {
+	notifyD := newNotifyDistributorfromStrings(
+		"unix:///run/receiver.sock:/reconfigure",
+		"unix:///run/receiver.sock:/remove",
+		"unix:///run/other.sock:/node",
+		"",
+		5, 10, s.log)
 
 	s.Len(notifyD.NotifyEndpoints, 2)
-	ep1, ok1 := notifyD.NotifyEndpoints["host1"]
-	s.True(ok1)
-
-	s.Require().NotNil(ep1.NodeNotifier)
-	s.Equal("http://host1/create", ep1.NodeNotifier.GetCreateAddr())
-	s.Equal("http://host1/remove", ep1.NodeNotifier.GetRemoveAddr())
-
-	ep2, ok2 := notifyD.NotifyEndpoints["host2"]
-	s.True(ok2)
+	receiverEP, ok := notifyD.NotifyEndpoints["/run/receiver.sock"]
+	s.Require().True(ok)
+	s.AssertEndpoints(
+		receiverEP,
+		"unix:///run/receiver.sock:/reconfigure",
+		"unix:///run/receiver.sock:/remove",
+		"",
+		"",
+	)
 
-	s.Require().NotNil(ep2.NodeNotifier)
-	s.Equal("http://host2/create", ep2.NodeNotifier.GetCreateAddr())
-	s.Equal("http://host2/remove", ep2.NodeNotifier.GetRemoveAddr())
+	otherEP, ok := notifyD.NotifyEndpoints["/run/other.sock"]
+	s.Require().True(ok)
+	s.AssertEndpoints(otherEP, "", "", "unix:///run/other.sock:/node", "")
 }
 
 func (s *NotifyDistributorTestSuite) Test_RunDistributesNotificationsToEndpoints_Servies() {
@@ -435,6 +347,280 @@ func (s *NotifyDistributorTestSuite) Test_RunDistributesNotificationsToEndpoints
 	nodesNotifyMock2.AssertExpectations(s.T())
 }
 
+func (s *NotifyDistributorTestSuite) Test_RunDistributesNotificationsToEndpoints_SkipsEndpointsWithoutNotifier() {
+	serviceDone := make(chan struct{})
+
+	serviceNotifyMock := notificationSenderMock{}
+	serviceNotifyMock.On("Create", mock.AnythingOfType("*context.cancelCtx"), "hello=world").
+		Return(nil)
+	nodeNotifyMock := notificationSenderMock{}
+
+	endpoints := map[string]NotifyEndpoint{
+		"host1": {
+			ServiceChan:     make(chan internalNotification),
+			ServiceNotifier: &serviceNotifyMock,
+		},
+		"host2": {
+			NodeChan:     make(chan internalNotification),
+			NodeNotifier: &nodeNotifyMock,
+		},
+	}
+
+	notifyD := newNotifyDistributor(endpoints, NewCancelManager(true),
+		NewCancelManager(true), 1, s.log)
+	serviceChan := make(chan Notification)
+
+	notifyD.Run(serviceChan, nil)
+
+	go func() {
+		serviceChan <- Notification{
+			EventType:  EventTypeCreate,
+			ID:         "sid1",
+			Parameters: "hello=world",
+			TimeNano:   int64(1),
+			Context:    s.ctx,
+			Done:       serviceDone,
+		}
+	}()
+
+	timer := time.NewTimer(time.Second * 5).C
+
+	select {
+	case <-serviceDone:
+	case <-timer:
+		s.Fail("Timeout")
+		return
+	}
+
+	serviceNotifyMock.AssertExpectations(s.T())
+	nodeNotifyMock.AssertExpectations(s.T())
+}
+
+func (s *NotifyDistributorTestSuite) Test_RunDistributesNotificationsToSinks() {
+	serviceDone := make(chan struct{})
+
+	endpointNotifyMock := notificationSenderMock{}
+	endpointNotifyMock.On("Create", mock.AnythingOfType("*context.cancelCtx"), "hello=world").
+		Return(nil)
+	sinkNotifyMock := notificationSenderMock{}
+	sinkNotifyMock.On("Create", mock.AnythingOfType("*context.cancelCtx"), "hello=world").
+		Return(nil)
+
+	// The endpoint has the host the consul sink used to be keyed by
+	endpoints := map[string]NotifyEndpoint{
+		"consul": {
+			ServiceChan:     make(chan internalNotification),
+			ServiceNotifier: &endpointNotifyMock,
+		},
+	}
+	notifyD := newNotifyDistributor(endpoints, NewCancelManager(true),
+		NewCancelManager(true), 1, s.log)
+	notifyD.AddSink(NotifyEndpoint{
+		ServiceChan:     make(chan internalNotification),
+		ServiceNotifier: &sinkNotifyMock,
+	})
+	s.Len(notifyD.NotifyEndpoints, 1)
+	s.Len(notifyD.Sinks, 1)
+	s.True(notifyD.HasServiceListeners())
+
+	serviceChan := make(chan Notification)
+	notifyD.Run(serviceChan, nil)
+
+	go func() {
+		serviceChan <- Notification{
+			EventType:  EventTypeCreate,
+			ID:         "sid1",
+			Parameters: "hello=world",
+			TimeNano:   int64(1),
+			Done:       serviceDone,
+		}
+	}()
+
+	timer := time.NewTimer(time.Second * 5).C
+
+	select {
+	case <-serviceDone:
+	case <-timer:
+		s.Fail("Timeout")
+		return
+	}
+
+	endpointNotifyMock.AssertCalled(s.T(), "Create", mock.AnythingOfType("*context.cancelCtx"), "hello=world")
+	sinkNotifyMock.AssertCalled(s.T(), "Create", mock.AnythingOfType("*context.cancelCtx"), "hello=world")
+}
+
+func (s *NotifyDistributorTestSuite) Test_RunTypeDistributesNotificationsToNotifiers() {
+	taskDone := make(chan struct{})
+
+	taskNotifyMock := notificationSenderMock{}
+	taskNotifyMock.On("Create", mock.Anything, "event=task-started").Return(nil)
+	serviceNotifyMock := notificationSenderMock{}
+
+	endpoints := map[string]NotifyEndpoint{
+		"host1": {Notifiers: map[NotifyType]NotificationSender{NotifyTypeTask: &taskNotifyMock}},
+		"host2": {
+			ServiceChan:     make(chan internalNotification),
+			ServiceNotifier: &serviceNotifyMock,
+		},
+	}
+
+	notifyD := newNotifyDistributor(endpoints, NewCancelManager(true),
+		NewCancelManager(true), 1, s.log)
+	s.True(notifyD.HasListeners(NotifyTypeTask))
+
+	var received []Notification
+	notifyD.Subscribe(NotificationFilter{Type: NotifyTypeTask}, func(n Notification) {
+		received = append(received, n)
+	})
+
+	taskChan := make(chan Notification)
+	notifyD.RunType(NotifyTypeTask, taskChan)
+
+	go func() {
+		taskChan <- Notification{
+			EventType:  EventTypeTaskStarted,
+			ID:         "tid1",
+			Parameters: "event=task-started",
+			TimeNano:   int64(1),
+			Done:       taskDone,
+		}
+	}()
+
+	timer := time.NewTimer(time.Second * 5).C
+
+	select {
+	case <-taskDone:
+	case <-timer:
+		s.Fail("Timeout")
+		return
+	}
+
+	taskNotifyMock.AssertExpectations(s.T())
+	serviceNotifyMock.AssertExpectations(s.T())
+	s.Require().Len(received, 1)
+	s.Equal("tid1", received[0].ID)
+}
+
+func (s *NotifyDistributorTestSuite) Test_HasListeners_Subscriptions_MatchType() {
+	notifyD := newNotifyDistributor(map[string]NotifyEndpoint{}, NewCancelManager(true),
+		NewCancelManager(true), 1, s.log)
+	unsubscribe := notifyD.Subscribe(NotificationFilter{Type: NotifyTypeStack}, func(n Notification) {})
+
+	s.True(notifyD.HasListeners(NotifyTypeStack))
+	s.False(notifyD.HasListeners(NotifyTypeTask))
+	s.False(notifyD.HasServiceListeners())
+	s.False(notifyD.HasNodeListeners())
+
+	unsubscribe()
+	notifyD.Subscribe(NotificationFilter{}, func(n Notification) {})
+	s.True(notifyD.HasListeners(NotifyTypeTask))
+	s.True(notifyD.HasServiceListeners())
+	s.True(notifyD.HasNodeListeners())
+}
+
+func (s *NotifyDistributorTestSuite) Test_RunTypeSendsRemoveEventsToRemoveAddr() {
+	configDone := make(chan struct{})
+
+	configNotifyMock := notificationSenderMock{}
+	configNotifyMock.On("Remove", mock.Anything, "id=configID1").Return(nil)
+
+	endpoints := map[string]NotifyEndpoint{
+		"host1": {Notifiers: map[NotifyType]NotificationSender{NotifyTypeConfig: &configNotifyMock}},
+	}
+	notifyD := newNotifyDistributor(endpoints, NewCancelManager(true),
+		NewCancelManager(true), 1, s.log)
+	s.True(notifyD.HasListeners(NotifyTypeConfig))
+	s.False(notifyD.HasListeners(NotifyTypeSecret))
+
+	configChan := make(chan Notification)
+	notifyD.RunType(NotifyTypeConfig, configChan)
+
+	go func() {
+		configChan <- Notification{
+			EventType:  EventTypeRemove,
+			ID:         "configID1",
+			Parameters: "id=configID1",
+			TimeNano:   int64(1),
+			Done:       configDone,
+		}
+	}()
+
+	timer := time.NewTimer(time.Second * 5).C
+
+	select {
+	case <-configDone:
+	case <-timer:
+		s.Fail("Timeout")
+		return
+	}
+	configNotifyMock.AssertExpectations(s.T())
+	configNotifyMock.AssertNotCalled(s.T(), "Create", mock.Anything, mock.Anything)
+}
+
+func (s *NotifyDistributorTestSuite) Test_RunRecordsAuditEntries() {
+	serviceDone := make(chan struct{})
+
+	serviceNotifyMock := notificationSenderMock{}
+	serviceNotifyMock.On("Create", mock.AnythingOfType("*context.cancelCtx"), "hello=world").
+		Return(nil)
+	serviceNotifyMock.On("GetCreateAddr").Return("http://host1/create")
+	auditMock := auditLoggingMock{}
+	auditMock.On("Record", AuditEntry{
+		Kind:   AuditKindEvent,
+		Type:   "service",
+		Event:  EventTypeCreate,
+		ID:     "sid1",
+		Params: "hello=world",
+	}).Return(nil)
+	auditMock.On("Record", AuditEntry{
+		Kind:     AuditKindNotification,
+		Type:     "service",
+		Event:    EventTypeCreate,
+		ID:       "sid1",
+		Params:   "hello=world",
+		Endpoint: "http://host1/create",
+		Attempts: 1,
+		Status:   AuditStatusSuccess,
+	}).Return(nil)
+
+	endpoints := map[string]NotifyEndpoint{
+		"host1": {
+			ServiceChan:     make(chan internalNotification),
+			ServiceNotifier: &serviceNotifyMock,
+		},
+	}
+
+	notifyD := newNotifyDistributor(endpoints, NewCancelManager(true),
+		NewCancelManager(true), 1, s.log)
+	notifyD.Audit = &auditMock
+	serviceChan := make(chan Notification)
+
+	notifyD.Run(serviceChan, nil)
+
+	go func() {
+		serviceChan <- Notification{
+			EventType:  EventTypeCreate,
+			ID:         "sid1",
+			Parameters: "hello=world",
+			TimeNano:   int64(1),
+			Context:    s.ctx,
+			Done:       serviceDone,
+		}
+	}()
+
+	timer := time.NewTimer(time.Second * 5).C
+
+	select {
+	case <-serviceDone:
+	case <-timer:
+		s.Fail("Timeout")
+		return
+	}
+
+	serviceNotifyMock.AssertExpectations(s.T())
+	auditMock.AssertExpectations(s.T())
+}
+
 func (s *NotifyDistributorTestSuite) AssertEndpoints(endpoint NotifyEndpoint, serviceCreateAddr, serviceRemoveAddr, nodeCreateAddr, nodeRemoveAddr string) {
 	if len(serviceCreateAddr) == 0 && len(serviceRemoveAddr) == 0 {
 		s.Nil(endpoint.ServiceNotifier)
@@ -0,0 +1,507 @@
+package service
+
+import (
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// Option configures a `SwarmListener` created with `NewSwarmListener`
+type Option func(*swarmListenerOptions)
+
+type swarmListenerOptions struct {
+	dockerClient        *client.Client
+	logger              *log.Logger
+	notifyLabel         string
+	scrapeNetworkLabel  string
+	includeEndpointInfo bool
+	includeNodeInfo     bool
+	fullRemoveParams    bool
+	retries             int
+	interval            int
+	serviceCreateAddrs  []string
+	serviceRemoveAddrs  []string
+	nodeCreateAddrs     []string
+	nodeRemoveAddrs     []string
+	format              NotificationFormat
+	source              string
+	taskAddrs           []string
+	taskWatchInterval   time.Duration
+	configCreateAddrs   []string
+	configRemoveAddrs   []string
+	secretCreateAddrs   []string
+	secretRemoveAddrs   []string
+	networkCreateAddrs  []string
+	networkRemoveAddrs  []string
+	updateAddrs         []string
+	convergenceAddrs    []string
+	stackAddrs          []string
+	specCreateAddrs     []string
+	specRemoveAddrs     []string
+	convergenceTimeout  time.Duration
+	waitForHealthy      bool
+	eventStreamMaxGap   time.Duration
+	reconcileInterval   time.Duration
+	cacheServices       bool
+	cacheNodes          bool
+	requestTemplates    map[string]EndpointRequestTemplates
+	templates           []TemplateConfig
+	consulAddr          string
+	consulToken         string
+	consulSyncInterval  time.Duration
+	redisSink           *RedisSink
+	redisTypes          []NotifyType
+	xdsAddr             string
+	xdsListenerPort     uint32
+	dnsAddr             string
+	dnsDomain           string
+	auditLog            AuditLogging
+	subscriptions       []subscription
+}
+
+// WithDockerClient sets the docker client. By default, the client is
+// created from environment variables with `NewDockerClientFromEnv`
+func WithDockerClient(dockerClient *client.Client) Option {
+	return func(o *swarmListenerOptions) {
+		o.dockerClient = dockerClient
+	}
+}
+
+// WithLogger sets the logger
+func WithLogger(logger *log.Logger) Option {
+	return func(o *swarmListenerOptions) {
+		o.logger = logger
+	}
+}
+
+// WithNotifyLabel sets the label that services must have to trigger
+// notifications. Defaults to `com.df.notify`
+func WithNotifyLabel(label string) Option {
+	return func(o *swarmListenerOptions) {
+		o.notifyLabel = label
+	}
+}
+
+// WithIncludeNodeInfo includes the task addresses of services on the network
+// defined by the `com.df.scrapeNetwork` label
+func WithIncludeNodeInfo(includeNodeInfo bool) Option {
+	return func(o *swarmListenerOptions) {
+		o.includeNodeInfo = includeNodeInfo
+	}
+}
+
+// WithIncludeEndpointInfo includes the endpoint mode, published ports, and
+// virtual IPs of services in service notifications
+func WithIncludeEndpointInfo(includeEndpointInfo bool) Option {
+	return func(o *swarmListenerOptions) {
+		o.includeEndpointInfo = includeEndpointInfo
+	}
+}
+
+// WithFullRemoveParameters sends all of the last known create parameters of
+// services and nodes in remove notifications
+func WithFullRemoveParameters(fullRemoveParams bool) Option {
+	return func(o *swarmListenerOptions) {
+		o.fullRemoveParams = fullRemoveParams
+	}
+}
+
+// WithRetry sets the number of retries and the interval in seconds
+// between retries of HTTP notifications. Defaults to 50 retries every 5
+// seconds
+func WithRetry(retries, interval int) Option {
+	return func(o *swarmListenerOptions) {
+		o.retries = retries
+		o.interval = interval
+	}
+}
+
+// WithServiceNotifyURLs adds URLs that receive service create and remove
+// notifications
+func WithServiceNotifyURLs(createAddrs, removeAddrs []string) Option {
+	return func(o *swarmListenerOptions) {
+		o.serviceCreateAddrs = append(o.serviceCreateAddrs, createAddrs...)
+		o.serviceRemoveAddrs = append(o.serviceRemoveAddrs, removeAddrs...)
+	}
+}
+
+// WithNodeNotifyURLs adds URLs that receive node create and remove
+// notifications
+func WithNodeNotifyURLs(createAddrs, removeAddrs []string) Option {
+	return func(o *swarmListenerOptions) {
+		o.nodeCreateAddrs = append(o.nodeCreateAddrs, createAddrs...)
+		o.nodeRemoveAddrs = append(o.nodeRemoveAddrs, removeAddrs...)
+	}
+}
+
+// WithNotificationFormat sets the format of HTTP notifications. `source`
+// is used as the CloudEvents source
+func WithNotificationFormat(format NotificationFormat, source string) Option {
+	return func(o *swarmListenerOptions) {
+		o.format = format
+		o.source = source
+	}
+}
+
+// WithTaskNotifyURLs adds URLs that receive task notifications
+func WithTaskNotifyURLs(addrs []string) Option {
+	return func(o *swarmListenerOptions) {
+		o.taskAddrs = append(o.taskAddrs, addrs...)
+	}
+}
+
+// WithTaskWatchInterval enables the task watcher, which polls the tasks of
+// services every `interval`
+func WithTaskWatchInterval(interval time.Duration) Option {
+	return func(o *swarmListenerOptions) {
+		o.taskWatchInterval = interval
+	}
+}
+
+// WithUpdateNotifyURLs adds URLs that receive service update and rollback
+// notifications
+func WithUpdateNotifyURLs(addrs []string) Option {
+	return func(o *swarmListenerOptions) {
+		o.updateAddrs = append(o.updateAddrs, addrs...)
+	}
+}
+
+// WithStackNotifyURLs adds URLs that receive stack deployed and removed
+// notifications
+func WithStackNotifyURLs(addrs []string) Option {
+	return func(o *swarmListenerOptions) {
+		o.stackAddrs = append(o.stackAddrs, addrs...)
+	}
+}
+
+// WithServiceSpecNotifyURLs adds URLs that receive service create and
+// remove notifications as soon as the spec of services changed, without
+// waiting for services to converge
+func WithServiceSpecNotifyURLs(createAddrs, removeAddrs []string) Option {
+	return func(o *swarmListenerOptions) {
+		o.specCreateAddrs = append(o.specCreateAddrs, createAddrs...)
+		o.specRemoveAddrs = append(o.specRemoveAddrs, removeAddrs...)
+	}
+}
+
+// WithConvergenceTimeout sets the time services have to converge. The
+// `com.df.convergenceTimeout` label overrides it per service
+func WithConvergenceTimeout(timeout time.Duration) Option {
+	return func(o *swarmListenerOptions) {
+		o.convergenceTimeout = timeout
+	}
+}
+
+// WithWaitForHealthy waits for the containers of services to be healthy
+// before services are notified. The `com.df.waitForHealthy` label overrides
+// it per service
+func WithWaitForHealthy(wait bool) Option {
+	return func(o *swarmListenerOptions) {
+		o.waitForHealthy = wait
+	}
+}
+
+// WithEventStreamMaxGap sets how long the docker event streams can be down
+// before services and nodes are reconciled instead of replaying the missed
+// events. Defaults to one minute
+func WithEventStreamMaxGap(maxGap time.Duration) Option {
+	return func(o *swarmListenerOptions) {
+		o.eventStreamMaxGap = maxGap
+	}
+}
+
+// WithReconcileInterval sets the time between reconciliations of the
+// cached services and nodes with the swarm. Reconciliation is disabled by
+// default
+func WithReconcileInterval(interval time.Duration) Option {
+	return func(o *swarmListenerOptions) {
+		o.reconcileInterval = interval
+	}
+}
+
+// WithConvergenceNotifyURLs adds URLs that receive notifications of
+// services that did not converge in time
+func WithConvergenceNotifyURLs(addrs []string) Option {
+	return func(o *swarmListenerOptions) {
+		o.convergenceAddrs = append(o.convergenceAddrs, addrs...)
+	}
+}
+
+// WithConfigNotifyURLs adds URLs that receive config create and remove
+// notifications
+func WithConfigNotifyURLs(createAddrs, removeAddrs []string) Option {
+	return func(o *swarmListenerOptions) {
+		o.configCreateAddrs = append(o.configCreateAddrs, createAddrs...)
+		o.configRemoveAddrs = append(o.configRemoveAddrs, removeAddrs...)
+	}
+}
+
+// WithSecretNotifyURLs adds URLs that receive secret create and remove
+// notifications
+func WithSecretNotifyURLs(createAddrs, removeAddrs []string) Option {
+	return func(o *swarmListenerOptions) {
+		o.secretCreateAddrs = append(o.secretCreateAddrs, createAddrs...)
+		o.secretRemoveAddrs = append(o.secretRemoveAddrs, removeAddrs...)
+	}
+}
+
+// WithNetworkNotifyURLs adds URLs that receive overlay network create and
+// remove notifications
+func WithNetworkNotifyURLs(createAddrs, removeAddrs []string) Option {
+	return func(o *swarmListenerOptions) {
+		o.networkCreateAddrs = append(o.networkCreateAddrs, createAddrs...)
+		o.networkRemoveAddrs = append(o.networkRemoveAddrs, removeAddrs...)
+	}
+}
+
+// WithCache caches services and nodes, so that notifications are only sent
+// when they changed. Both are cached by default. Services are always cached
+// when update, convergence, stack, spec, or task notifications are enabled
+func WithCache(services, nodes bool) Option {
+	return func(o *swarmListenerOptions) {
+		o.cacheServices = services
+		o.cacheNodes = nodes
+	}
+}
+
+// WithRequestTemplates sets the request templates of notification URLs,
+// keyed by the host of the URLs
+func WithRequestTemplates(templates map[string]EndpointRequestTemplates) Option {
+	return func(o *swarmListenerOptions) {
+		o.requestTemplates = templates
+	}
+}
+
+// WithTemplates renders `templates` with the cached services and nodes
+// every time they change
+func WithTemplates(templates []TemplateConfig) Option {
+	return func(o *swarmListenerOptions) {
+		o.templates = append(o.templates, templates...)
+	}
+}
+
+// WithConsul registers services in the Consul agent at `addr` and
+// synchronizes them every `syncInterval`
+func WithConsul(addr, token string, syncInterval time.Duration) Option {
+	return func(o *swarmListenerOptions) {
+		o.consulAddr = addr
+		o.consulToken = token
+		o.consulSyncInterval = syncInterval
+	}
+}
+
+// WithRedisSink publishes notifications of `notifyTypes` with `sink`
+func WithRedisSink(sink *RedisSink, notifyTypes []NotifyType) Option {
+	return func(o *swarmListenerOptions) {
+		o.redisSink = sink
+		o.redisTypes = notifyTypes
+	}
+}
+
+// WithXDS serves the cached services as Envoy xDS resources on `addr`
+// Envoy listens for requests on `listenerPort`
+func WithXDS(addr string, listenerPort uint32) Option {
+	return func(o *swarmListenerOptions) {
+		o.xdsAddr = addr
+		o.xdsListenerPort = listenerPort
+	}
+}
+
+// WithDNSServer answers DNS queries for the cached services and nodes in
+// `domain` on `addr`. Services and nodes are always cached when it is set
+func WithDNSServer(addr, domain string) Option {
+	return func(o *swarmListenerOptions) {
+		o.dnsAddr = addr
+		o.dnsDomain = domain
+	}
+}
+
+// WithAuditLog records observed changes and sent notifications in
+// `auditLog`
+func WithAuditLog(auditLog AuditLogging) Option {
+	return func(o *swarmListenerOptions) {
+		o.auditLog = auditLog
+	}
+}
+
+// WithSubscription calls `fn` with every notification that matches
+// `filter`. Unlike `Subscribe`, subscriptions added with options enable
+// update, convergence, stack, and spec notifications, which are only
+// tracked when they have listeners once the listener is created
+func WithSubscription(filter NotificationFilter, fn func(Notification)) Option {
+	return func(o *swarmListenerOptions) {
+		o.subscriptions = append(o.subscriptions, subscription{filter: filter, fn: fn})
+	}
+}
+
+// NewSwarmListener creates a `SwarmListener` configured with `opts`
+// Update, convergence, stack, and spec notifications are only tracked when
+// they have listeners
+func NewSwarmListener(opts ...Option) (*SwarmListener, error) {
+	o := swarmListenerOptions{
+		notifyLabel:        "com.df.notify",
+		scrapeNetworkLabel: "com.df.scrapeNetwork",
+		retries:            50,
+		interval:           5,
+		format:             NotificationFormatQuery,
+		eventStreamMaxGap:  defaultEventStreamMaxGap,
+		cacheServices:      true,
+		cacheNodes:         true,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.logger == nil {
+		o.logger = log.New(os.Stdout, "", log.LstdFlags)
+	}
+	if o.dockerClient == nil {
+		dockerClient, err := NewDockerClientFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		o.dockerClient = dockerClient
+	}
+
+	notifyDistributor := newFormattedNotifyDistributorfromStrings(
+		strings.Join(o.serviceCreateAddrs, ","),
+		strings.Join(o.serviceRemoveAddrs, ","),
+		strings.Join(o.nodeCreateAddrs, ","),
+		strings.Join(o.nodeRemoveAddrs, ","),
+		o.format, o.source, o.retries, o.interval, o.logger)
+	notifyDistributor.addEndpoints(NotifyTypeTask,
+		strings.Join(o.taskAddrs, ","), "", o.format, o.source, o.retries, o.interval, o.logger)
+	notifyDistributor.addEndpoints(NotifyTypeUpdate,
+		strings.Join(o.updateAddrs, ","), "", o.format, o.source, o.retries, o.interval, o.logger)
+	notifyDistributor.addEndpoints(NotifyTypeConvergence,
+		strings.Join(o.convergenceAddrs, ","), "", o.format, o.source, o.retries, o.interval, o.logger)
+	notifyDistributor.addEndpoints(NotifyTypeStack,
+		strings.Join(o.stackAddrs, ","), "", o.format, o.source, o.retries, o.interval, o.logger)
+	notifyDistributor.addEndpoints(NotifyTypeSpec,
+		strings.Join(o.specCreateAddrs, ","), strings.Join(o.specRemoveAddrs, ","),
+		o.format, o.source, o.retries, o.interval, o.logger)
+	notifyDistributor.addEndpoints(NotifyTypeConfig,
+		strings.Join(o.configCreateAddrs, ","), strings.Join(o.configRemoveAddrs, ","),
+		o.format, o.source, o.retries, o.interval, o.logger)
+	notifyDistributor.addEndpoints(NotifyTypeSecret,
+		strings.Join(o.secretCreateAddrs, ","), strings.Join(o.secretRemoveAddrs, ","),
+		o.format, o.source, o.retries, o.interval, o.logger)
+	notifyDistributor.addEndpoints(NotifyTypeNetwork,
+		strings.Join(o.networkCreateAddrs, ","), strings.Join(o.networkRemoveAddrs, ","),
+		o.format, o.source, o.retries, o.interval, o.logger)
+
+	notifyDistributor.SetRequestTemplates(o.requestTemplates)
+	for _, sub := range o.subscriptions {
+		notifyDistributor.Subscribe(sub.filter, sub.fn)
+	}
+
+	ssCache := NewSwarmServiceCache()
+	nodeCache := NewNodeCache()
+	var registrar *ConsulRegistrar
+	var xdsServer *XDSServer
+	if len(o.templates) > 0 {
+		renderer := NewTemplateRenderer(o.templates, ssCache, nodeCache, o.logger)
+		notifyDistributor.AddSink(NotifyEndpoint{
+			ServiceChan:     make(chan internalNotification),
+			ServiceNotifier: renderer,
+			NodeChan:        make(chan internalNotification),
+			NodeNotifier:    renderer,
+		})
+	}
+	if len(o.consulAddr) > 0 {
+		registrar = NewConsulRegistrar(
+			o.consulAddr, o.consulToken, o.consulSyncInterval, ssCache, o.logger)
+		notifyDistributor.AddSink(NotifyEndpoint{
+			ServiceChan:     make(chan internalNotification),
+			ServiceNotifier: registrar,
+		})
+	}
+	if o.redisSink != nil {
+		notifyDistributor.AddSink(NewRedisEndpoint(o.redisSink, o.redisTypes))
+	}
+	if len(o.xdsAddr) > 0 {
+		xdsServer = NewXDSServer(o.xdsAddr, o.xdsListenerPort, ssCache, o.logger)
+		notifyDistributor.AddSink(NotifyEndpoint{
+			ServiceChan:     make(chan internalNotification),
+			ServiceNotifier: xdsServer,
+		})
+	}
+	if o.auditLog != nil {
+		notifyDistributor.Audit = o.auditLog
+	}
+
+	ssClient := NewSwarmServiceClient(o.dockerClient, o.notifyLabel, o.scrapeNetworkLabel, o.logger)
+	ssClient.ConvergenceTimeout = o.convergenceTimeout
+	ssClient.WaitForHealthy = o.waitForHealthy
+	ssListener := NewSwarmServiceListener(o.dockerClient, o.logger)
+	ssListener.MaxGap = o.eventStreamMaxGap
+	nodeListener := NewNodeListener(o.dockerClient, o.logger)
+	nodeListener.MaxGap = o.eventStreamMaxGap
+	swarmListener := newSwarmListener(
+		ssListener,
+		ssClient,
+		ssCache,
+		nodeListener,
+		NewNodeClient(o.dockerClient),
+		nodeCache,
+		notifyDistributor,
+		NewCancelManager(false),
+		NewCancelManager(false),
+		NewCancelManager(false),
+		NewCancelManager(false),
+		o.includeNodeInfo,
+		o.notifyLabel,
+		"com.docker.stack.namespace",
+		o.logger,
+	)
+	swarmListener.IncludeEndpointInfo = o.includeEndpointInfo
+	swarmListener.FullRemoveParameters = o.fullRemoveParams
+	swarmListener.Audit = o.auditLog
+	swarmListener.ConsulRegistrar = registrar
+	swarmListener.XDSServer = xdsServer
+	if len(o.dnsAddr) > 0 {
+		swarmListener.DNSServer = NewDNSServer(o.dnsAddr, o.dnsDomain, ssCache, nodeCache, o.logger)
+	}
+	ssListener.OnGap = func() { swarmListener.ReconcileServices() }
+	nodeListener.OnGap = func() { swarmListener.ReconcileNodes() }
+	if o.taskWatchInterval > 0 {
+		swarmListener.TaskWatcher = NewTaskWatcher(
+			NewTaskClient(o.dockerClient), ssCache, o.taskWatchInterval, o.logger)
+	}
+	if o.reconcileInterval > 0 {
+		swarmListener.Reconciler = swarmListener.newReconciler(o.reconcileInterval)
+	}
+	if notifyDistributor.HasListeners(NotifyTypeUpdate) {
+		swarmListener.UpdateTracker = NewUpdateTracker()
+		ssClient.UpdateObserver = swarmListener.UpdateTracker.Observe
+	}
+	if notifyDistributor.HasListeners(NotifyTypeConvergence) {
+		swarmListener.ConvergenceNotificationChan = make(chan Notification)
+	}
+	if notifyDistributor.HasListeners(NotifyTypeStack) {
+		swarmListener.StackTracker = NewStackTracker()
+		ssClient.StackObserver = swarmListener.StackTracker.Observe
+	}
+	if notifyDistributor.HasListeners(NotifyTypeSpec) {
+		swarmListener.SpecTracker = NewSpecTracker(o.notifyLabel, swarmListener.IncludeKey)
+		swarmListener.SpecTracker.FullRemoveParameters = o.fullRemoveParams
+		swarmListener.SpecTracker.IncludeEndpointInfo = o.includeEndpointInfo
+		ssClient.SpecObserver = swarmListener.SpecTracker.Observe
+	}
+	swarmListener.CacheServices = o.cacheServices ||
+		swarmListener.TaskWatcher != nil || swarmListener.UpdateTracker != nil ||
+		swarmListener.ConvergenceNotificationChan != nil || swarmListener.StackTracker != nil ||
+		swarmListener.SpecTracker != nil || swarmListener.DNSServer != nil
+	swarmListener.CacheNodes = o.cacheNodes || swarmListener.DNSServer != nil
+	swarmListener.NetworkListener = NewNetworkListener(o.dockerClient, o.logger)
+	swarmListener.NetworkClient = NewNetworkClient(o.dockerClient)
+	swarmListener.NetworkCache = NewNetworkCache()
+	objectClient := NewSwarmObjectClient(o.dockerClient)
+	swarmListener.ConfigWatch = NewSwarmObjectWatch(NotifyTypeConfig,
+		NewConfigListener(o.dockerClient, o.logger), objectClient, NewSwarmObjectCache())
+	swarmListener.SecretWatch = NewSwarmObjectWatch(NotifyTypeSecret,
+		NewSecretListener(o.dockerClient, o.logger), objectClient, NewSwarmObjectCache())
+	return swarmListener, nil
+}
@@ -0,0 +1,611 @@
+package service
+
+import (
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type OptionsTestSuite struct {
+	suite.Suite
+}
+
+func TestOptionsUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(OptionsTestSuite))
+}
+
+func (s *OptionsTestSuite) Test_NewSwarmListener_AppliesOptions() {
+	dockerClient, err := NewDockerClientFromEnv()
+	s.Require().NoError(err)
+	logger := log.New(ioutil.Discard, "", 0)
+
+	l, err := NewSwarmListener(
+		WithDockerClient(dockerClient),
+		WithLogger(logger),
+		WithIncludeNodeInfo(true),
+		WithServiceNotifyURLs([]string{"http://host1/create"}, []string{"http://host1/remove"}),
+	)
+	s.Require().NoError(err)
+
+	s.True(l.IncludeNodeInfo)
+	s.True(l.CacheServices)
+	s.True(l.CacheNodes)
+	s.Equal("com.df.notify", l.IgnoreKey)
+	s.Equal(logger, l.Log)
+	notifyD := l.NotifyDistributor.(*NotifyDistributor)
+	s.Require().Contains(notifyD.NotifyEndpoints, "host1")
+	s.Equal("http://host1/create", notifyD.NotifyEndpoints["host1"].ServiceNotifier.GetCreateAddr())
+	s.Nil(notifyD.NotifyEndpoints["host1"].NodeNotifier)
+}
+
+func (s *OptionsTestSuite) Test_NewSwarmListener_Defaults() {
+	dockerClient, err := NewDockerClientFromEnv()
+	s.Require().NoError(err)
+
+	l, err := NewSwarmListener(WithDockerClient(dockerClient))
+	s.Require().NoError(err)
+
+	s.False(l.IncludeNodeInfo)
+	s.NotNil(l.Log)
+	s.True(l.CacheServices)
+	s.True(l.CacheNodes)
+	notifyD := l.NotifyDistributor.(*NotifyDistributor)
+	s.Empty(notifyD.NotifyEndpoints)
+	s.Empty(notifyD.Sinks)
+	s.Nil(l.UpdateTracker)
+	s.Nil(l.StackTracker)
+	s.Nil(l.SpecTracker)
+	s.Nil(l.ConvergenceNotificationChan)
+	s.Nil(l.Audit)
+}
+
+func (s *OptionsTestSuite) Test_NewSwarmListener_DefaultRetry() {
+	dockerClient, err := NewDockerClientFromEnv()
+	s.Require().NoError(err)
+
+	l, err := NewSwarmListener(
+		WithDockerClient(dockerClient),
+		WithServiceNotifyURLs([]string{"http://host1/create"}, nil),
+	)
+	s.Require().NoError(err)
+
+	notifyD := l.NotifyDistributor.(*NotifyDistributor)
+	notifier := notifyD.NotifyEndpoints["host1"].ServiceNotifier.(*Notifier)
+	s.Equal(50, notifier.retries)
+	s.Equal(5, notifier.interval)
+}
+
+func (s *OptionsTestSuite) Test_NewSwarmListener_WithCache() {
+	dockerClient, err := NewDockerClientFromEnv()
+	s.Require().NoError(err)
+
+	l, err := NewSwarmListener(
+		WithDockerClient(dockerClient),
+		WithCache(false, false),
+	)
+	s.Require().NoError(err)
+	s.False(l.CacheServices)
+	s.False(l.CacheNodes)
+
+	l, err = NewSwarmListener(
+		WithDockerClient(dockerClient),
+		WithCache(false, false),
+		WithStackNotifyURLs([]string{"http://host1/stack"}),
+	)
+	s.Require().NoError(err)
+	s.True(l.CacheServices)
+	s.False(l.CacheNodes)
+}
+
+func (s *OptionsTestSuite) Test_NewSwarmListener_WithSubscription_EnablesTracker() {
+	dockerClient, err := NewDockerClientFromEnv()
+	s.Require().NoError(err)
+
+	l, err := NewSwarmListener(
+		WithDockerClient(dockerClient),
+		WithSubscription(NotificationFilter{Type: NotifyTypeStack}, func(n Notification) {}),
+	)
+	s.Require().NoError(err)
+
+	s.NotNil(l.StackTracker)
+	s.Nil(l.UpdateTracker)
+	s.Nil(l.SpecTracker)
+	s.True(l.NotifyDistributor.HasListeners(NotifyTypeStack))
+}
+
+func (s *OptionsTestSuite) Test_NewSwarmListener_Sinks() {
+	dockerClient, err := NewDockerClientFromEnv()
+	s.Require().NoError(err)
+	tmpDir, err := ioutil.TempDir("", "options")
+	s.Require().NoError(err)
+	defer os.RemoveAll(tmpDir)
+	auditLog, err := NewFileAuditLog(filepath.Join(tmpDir, "audit.log"), 0, 0)
+	s.Require().NoError(err)
+	redisSink := NewRedisSink(
+		"localhost:6379", "", "swarm", "", 0, "service", "", 1, 0, log.New(ioutil.Discard, "", 0))
+
+	l, err := NewSwarmListener(
+		WithDockerClient(dockerClient),
+		WithTemplates([]TemplateConfig{{Source: "/in.tmpl", Destination: "/out.conf"}}),
+		WithConsul("http://consul:8500", "", 0),
+		WithRedisSink(redisSink, []NotifyType{NotifyTypeService, NotifyTypeStack}),
+		WithAuditLog(auditLog),
+		WithServiceNotifyURLs([]string{"http://consul:8500/reconfigure"}, nil),
+	)
+	s.Require().NoError(err)
+
+	notifyD := l.NotifyDistributor.(*NotifyDistributor)
+	s.Len(notifyD.NotifyEndpoints, 1)
+	s.Equal("http://consul:8500/reconfigure",
+		notifyD.NotifyEndpoints["consul:8500"].ServiceNotifier.GetCreateAddr())
+	s.Require().Len(notifyD.Sinks, 3)
+	s.IsType(&TemplateRenderer{}, notifyD.Sinks[0].ServiceNotifier)
+	s.IsType(&ConsulRegistrar{}, notifyD.Sinks[1].ServiceNotifier)
+	s.IsType(&RedisSink{}, notifyD.Sinks[2].ServiceNotifier)
+	s.Equal(auditLog, notifyD.Audit)
+	s.Equal(auditLog, l.Audit)
+	s.NotNil(l.StackTracker)
+	s.Nil(l.UpdateTracker)
+}
+
+func (s *OptionsTestSuite) Test_NewSwarmListener_DoesNotStartServers() {
+	dockerClient, err := NewDockerClientFromEnv()
+	s.Require().NoError(err)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer lis.Close()
+
+	l, err := NewSwarmListener(
+		WithDockerClient(dockerClient),
+		WithLogger(log.New(ioutil.Discard, "", 0)),
+		WithConsul("http://consul:8500", "", time.Second),
+		WithXDS(lis.Addr().String(), 8080),
+		WithDNSServer(lis.Addr().String(), "swarm"),
+	)
+	s.Require().NoError(err)
+
+	s.Require().NotNil(l.ConsulRegistrar)
+	s.Require().NotNil(l.XDSServer)
+	s.Require().NotNil(l.DNSServer)
+	s.Error(l.XDSServer.Run())
+	s.Error(l.DNSServer.Run())
+}
+
+func (s *OptionsTestSuite) Test_NewSwarmListener_DNSServerCachesServicesAndNodes() {
+	dockerClient, err := NewDockerClientFromEnv()
+	s.Require().NoError(err)
+
+	l, err := NewSwarmListener(
+		WithDockerClient(dockerClient),
+		WithCache(false, false),
+		WithDNSServer(":53", "cluster.local."),
+	)
+	s.Require().NoError(err)
+
+	s.Require().NotNil(l.DNSServer)
+	s.Equal(":53", l.DNSServer.Addr)
+	s.Equal("cluster.local.", l.DNSServer.Domain)
+	s.True(l.CacheServices)
+	s.True(l.CacheNodes)
+}
+
+func (s *OptionsTestSuite) Test_NewSwarmListener_TaskOptions() {
+	dockerClient, err := NewDockerClientFromEnv()
+	s.Require().NoError(err)
+
+	l, err := NewSwarmListener(
+		WithDockerClient(dockerClient),
+		WithServiceNotifyURLs([]string{"http://host1/create"}, nil),
+		WithTaskNotifyURLs([]string{"http://host1/task", "http://host2/task"}),
+		WithTaskWatchInterval(time.Second),
+	)
+	s.Require().NoError(err)
+
+	s.Require().NotNil(l.TaskWatcher)
+	s.Equal(time.Second, l.TaskWatcher.Interval)
+	notifyD := l.NotifyDistributor.(*NotifyDistributor)
+	s.Require().Len(notifyD.NotifyEndpoints, 2)
+	s.Equal("http://host1/create", notifyD.NotifyEndpoints["host1"].ServiceNotifier.GetCreateAddr())
+	s.Equal("http://host1/task", notifyD.NotifyEndpoints["host1"].Notifiers[NotifyTypeTask].GetCreateAddr())
+	s.Nil(notifyD.NotifyEndpoints["host2"].ServiceNotifier)
+	s.Equal("http://host2/task", notifyD.NotifyEndpoints["host2"].Notifiers[NotifyTypeTask].GetCreateAddr())
+	s.True(notifyD.HasListeners(NotifyTypeTask))
+}
+
+func (s *OptionsTestSuite) Test_NewSwarmListener_ConfigAndSecretOptions() {
+	dockerClient, err := NewDockerClientFromEnv()
+	s.Require().NoError(err)
+
+	l, err := NewSwarmListener(
+		WithDockerClient(dockerClient),
+		WithConfigNotifyURLs([]string{"http://host1/config/create"}, []string{"http://host1/config/remove"}),
+		WithSecretNotifyURLs([]string{"http://host2/secret/create"}, nil),
+	)
+	s.Require().NoError(err)
+
+	s.Require().NotNil(l.ConfigWatch)
+	s.Require().NotNil(l.SecretWatch)
+	s.Equal(NotifyTypeConfig, l.ConfigWatch.Kind)
+	s.Equal(NotifyTypeSecret, l.SecretWatch.Kind)
+	notifyD := l.NotifyDistributor.(*NotifyDistributor)
+	s.Require().Len(notifyD.NotifyEndpoints, 2)
+	s.Equal("http://host1/config/remove",
+		notifyD.NotifyEndpoints["host1"].Notifiers[NotifyTypeConfig].GetRemoveAddr())
+	s.Equal("http://host2/secret/create",
+		notifyD.NotifyEndpoints["host2"].Notifiers[NotifyTypeSecret].GetCreateAddr())
+}
+
+func (s *OptionsTestSuite) Test_NewSwarmListener_UpdateOptions() {
+	dockerClient, err := NewDockerClientFromEnv()
+	s.Require().NoError(err)
+
+	l, err := NewSwarmListener(
+		WithDockerClient(dockerClient),
+		WithUpdateNotifyURLs([]string{"http://host1/update"}),
+	)
+	s.Require().NoError(err)
+
+	s.NotNil(l.UpdateTracker)
+	s.NotNil(l.SSClient.(*SwarmServiceClient).UpdateObserver)
+	notifyD := l.NotifyDistributor.(*NotifyDistributor)
+	s.Equal("http://host1/update",
+		notifyD.NotifyEndpoints["host1"].Notifiers[NotifyTypeUpdate].GetCreateAddr())
+	s.True(notifyD.HasListeners(NotifyTypeUpdate))
+}
+
+func (s *OptionsTestSuite) Test_NewSwarmListener_ConvergenceOptions() {
+	dockerClient, err := NewDockerClientFromEnv()
+	s.Require().NoError(err)
+
+	l, err := NewSwarmListener(
+		WithDockerClient(dockerClient),
+		WithConvergenceTimeout(time.Minute),
+		WithWaitForHealthy(true),
+		WithConvergenceNotifyURLs([]string{"http://host1/convergence"}),
+	)
+	s.Require().NoError(err)
+
+	s.Equal(time.Minute, l.SSClient.(*SwarmServiceClient).ConvergenceTimeout)
+	s.True(l.SSClient.(*SwarmServiceClient).WaitForHealthy)
+	notifyD := l.NotifyDistributor.(*NotifyDistributor)
+	s.Equal("http://host1/convergence",
+		notifyD.NotifyEndpoints["host1"].Notifiers[NotifyTypeConvergence].GetCreateAddr())
+}
+
+func (s *OptionsTestSuite) Test_NewSwarmListener_FullRemoveParameters() {
+	dockerClient, err := NewDockerClientFromEnv()
+	s.Require().NoError(err)
+
+	l, err := NewSwarmListener(WithDockerClient(dockerClient))
+	s.Require().NoError(err)
+	s.False(l.FullRemoveParameters)
+
+	l, err = NewSwarmListener(
+		WithDockerClient(dockerClient),
+		WithFullRemoveParameters(true),
+	)
+	s.Require().NoError(err)
+	s.True(l.FullRemoveParameters)
+}
+
+func (s *OptionsTestSuite) Test_NewSwarmListener_IncludeEndpointInfo() {
+	dockerClient, err := NewDockerClientFromEnv()
+	s.Require().NoError(err)
+
+	l, err := NewSwarmListener(WithDockerClient(dockerClient))
+	s.Require().NoError(err)
+	s.False(l.IncludeEndpointInfo)
+
+	l, err = NewSwarmListener(
+		WithDockerClient(dockerClient),
+		WithIncludeEndpointInfo(true),
+		WithServiceSpecNotifyURLs([]string{"http://host1/spec/create"}, nil),
+	)
+	s.Require().NoError(err)
+	s.True(l.IncludeEndpointInfo)
+	s.Require().NotNil(l.SpecTracker)
+	s.True(l.SpecTracker.IncludeEndpointInfo)
+}
+
+func (s *OptionsTestSuite) Test_NewSwarmListener_EventStreamMaxGap() {
+	dockerClient, err := NewDockerClientFromEnv()
+	s.Require().NoError(err)
+
+	l, err := NewSwarmListener(WithDockerClient(dockerClient))
+	s.Require().NoError(err)
+	s.Equal(time.Minute, l.SSListener.(*SwarmServiceListener).MaxGap)
+	s.Equal(time.Minute, l.NodeListener.(*NodeListener).MaxGap)
+	s.NotNil(l.SSListener.(*SwarmServiceListener).OnGap)
+	s.NotNil(l.NodeListener.(*NodeListener).OnGap)
+
+	l, err = NewSwarmListener(
+		WithDockerClient(dockerClient),
+		WithEventStreamMaxGap(5*time.Minute),
+	)
+	s.Require().NoError(err)
+	s.Equal(5*time.Minute, l.SSListener.(*SwarmServiceListener).MaxGap)
+	s.Equal(5*time.Minute, l.NodeListener.(*NodeListener).MaxGap)
+}
+
+func (s *OptionsTestSuite) Test_NewSwarmListener_ReconcileInterval() {
+	dockerClient, err := NewDockerClientFromEnv()
+	s.Require().NoError(err)
+
+	l, err := NewSwarmListener(WithDockerClient(dockerClient))
+	s.Require().NoError(err)
+	s.Nil(l.Reconciler)
+
+	l, err = NewSwarmListener(
+		WithDockerClient(dockerClient),
+		WithIncludeNodeInfo(true),
+		WithReconcileInterval(time.Minute),
+	)
+	s.Require().NoError(err)
+	s.Require().NotNil(l.Reconciler)
+	s.Equal(time.Minute, l.Reconciler.Interval)
+	s.True(l.Reconciler.IncludeNodeInfo)
+	s.Equal("com.df.notify", l.Reconciler.IgnoreKey)
+	s.Equal(l.SSCache, l.Reconciler.SSCache)
+	s.Equal(l.NodeCache, l.Reconciler.NodeCache)
+}
+
+func (s *OptionsTestSuite) Test_NewSwarmListener_ServiceSpecNotifyURLs() {
+	dockerClient, err := NewDockerClientFromEnv()
+	s.Require().NoError(err)
+
+	l, err := NewSwarmListener(
+		WithDockerClient(dockerClient),
+		WithServiceSpecNotifyURLs(
+			[]string{"http://host1/spec/create"}, []string{"http://host1/spec/remove"}),
+	)
+	s.Require().NoError(err)
+
+	s.NotNil(l.SpecTracker)
+	s.NotNil(l.SSClient.(*SwarmServiceClient).SpecObserver)
+	notifyD := l.NotifyDistributor.(*NotifyDistributor)
+	s.Equal("http://host1/spec/create",
+		notifyD.NotifyEndpoints["host1"].Notifiers[NotifyTypeSpec].GetCreateAddr())
+	s.Equal("http://host1/spec/remove",
+		notifyD.NotifyEndpoints["host1"].Notifiers[NotifyTypeSpec].GetRemoveAddr())
+}
+
+func (s *OptionsTestSuite) Test_NewSwarmListener_StackNotifyURLs() {
+	dockerClient, err := NewDockerClientFromEnv()
+	s.Require().NoError(err)
+
+	l, err := NewSwarmListener(
+		WithDockerClient(dockerClient),
+		WithStackNotifyURLs([]string{"http://host1/stack"}),
+	)
+	s.Require().NoError(err)
+
+	s.NotNil(l.StackTracker)
+	s.NotNil(l.SSClient.(*SwarmServiceClient).StackObserver)
+	notifyD := l.NotifyDistributor.(*NotifyDistributor)
+	s.Equal("http://host1/stack",
+		notifyD.NotifyEndpoints["host1"].Notifiers[NotifyTypeStack].GetCreateAddr())
+}
+
+func (s *OptionsTestSuite) Test_OptionsFromEnv() {
+	env := map[string]string{
+		"DF_NOTIFY_CREATE_SERVICE_URL": "http://host1/create",
+		"DF_NOTIFY_STACK_URL":          "http://host2/stack",
+		"DF_NOTIFY_LABEL":              "com.df.listen",
+		"DF_INCLUDE_NODE_IP_INFO":      "true",
+		"DF_DNS_ADDR":                  ":53",
+		"DF_RECONCILE_INTERVAL":        "30",
+		"DF_TEMPLATE_OUT":              "/in.tmpl:/out.conf",
+		"DF_REDIS_ADDR":                "localhost:6379",
+		"DF_REDIS_TYPES":               "service,stack",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+	dockerClient, err := NewDockerClientFromEnv()
+	s.Require().NoError(err)
+	logger := log.New(ioutil.Discard, "", 0)
+
+	opts, err := optionsFromEnv(3, 2, "cluster1", logger)
+	s.Require().NoError(err)
+	l, err := NewSwarmListener(append(opts, WithDockerClient(dockerClient), WithLogger(logger))...)
+	s.Require().NoError(err)
+
+	s.True(l.IncludeNodeInfo)
+	s.Equal("com.df.listen", l.IgnoreKey)
+	s.True(l.CacheServices)
+	s.True(l.CacheNodes)
+	s.Require().NotNil(l.Reconciler)
+	s.Equal(30*time.Second, l.Reconciler.Interval)
+	s.NotNil(l.StackTracker)
+	s.Nil(l.UpdateTracker)
+	s.Nil(l.SpecTracker)
+	s.Nil(l.TaskWatcher)
+	s.Require().NotNil(l.DNSServer)
+	s.Equal(":53", l.DNSServer.Addr)
+	s.Equal("swarm.", l.DNSServer.Domain)
+	notifyD := l.NotifyDistributor.(*NotifyDistributor)
+	s.Require().Len(notifyD.NotifyEndpoints, 2)
+	notifier := notifyD.NotifyEndpoints["host1"].ServiceNotifier.(*Notifier)
+	s.Equal("http://host1/create", notifier.GetCreateAddr())
+	s.Equal(3, notifier.retries)
+	s.Equal(2, notifier.interval)
+	s.Equal("http://host2/stack",
+		notifyD.NotifyEndpoints["host2"].Notifiers[NotifyTypeStack].GetCreateAddr())
+	s.Require().Len(notifyD.Sinks, 2)
+	s.IsType(&TemplateRenderer{}, notifyD.Sinks[0].ServiceNotifier)
+	s.IsType(&RedisSink{}, notifyD.Sinks[1].Notifiers[NotifyTypeStack])
+}
+
+func (s *OptionsTestSuite) Test_OptionsFromEnv_ServiceCreate() {
+	envKeys := []string{"DF_NOTIFY_CREATE_SERVICE_URL",
+		"DF_NOTIF_CREATE_SERVICE_URL",
+		"DF_NOTIFICATION_URL"}
+	for _, envKey := range envKeys {
+		notifyD := s.notifyDistributorFromEnv(map[string]string{
+			envKey: "http://host1,http://host2",
+		})
+
+		s.Require().Len(notifyD.NotifyEndpoints, 2, envKey)
+		ep1, ok1 := notifyD.NotifyEndpoints["host1"]
+		s.Require().True(ok1, envKey)
+		s.Require().NotNil(ep1.ServiceNotifier, envKey)
+		s.Equal("http://host1", ep1.ServiceNotifier.GetCreateAddr())
+
+		ep2, ok2 := notifyD.NotifyEndpoints["host2"]
+		s.Require().True(ok2, envKey)
+		s.Require().NotNil(ep2.ServiceNotifier, envKey)
+		s.Equal("http://host2", ep2.ServiceNotifier.GetCreateAddr())
+	}
+}
+
+func (s *OptionsTestSuite) Test_OptionsFromEnv_ServiceRemove() {
+	envKeys := []string{"DF_NOTIFY_REMOVE_SERVICE_URL",
+		"DF_NOTIF_REMOVE_SERVICE_URL",
+		"DF_NOTIFICATION_URL"}
+	for _, envKey := range envKeys {
+		notifyD := s.notifyDistributorFromEnv(map[string]string{
+			envKey: "http://host1,http://host2",
+		})
+
+		s.Require().Len(notifyD.NotifyEndpoints, 2, envKey)
+		ep1, ok1 := notifyD.NotifyEndpoints["host1"]
+		s.Require().True(ok1, envKey)
+		s.Require().NotNil(ep1.ServiceNotifier, envKey)
+		s.Equal("http://host1", ep1.ServiceNotifier.GetRemoveAddr())
+
+		ep2, ok2 := notifyD.NotifyEndpoints["host2"]
+		s.Require().True(ok2, envKey)
+		s.Require().NotNil(ep2.ServiceNotifier, envKey)
+		s.Equal("http://host2", ep2.ServiceNotifier.GetRemoveAddr())
+	}
+}
+
+func (s *OptionsTestSuite) Test_OptionsFromEnv_Node() {
+	notifyD := s.notifyDistributorFromEnv(map[string]string{
+		"DF_NOTIFY_CREATE_NODE_URL": "http://host1/create,http://host2/create",
+		"DF_NOTIFY_REMOVE_NODE_URL": "http://host1/remove,http://host2/remove",
+	})
+
+	s.Len(notifyD.NotifyEndpoints, 2)
+	ep1, ok1 := notifyD.NotifyEndpoints["host1"]
+	s.True(ok1)
+
+	s.Require().NotNil(ep1.NodeNotifier)
+	s.Equal("http://host1/create", ep1.NodeNotifier.GetCreateAddr())
+	s.Equal("http://host1/remove", ep1.NodeNotifier.GetRemoveAddr())
+
+	ep2, ok2 := notifyD.NotifyEndpoints["host2"]
+	s.True(ok2)
+
+	s.Require().NotNil(ep2.NodeNotifier)
+	s.Equal("http://host2/create", ep2.NodeNotifier.GetCreateAddr())
+	s.Equal("http://host2/remove", ep2.NodeNotifier.GetRemoveAddr())
+}
+
+func (s *OptionsTestSuite) Test_OptionsFromEnv_Task() {
+	notifyD := s.notifyDistributorFromEnv(map[string]string{
+		"DF_NOTIFY_TASK_URL": "http://host1:8080/task,http://host2:8080/task",
+	})
+
+	s.Require().Len(notifyD.NotifyEndpoints, 2)
+	s.Equal("http://host1:8080/task",
+		notifyD.NotifyEndpoints["host1:8080"].Notifiers[NotifyTypeTask].GetCreateAddr())
+	s.Equal("",
+		notifyD.NotifyEndpoints["host1:8080"].Notifiers[NotifyTypeTask].GetRemoveAddr())
+	s.Nil(notifyD.NotifyEndpoints["host2:8080"].ServiceNotifier)
+	s.True(notifyD.HasListeners(NotifyTypeTask))
+}
+
+func (s *OptionsTestSuite) Test_OptionsFromEnv_ConfigSecretAndNetwork() {
+	notifyD := s.notifyDistributorFromEnv(map[string]string{
+		"DF_NOTIFY_CREATE_CONFIG_URL":  "http://host1:8080/config/create",
+		"DF_NOTIFY_REMOVE_CONFIG_URL":  "http://host1:8080/config/remove",
+		"DF_NOTIFY_CREATE_SECRET_URL":  "http://host2:8080/secret/create",
+		"DF_NOTIFY_REMOVE_NETWORK_URL": "http://host2:8080/network/remove",
+	})
+
+	s.Require().Len(notifyD.NotifyEndpoints, 2)
+	configNotifier := notifyD.NotifyEndpoints["host1:8080"].Notifiers[NotifyTypeConfig]
+	s.Equal("http://host1:8080/config/create", configNotifier.GetCreateAddr())
+	s.Equal("http://host1:8080/config/remove", configNotifier.GetRemoveAddr())
+	s.Nil(notifyD.NotifyEndpoints["host1:8080"].Notifiers[NotifyTypeSecret])
+	s.Equal("http://host2:8080/secret/create",
+		notifyD.NotifyEndpoints["host2:8080"].Notifiers[NotifyTypeSecret].GetCreateAddr())
+	s.True(notifyD.HasListeners(NotifyTypeConfig))
+	s.True(notifyD.HasListeners(NotifyTypeSecret))
+	s.Equal("http://host2:8080/network/remove",
+		notifyD.NotifyEndpoints["host2:8080"].Notifiers[NotifyTypeNetwork].GetRemoveAddr())
+	s.False(notifyD.HasListeners(NotifyTypeTask))
+}
+
+func (s *OptionsTestSuite) Test_OptionsFromEnv_TaskWatchInterval() {
+	s.Nil(s.listenerFromEnv(map[string]string{}).TaskWatcher)
+	s.Nil(s.listenerFromEnv(map[string]string{"DF_TASK_WATCH_INTERVAL": "0"}).TaskWatcher)
+
+	l := s.listenerFromEnv(map[string]string{"DF_TASK_WATCH_INTERVAL": "5"})
+	s.Require().NotNil(l.TaskWatcher)
+	s.Equal(5*time.Second, l.TaskWatcher.Interval)
+}
+
+func (s *OptionsTestSuite) Test_OptionsFromEnv_ConsulAndXDS() {
+	l := s.listenerFromEnv(map[string]string{})
+	s.Nil(l.ConsulRegistrar)
+	s.Nil(l.XDSServer)
+
+	l = s.listenerFromEnv(map[string]string{
+		"DF_CONSUL_ADDR": "http://consul:8500",
+		"DF_XDS_ADDR":    ":18000",
+	})
+	s.NotNil(l.ConsulRegistrar)
+	s.NotNil(l.XDSServer)
+}
+
+// listenerFromEnv creates a `SwarmListener` from `env` through
+// `optionsFromEnv`. Variables are unset when the test finishes
+func (s *OptionsTestSuite) listenerFromEnv(env map[string]string) *SwarmListener {
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+	dockerClient, err := NewDockerClientFromEnv()
+	s.Require().NoError(err)
+	logger := log.New(ioutil.Discard, "", 0)
+
+	opts, err := optionsFromEnv(5, 10, "", logger)
+	s.Require().NoError(err)
+	l, err := NewSwarmListener(append(opts, WithDockerClient(dockerClient), WithLogger(logger))...)
+	s.Require().NoError(err)
+	return l
+}
+
+func (s *OptionsTestSuite) notifyDistributorFromEnv(env map[string]string) *NotifyDistributor {
+	return s.listenerFromEnv(env).NotifyDistributor.(*NotifyDistributor)
+}
+
+func (s *OptionsTestSuite) Test_OptionsFromEnv_UnknownRedisType_ReturnsError() {
+	defer func() {
+		os.Unsetenv("DF_REDIS_ADDR")
+		os.Unsetenv("DF_REDIS_TYPES")
+	}()
+	os.Setenv("DF_REDIS_ADDR", "localhost:6379")
+	os.Setenv("DF_REDIS_TYPES", "service,tasks")
+
+	_, err := optionsFromEnv(5, 10, "", log.New(ioutil.Discard, "", 0))
+	s.Error(err)
+}
+
+func (s *OptionsTestSuite) Test_OptionsFromEnv_UnknownNotifyFormat_ReturnsError() {
+	defer os.Unsetenv("DF_NOTIFY_FORMAT")
+	os.Setenv("DF_NOTIFY_FORMAT", "cloudevents")
+
+	_, err := optionsFromEnv(5, 10, "", log.New(ioutil.Discard, "", 0))
+	s.Require().Error(err)
+	s.Contains(err.Error(), "query, cloudevents-binary, cloudevents-structured")
+}
@@ -7,26 +7,45 @@ import (
 	"strings"
 )
 
+const (
+	// listenerParamPrefix namespaces the parameters the listener adds to
+	// the parameters of `com.df.` labels. Labels with the prefix are
+	// ignored, so that they can not overwrite them
+	listenerParamPrefix = "swarmListener."
+	// EventParam is the parameter with the event of service and node
+	// notifications that are not create or remove notifications
+	EventParam = listenerParamPrefix + "event"
+	// DiffParam is the parameter with the JSON encoded `ServiceDiff` of
+	// updated services
+	DiffParam = listenerParamPrefix + "diff"
+)
+
+// labelParamKey returns the parameter key of label `k`
+// Only labels prefixed with `com.df.` are parameters. Labels of the
+// reserved `EventParam` and `DiffParam` keys are not
+func labelParamKey(k string) (string, bool) {
+	if !strings.HasPrefix(k, "com.df.") {
+		return "", false
+	}
+	key := strings.TrimPrefix(k, "com.df.")
+	if len(key) == 0 || key == EventParam || key == DiffParam {
+		return "", false
+	}
+	return key, true
+}
+
 // GetNodeMiniCreateParameters converts `NodeMini` into parameters
 func GetNodeMiniCreateParameters(node NodeMini) map[string]string {
 	params := map[string]string{}
 
 	for k, v := range node.EngineLabels {
-		if !strings.HasPrefix(k, "com.df.") {
-			continue
-		}
-		key := strings.TrimPrefix(k, "com.df.")
-		if len(key) > 0 {
+		if key, ok := labelParamKey(k); ok {
 			params[key] = v
 		}
 	}
 
 	for k, v := range node.NodeLabels {
-		if !strings.HasPrefix(k, "com.df.") {
-			continue
-		}
-		key := strings.TrimPrefix(k, "com.df.")
-		if len(key) > 0 {
+		if key, ok := labelParamKey(k); ok {
 			params[key] = v
 		}
 	}
@@ -45,11 +64,7 @@ func GetNodeMiniCreateParameters(node NodeMini) map[string]string {
 func GetSwarmServiceMiniCreateParameters(ssm SwarmServiceMini) map[string]string {
 	params := map[string]string{}
 	for k, v := range ssm.Labels {
-		if !strings.HasPrefix(k, "com.df.") {
-			continue
-		}
-		key := strings.TrimPrefix(k, "com.df.")
-		if len(key) > 0 {
+		if key, ok := labelParamKey(k); ok {
 			params[key] = v
 		}
 	}
@@ -76,6 +91,52 @@ func GetSwarmServiceMiniCreateParameters(ssm SwarmServiceMini) map[string]string
 		}
 	}
 
+	if ssm.Endpoint != nil {
+		params["endpointMode"] = string(ssm.Endpoint.Mode)
+		if b, err := json.Marshal(ssm.Endpoint.Ports); err == nil {
+			params["endpointPorts"] = string(b)
+		}
+		if b, err := json.Marshal(ssm.Endpoint.VirtualIPs); err == nil {
+			params["virtualIPs"] = string(b)
+		}
+	}
+
+	return params
+}
+
+// GetNetworkMiniCreateParameters converts `NetworkMini` into parameters
+// All labels are encoded as JSON in the `labels` parameter
+func GetNetworkMiniCreateParameters(network NetworkMini) map[string]string {
+	params := map[string]string{}
+
+	for k, v := range network.Labels {
+		if !strings.HasPrefix(k, "com.df.") {
+			continue
+		}
+		key := strings.TrimPrefix(k, "com.df.")
+		if len(key) > 0 {
+			params[key] = v
+		}
+	}
+
+	params["id"] = network.ID
+	params["name"] = network.Name
+	params["driver"] = network.Driver
+	params["scope"] = network.Scope
+	if b, err := json.Marshal(network.Labels); err == nil {
+		params["labels"] = string(b)
+	}
+	return params
+}
+
+// GetNetworkMiniRemoveParameters converts `NetworkMini` into remove parameters
+func GetNetworkMiniRemoveParameters(network NetworkMini) map[string]string {
+	params := map[string]string{}
+	params["id"] = network.ID
+	params["name"] = network.Name
+	params["driver"] = network.Driver
+	params["scope"] = network.Scope
+
 	return params
 }
 
@@ -110,6 +171,49 @@ func GetSwarmServiceMiniRemoveParameters(ssm SwarmServiceMini) map[string]string
 	return params
 }
 
+// GetSwarmServiceMiniFullRemoveParameters converts `SwarmServiceMini` into
+// remove parameters that contain all of its create parameters
+func GetSwarmServiceMiniFullRemoveParameters(ssm SwarmServiceMini) map[string]string {
+	params := GetSwarmServiceMiniCreateParameters(ssm)
+	params[EventParam] = string(EventTypeRemove)
+	return params
+}
+
+// GetNodeMiniFullRemoveParameters converts `NodeMini` into remove parameters
+// that contain all of its create parameters
+func GetNodeMiniFullRemoveParameters(node NodeMini) map[string]string {
+	params := GetNodeMiniCreateParameters(node)
+	params[EventParam] = string(EventTypeRemove)
+	return params
+}
+
+// GetConvergenceErrorParameters converts `ConvergenceError` into parameters
+// The task errors are encoded as a JSON array
+func GetConvergenceErrorParameters(convergenceErr *ConvergenceError) map[string]string {
+	params := map[string]string{}
+	params["event"] = string(EventTypeConvergenceFailed)
+	params["serviceID"] = convergenceErr.ServiceID
+	params["serviceName"] = convergenceErr.ServiceName
+	params["timeout"] = fmt.Sprintf("%d", int64(convergenceErr.Timeout.Seconds()))
+	if b, err := json.Marshal(convergenceErr.TaskErrors); err == nil {
+		params["errors"] = string(b)
+	}
+	return params
+}
+
+// GetServiceDiffParameters converts `ServiceDiff` into parameters
+// The diff is encoded as a JSON object
+func GetServiceDiffParameters(diff ServiceDiff) map[string]string {
+	params := map[string]string{}
+	if diff.ScaleOnly() {
+		params[EventParam] = string(EventTypeServiceScaled)
+	}
+	if b, err := json.Marshal(diff); err == nil {
+		params[DiffParam] = string(b)
+	}
+	return params
+}
+
 // ConvertMapStringStringToURLValues converts params to `url.Values`
 func ConvertMapStringStringToURLValues(params map[string]string) url.Values {
 	values := url.Values{}
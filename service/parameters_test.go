@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/stretchr/testify/suite"
@@ -92,6 +93,25 @@ func (s *ParametersTestSuite) Test_GetNodeMiniRemoveParameters() {
 	s.Equal(expected, params)
 }
 
+func (s *ParametersTestSuite) Test_GetNodeMiniFullRemoveParameters() {
+	nm := getNewNodeMini()
+
+	expected := map[string]string{
+		"id":           "nodeID",
+		"hostname":     "nodehostname",
+		"address":      "nodeaddr",
+		"versionIndex": "10",
+		"state":        "ready",
+		"role":         "worker",
+		"availability": "active",
+		"world":        "round",
+		"wow":          "yup",
+		EventParam:     "remove",
+	}
+	params := GetNodeMiniFullRemoveParameters(nm)
+	s.Equal(expected, params)
+}
+
 func (s *ParametersTestSuite) Test_GetSwarmServiceMiniCreateParameters_Global() {
 	ssm := getNewSwarmServiceMini()
 	ssm.Replicas = uint64(0)
@@ -184,6 +204,33 @@ func (s *ParametersTestSuite) Test_GetSwarmServiceMiniCreateParameters_NoNodeInf
 
 }
 
+func (s *ParametersTestSuite) Test_GetSwarmServiceMiniCreateParameters_Endpoint() {
+	ssm := getNewSwarmServiceMini()
+	ssm.NodeInfo = nil
+	ssm.Endpoint = &EndpointMini{
+		Mode: swarm.ResolutionModeVIP,
+		Ports: []PortMini{
+			{Protocol: swarm.PortConfigProtocolTCP, TargetPort: 80,
+				PublishedPort: 8080, PublishMode: swarm.PortConfigPublishModeIngress},
+		},
+		VirtualIPs: []VirtualIPMini{{NetworkID: "network1", Addr: "10.0.0.5/24"}},
+	}
+
+	expected := map[string]string{
+		"serviceName":  "demo-go",
+		"hello":        "nyc",
+		"distribute":   "true",
+		"replicas":     "3",
+		"endpointMode": "vip",
+		"endpointPorts": `[{"protocol":"tcp","targetPort":80,"publishedPort":8080,` +
+			`"publishMode":"ingress"}]`,
+		"virtualIPs": `[{"networkID":"network1","addr":"10.0.0.5/24"}]`,
+	}
+
+	params := GetSwarmServiceMiniCreateParameters(ssm)
+	s.Equal(expected, params)
+}
+
 func (s *ParametersTestSuite) Test_GetSwarmServiceMiniCreateParameters_StackNamespace_ShortNameTrue_Combines_ServiceName() {
 	ssm := getNewSwarmServiceMini()
 	ssm.Name = "stack_demo-go"
@@ -258,6 +305,23 @@ func (s *ParametersTestSuite) Test_GetSwarmServiceMiniRemoveParameters() {
 	s.Equal(expected, params)
 }
 
+func (s *ParametersTestSuite) Test_GetSwarmServiceMiniFullRemoveParameters() {
+	ssm := getNewSwarmServiceMini()
+	b, err := json.Marshal(ssm.NodeInfo)
+	s.Require().NoError(err)
+
+	expected := map[string]string{
+		"serviceName": "demo-go",
+		"hello":       "nyc",
+		"distribute":  "true",
+		"replicas":    "3",
+		"nodeInfo":    string(b),
+		EventParam:    "remove",
+	}
+	params := GetSwarmServiceMiniFullRemoveParameters(ssm)
+	s.Equal(expected, params)
+}
+
 func (s *ParametersTestSuite) Test_GetSwarmServiceMiniRemoveParameters_ShortNameUndefined_DoesNotCombineServiceName() {
 	ssm := getNewSwarmServiceMini()
 	ssm.Name = "stack_demo-go"
@@ -331,3 +395,97 @@ func (s *ParametersTestSuite) Test_ConvertMapStringStringToURLValues() {
 	}
 
 }
+
+func (s *ParametersTestSuite) Test_GetNetworkMiniCreateParameters() {
+	nm := getNewNetworkMini()
+
+	params := GetNetworkMiniCreateParameters(nm)
+
+	labels := map[string]string{}
+	s.Require().NoError(json.Unmarshal([]byte(params["labels"]), &labels))
+	s.Equal(nm.Labels, labels)
+
+	delete(params, "labels")
+	s.Equal(map[string]string{
+		"id":     "networkID",
+		"name":   "proxy",
+		"driver": "overlay",
+		"scope":  "swarm",
+		"proxy":  "true",
+	}, params)
+}
+
+func (s *ParametersTestSuite) Test_GetNetworkMiniRemoveParameters() {
+	params := GetNetworkMiniRemoveParameters(getNewNetworkMini())
+	s.Equal(map[string]string{
+		"id":     "networkID",
+		"name":   "proxy",
+		"driver": "overlay",
+		"scope":  "swarm",
+	}, params)
+}
+
+func (s *ParametersTestSuite) Test_GetConvergenceErrorParameters() {
+	params := GetConvergenceErrorParameters(&ConvergenceError{
+		ServiceID:   "serviceID1",
+		ServiceName: "demo_go",
+		Timeout:     time.Minute,
+		TaskErrors:  []string{"No such image: demo:bad"},
+	})
+	s.Equal(map[string]string{
+		"event":       "convergence-failed",
+		"serviceID":   "serviceID1",
+		"serviceName": "demo_go",
+		"timeout":     "60",
+		"errors":      `["No such image: demo:bad"]`,
+	}, params)
+}
+
+func (s *ParametersTestSuite) Test_GetServiceDiffParameters_ScaleOnly() {
+	params := GetServiceDiffParameters(ServiceDiff{
+		PreviousReplicas: 2,
+		Replicas:         3,
+	})
+	s.Equal(map[string]string{
+		"swarmListener.event": "service-scaled",
+		"swarmListener.diff":  `{"previousReplicas":2,"replicas":3}`,
+	}, params)
+}
+
+func (s *ParametersTestSuite) Test_GetSwarmServiceMiniCreateParameters_IgnoresReservedParamLabels() {
+	ssm := getNewSwarmServiceMini()
+	ssm.NodeInfo = nil
+	ssm.Labels["com.df.swarmListener.event"] = "service-scaled"
+	ssm.Labels["com.df.swarmListener.diff"] = "{}"
+	ssm.Labels["com.df.swarmListener.group"] = "blue"
+	ssm.Labels["com.df.event"] = "deploy"
+
+	params := GetSwarmServiceMiniCreateParameters(ssm)
+	s.NotContains(params, "swarmListener.event")
+	s.NotContains(params, "swarmListener.diff")
+	s.Equal("blue", params["swarmListener.group"])
+	s.Equal("deploy", params["event"])
+}
+
+func (s *ParametersTestSuite) Test_GetNodeMiniCreateParameters_IgnoresReservedParamLabels() {
+	node := NodeMini{
+		ID:         "nodeID1",
+		NodeLabels: map[string]string{"com.df.swarmListener.event": "remove"},
+	}
+
+	params := GetNodeMiniCreateParameters(node)
+	s.NotContains(params, "swarmListener.event")
+}
+
+func (s *ParametersTestSuite) Test_GetServiceDiffParameters_Labels() {
+	params := GetServiceDiffParameters(ServiceDiff{
+		LabelsChanged: map[string]LabelChange{
+			"com.df.port": {Previous: "80", Current: "8080"},
+		},
+		PreviousReplicas: 2,
+		Replicas:         3,
+	})
+	s.Equal(map[string]string{
+		"swarmListener.diff": `{"labelsChanged":{"com.df.port":{"previous":"80","current":"8080"}},"previousReplicas":2,"replicas":3}`,
+	}, params)
+}
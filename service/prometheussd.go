@@ -0,0 +1,54 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+)
+
+// PrometheusTargetGroup is a target group in the Prometheus `http_sd_config`
+// format
+type PrometheusTargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// GetPrometheusTargetGroups converts services into Prometheus target groups
+// Only services with the `com.df.scrapePort` label are included. When
+// a service has node info, there is one target group per task address,
+// otherwise the service name is used as the target.
+func GetPrometheusTargetGroups(services []SwarmServiceMini) []PrometheusTargetGroup {
+	groups := []PrometheusTargetGroup{}
+	for _, ssm := range services {
+		port, ok := ssm.Labels["com.df.scrapePort"]
+		if !ok || len(port) == 0 {
+			continue
+		}
+		serviceName := GetSwarmServiceMiniCreateParameters(ssm)["serviceName"]
+
+		if ssm.NodeInfo.Cardinality() == 0 {
+			groups = append(groups, PrometheusTargetGroup{
+				Targets: []string{fmt.Sprintf("%s:%s", serviceName, port)},
+				Labels: map[string]string{
+					"service": serviceName,
+				},
+			})
+			continue
+		}
+
+		for nodeIP := range ssm.NodeInfo {
+			groups = append(groups, PrometheusTargetGroup{
+				Targets: []string{fmt.Sprintf("%s:%s", nodeIP.Addr, port)},
+				Labels: map[string]string{
+					"service": serviceName,
+					"node":    nodeIP.Name,
+					"node_id": nodeIP.ID,
+				},
+			})
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Targets[0] < groups[j].Targets[0]
+	})
+	return groups
+}
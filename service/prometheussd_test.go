@@ -0,0 +1,69 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PrometheusSDTestSuite struct {
+	suite.Suite
+}
+
+func TestPrometheusSDUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(PrometheusSDTestSuite))
+}
+
+func (s *PrometheusSDTestSuite) Test_GetPrometheusTargetGroups_NoScrapePort_ReturnsEmpty() {
+	ssm := getNewSwarmServiceMini()
+
+	groups := GetPrometheusTargetGroups([]SwarmServiceMini{ssm})
+	s.Empty(groups)
+}
+
+func (s *PrometheusSDTestSuite) Test_GetPrometheusTargetGroups_WithNodeInfo() {
+	ssm := getNewSwarmServiceMini()
+	ssm.Labels["com.df.scrapePort"] = "8080"
+	ssm.NodeInfo.Add("node-2", "1.0.0.2", "id2")
+
+	groups := GetPrometheusTargetGroups([]SwarmServiceMini{ssm})
+
+	s.Equal([]PrometheusTargetGroup{
+		{
+			Targets: []string{"1.0.0.1:8080"},
+			Labels: map[string]string{
+				"service": "demo-go",
+				"node":    "node-1",
+				"node_id": "id1",
+			},
+		},
+		{
+			Targets: []string{"1.0.0.2:8080"},
+			Labels: map[string]string{
+				"service": "demo-go",
+				"node":    "node-2",
+				"node_id": "id2",
+			},
+		},
+	}, groups)
+}
+
+func (s *PrometheusSDTestSuite) Test_GetPrometheusTargetGroups_WithoutNodeInfo_UsesServiceName() {
+	ssm := getNewSwarmServiceMini()
+	ssm.Name = "stack_demo-go"
+	ssm.Labels["com.df.scrapePort"] = "8080"
+	ssm.Labels["com.df.shortName"] = "true"
+	ssm.Labels["com.docker.stack.namespace"] = "stack"
+	ssm.NodeInfo = nil
+
+	groups := GetPrometheusTargetGroups([]SwarmServiceMini{ssm})
+
+	s.Equal([]PrometheusTargetGroup{
+		{
+			Targets: []string{"demo-go:8080"},
+			Labels: map[string]string{
+				"service": "demo-go",
+			},
+		},
+	}, groups)
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
+)
+
+// Reconciler periodically compares the services and nodes of the swarm
+// with `SSCache` and `NodeCache`
+// The caches are only updated by docker events, so a missed event leaves
+// them out of date until the service or node changes again. Services and
+// nodes that drifted from the caches are placed on the event channels,
+// which updates the caches and sends notifications like docker events do
+type Reconciler struct {
+	SSClient            SwarmServiceInspector
+	SSCache             SwarmServiceCacher
+	NodeClient          NodeInspector
+	NodeCache           NodeCacher
+	Interval            time.Duration
+	IncludeNodeInfo     bool
+	IncludeEndpointInfo bool
+	IgnoreKey           string
+	IncludeKey          string
+	log                 *log.Logger
+}
+
+// NewReconciler creates a `Reconciler`
+func NewReconciler(
+	ssClient SwarmServiceInspector,
+	ssCache SwarmServiceCacher,
+	nodeClient NodeInspector,
+	nodeCache NodeCacher,
+	interval time.Duration,
+	logger *log.Logger,
+) *Reconciler {
+	return &Reconciler{
+		SSClient:   ssClient,
+		SSCache:    ssCache,
+		NodeClient: nodeClient,
+		NodeCache:  nodeCache,
+		Interval:   interval,
+		log:        logger,
+	}
+}
+
+// Run reconciles services and nodes every `Interval`
+// Drifted services are placed on `serviceEventChan` and drifted nodes on
+// `nodeEventChan`. Nil channels are skipped
+func (r *Reconciler) Run(serviceEventChan chan<- Event, nodeEventChan chan<- Event) {
+	go func() {
+		ticker := time.NewTicker(r.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if serviceEventChan != nil {
+				r.placeOnEventChan(serviceEventChan, r.ReconcileServices(context.Background()))
+			}
+			if nodeEventChan != nil {
+				r.placeOnEventChan(nodeEventChan, r.ReconcileNodes(context.Background()))
+			}
+		}
+	}()
+}
+
+// ReconcileServices returns the events that bring `SSCache` up to date
+// Services that are not cached or changed are returned as create events
+// and cached services that no longer exist as remove events
+func (r *Reconciler) ReconcileServices(ctx context.Context) []Event {
+	services, err := r.SSClient.SwarmServiceList(ctx, r.IncludeNodeInfo)
+	if err != nil {
+		r.log.Printf("ERROR: Unable to reconcile services: %v", err)
+		metrics.RecordError("reconcileServices")
+		return []Event{}
+	}
+
+	nowTimeNano := time.Now().UTC().UnixNano()
+	events := []Event{}
+	running := map[string]struct{}{}
+	for _, s := range services {
+		running[s.ID] = struct{}{}
+		ssm := minifySwarmService(s, r.IgnoreKey, r.IncludeKey, r.IncludeEndpointInfo)
+		if cached, ok := r.SSCache.Get(s.ID); ok && cached.Equal(ssm) {
+			continue
+		}
+		events = append(events, Event{Type: EventTypeCreate, ID: s.ID, TimeNano: nowTimeNano})
+	}
+	for _, ssm := range r.SSCache.GetAll() {
+		if _, ok := running[ssm.ID]; !ok {
+			events = append(events, Event{Type: EventTypeRemove, ID: ssm.ID, TimeNano: nowTimeNano})
+		}
+	}
+	metrics.RecordReconcileDrift("service", len(events))
+	return events
+}
+
+// ReconcileNodes returns the events that bring `NodeCache` up to date
+// Nodes that are not cached or changed are returned as create events and
+// cached nodes that left the swarm as remove events
+func (r *Reconciler) ReconcileNodes(ctx context.Context) []Event {
+	nodes, err := r.NodeClient.NodeList(ctx)
+	if err != nil {
+		r.log.Printf("ERROR: Unable to reconcile nodes: %v", err)
+		metrics.RecordError("reconcileNodes")
+		return []Event{}
+	}
+
+	nowTimeNano := time.Now().UTC().UnixNano()
+	events := []Event{}
+	running := map[string]struct{}{}
+	for _, n := range nodes {
+		running[n.ID] = struct{}{}
+		if cached, ok := r.NodeCache.Get(n.ID); ok && cached.Equal(MinifyNode(n)) {
+			continue
+		}
+		events = append(events, Event{Type: EventTypeCreate, ID: n.ID, TimeNano: nowTimeNano})
+	}
+	for _, nm := range r.NodeCache.GetAll() {
+		if _, ok := running[nm.ID]; !ok {
+			events = append(events, Event{Type: EventTypeRemove, ID: nm.ID, TimeNano: nowTimeNano})
+		}
+	}
+	metrics.RecordReconcileDrift("node", len(events))
+	return events
+}
+
+func (r *Reconciler) placeOnEventChan(eventChan chan<- Event, events []Event) {
+	for _, event := range events {
+		eventChan <- event
+	}
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReconcilerTestSuite struct {
+	suite.Suite
+	SSClientMock   *swarmServiceInspector
+	NodeClientMock *nodeInspectorMock
+	Reconciler     *Reconciler
+	LogBytes       *bytes.Buffer
+}
+
+func TestReconcilerUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ReconcilerTestSuite))
+}
+
+func (s *ReconcilerTestSuite) SetupTest() {
+	s.SSClientMock = new(swarmServiceInspector)
+	s.NodeClientMock = new(nodeInspectorMock)
+	s.LogBytes = new(bytes.Buffer)
+	s.Reconciler = NewReconciler(s.SSClientMock, NewSwarmServiceCache(),
+		s.NodeClientMock, NewNodeCache(), time.Millisecond, log.New(s.LogBytes, "", 0))
+	s.Reconciler.IgnoreKey = "com.df.notify"
+	s.Reconciler.IncludeKey = "com.docker.stack.namespace"
+}
+
+func (s *ReconcilerTestSuite) Test_ReconcileServices() {
+	unchanged := SwarmService{swarm.Service{ID: "serviceID1",
+		Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "serviceName1"}}}, nil}
+	changed := SwarmService{swarm.Service{ID: "serviceID2",
+		Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{
+			Name:   "serviceName2",
+			Labels: map[string]string{"com.df.notify": "true", "com.df.port": "8080"},
+		}}}, nil}
+	created := SwarmService{swarm.Service{ID: "serviceID3",
+		Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "serviceName3"}}}, nil}
+
+	s.Reconciler.SSCache.InsertAndCheck(
+		MinifySwarmService(unchanged, "com.df.notify", "com.docker.stack.namespace"))
+	s.Reconciler.SSCache.InsertAndCheck(SwarmServiceMini{ID: "serviceID2", Name: "serviceName2",
+		Labels: map[string]string{"com.df.notify": "true", "com.df.port": "80"}})
+	s.Reconciler.SSCache.InsertAndCheck(SwarmServiceMini{ID: "serviceID4", Name: "serviceName4"})
+
+	s.SSClientMock.On("SwarmServiceList", mock.Anything, false).
+		Return([]SwarmService{unchanged, changed, created}, nil)
+
+	events := s.Reconciler.ReconcileServices(context.Background())
+	s.Require().Len(events, 3)
+	s.Equal("serviceID2", events[0].ID)
+	s.Equal(EventTypeCreate, events[0].Type)
+	s.Equal("serviceID3", events[1].ID)
+	s.Equal(EventTypeCreate, events[1].Type)
+	s.Equal("serviceID4", events[2].ID)
+	s.Equal(EventTypeRemove, events[2].Type)
+	s.SSClientMock.AssertExpectations(s.T())
+}
+
+func (s *ReconcilerTestSuite) Test_ReconcileServices_IncludeEndpointInfo() {
+	s.Reconciler.IncludeEndpointInfo = true
+	ss := SwarmService{swarm.Service{ID: "serviceID1",
+		Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "serviceName1"}},
+		Endpoint: swarm.Endpoint{
+			Ports: []swarm.PortConfig{{TargetPort: 80, PublishedPort: 8081}},
+		}}, nil}
+	cached := minifySwarmService(ss, "com.df.notify", "com.docker.stack.namespace", true)
+	cached.Endpoint.Ports[0].PublishedPort = 8080
+	s.Reconciler.SSCache.InsertAndCheck(cached)
+
+	s.SSClientMock.On("SwarmServiceList", mock.Anything, false).
+		Return([]SwarmService{ss}, nil)
+
+	events := s.Reconciler.ReconcileServices(context.Background())
+	s.Require().Len(events, 1)
+	s.Equal("serviceID1", events[0].ID)
+	s.Equal(EventTypeCreate, events[0].Type)
+}
+
+func (s *ReconcilerTestSuite) Test_ReconcileServices_ListError() {
+	s.Reconciler.SSCache.InsertAndCheck(SwarmServiceMini{ID: "serviceID1"})
+	s.SSClientMock.On("SwarmServiceList", mock.Anything, false).
+		Return([]SwarmService{}, errors.New("daemon unavailable"))
+
+	events := s.Reconciler.ReconcileServices(context.Background())
+	s.Empty(events)
+	s.Contains(s.LogBytes.String(), "Unable to reconcile services: daemon unavailable")
+}
+
+func (s *ReconcilerTestSuite) Test_ReconcileNodes() {
+	unchanged := swarm.Node{ID: "nodeID1", Description: swarm.NodeDescription{Hostname: "node1"}}
+	changed := swarm.Node{ID: "nodeID2", Description: swarm.NodeDescription{Hostname: "node2"},
+		Status: swarm.NodeStatus{State: swarm.NodeStateDown}}
+	created := swarm.Node{ID: "nodeID3", Description: swarm.NodeDescription{Hostname: "node3"}}
+
+	s.Reconciler.NodeCache.InsertAndCheck(MinifyNode(unchanged))
+	changedMini := MinifyNode(changed)
+	changedMini.State = swarm.NodeStateReady
+	s.Reconciler.NodeCache.InsertAndCheck(changedMini)
+	s.Reconciler.NodeCache.InsertAndCheck(NodeMini{ID: "nodeID4", Hostname: "node4"})
+
+	s.NodeClientMock.On("NodeList", mock.Anything).
+		Return([]swarm.Node{unchanged, changed, created}, nil)
+
+	events := s.Reconciler.ReconcileNodes(context.Background())
+	s.Require().Len(events, 3)
+	s.Equal("nodeID2", events[0].ID)
+	s.Equal(EventTypeCreate, events[0].Type)
+	s.Equal("nodeID3", events[1].ID)
+	s.Equal(EventTypeCreate, events[1].Type)
+	s.Equal("nodeID4", events[2].ID)
+	s.Equal(EventTypeRemove, events[2].Type)
+	s.NodeClientMock.AssertExpectations(s.T())
+}
+
+func (s *ReconcilerTestSuite) Test_Run_PlacesDriftOnEventChannels() {
+	s.SSClientMock.On("SwarmServiceList", mock.Anything, false).
+		Return([]SwarmService{{swarm.Service{ID: "serviceID1"}, nil}}, nil)
+	serviceEventChan := make(chan Event)
+
+	s.Reconciler.Run(serviceEventChan, nil)
+
+	select {
+	case event := <-serviceEventChan:
+		s.Equal("serviceID1", event.ID)
+		s.Equal(EventTypeCreate, event.Type)
+	case <-time.NewTimer(time.Second * 5).C:
+		s.Fail("Timeout")
+	}
+	s.NodeClientMock.AssertNotCalled(s.T(), "NodeList", mock.Anything)
+}
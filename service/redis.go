@@ -0,0 +1,380 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
+)
+
+const (
+	redisDefaultChannel = "docker-flow-swarm-listener"
+	redisDefaultMaxLen  = 10000
+	redisTimeout        = 10 * time.Second
+	redisDefaultTypes   = "service,node"
+)
+
+// redisError is an error reply of the Redis server
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// redisClient sends commands to a Redis server with the RESP protocol
+// The connection is opened on the first command and reopened after an
+// error
+type redisClient struct {
+	addr     string
+	password string
+	conn     net.Conn
+	reader   *bufio.Reader
+	mux      sync.Mutex
+}
+
+func newRedisClient(addr, password string) *redisClient {
+	return &redisClient{
+		addr:     strings.TrimPrefix(addr, "redis://"),
+		password: password,
+	}
+}
+
+// Do sends a command and returns its reply
+func (c *redisClient) Do(ctx context.Context, args ...string) (interface{}, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.conn == nil {
+		if err := c.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := c.do(ctx, args...)
+	if _, ok := err.(redisError); err != nil && !ok {
+		c.close()
+	}
+	return reply, err
+}
+
+// Close closes the connection
+func (c *redisClient) Close() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.close()
+}
+
+func (c *redisClient) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: redisTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+	if len(c.password) > 0 {
+		if _, err := c.do(ctx, "AUTH", c.password); err != nil {
+			c.close()
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *redisClient) close() {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.conn = nil
+	c.reader = nil
+}
+
+func (c *redisClient) do(ctx context.Context, args ...string) (interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisTimeout)
+	}
+	c.conn.SetDeadline(deadline)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return nil, err
+	}
+	return readRedisReply(c.reader)
+}
+
+// readRedisReply reads a RESP reply. Simple strings and bulk strings are
+// returned as strings, integers as int64, arrays as []interface{}, and
+// error replies as `redisError`
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) == 0 {
+		return nil, errors.New("Empty redis reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:size]), nil
+	case '*':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		replies := make([]interface{}, size)
+		for i := range replies {
+			if replies[i], err = readRedisReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return replies, nil
+	}
+	return nil, fmt.Errorf("Unknown redis reply: %s", line)
+}
+
+// RedisSink publishes notifications to a Redis channel and appends them
+// to a Redis stream. Notifications are encoded as structured CloudEvents.
+// It implements `NotificationSender` so that it can be placed on a
+// `NotifyEndpoint`
+type RedisSink struct {
+	Addr       string
+	Channel    string
+	Stream     string
+	MaxLen     int
+	notifyType string
+	source     string
+	retries    int
+	interval   int
+	client     *redisClient
+	log        *log.Logger
+}
+
+// NewRedisSink creates a `RedisSink`
+// Notifications are published to `channel` and appended to `stream`, when
+// they are not empty. The stream is capped at approximately `maxLen`
+// entries
+func NewRedisSink(
+	addr, password, channel, stream string, maxLen int,
+	notifyType, source string, retries, interval int, logger *log.Logger) *RedisSink {
+	if len(source) == 0 {
+		source = cloudEventsSource
+	}
+	return &RedisSink{
+		Addr:       addr,
+		Channel:    channel,
+		Stream:     stream,
+		MaxLen:     maxLen,
+		notifyType: notifyType,
+		source:     source,
+		retries:    retries,
+		interval:   interval,
+		client:     newRedisClient(addr, password),
+		log:        logger,
+	}
+}
+
+// NewRedisSinkFromEnv creates a `RedisSink` from environment variables
+// `DF_REDIS_ADDR`, `DF_REDIS_PASSWORD`, `DF_REDIS_CHANNEL`,
+// `DF_REDIS_STREAM`, and `DF_REDIS_STREAM_MAX_LEN`. Returns nil when
+// `DF_REDIS_ADDR` is not set
+func NewRedisSinkFromEnv(notifyType, source string, retries, interval int, logger *log.Logger) *RedisSink {
+	addr := os.Getenv("DF_REDIS_ADDR")
+	if len(addr) == 0 {
+		return nil
+	}
+	channel := os.Getenv("DF_REDIS_CHANNEL")
+	stream := os.Getenv("DF_REDIS_STREAM")
+	if len(channel) == 0 && len(stream) == 0 {
+		channel = redisDefaultChannel
+	}
+	maxLen := redisDefaultMaxLen
+	if maxLenStr := os.Getenv("DF_REDIS_STREAM_MAX_LEN"); len(maxLenStr) > 0 {
+		if m, err := strconv.Atoi(maxLenStr); err == nil {
+			maxLen = m
+		}
+	}
+	return NewRedisSink(
+		addr, os.Getenv("DF_REDIS_PASSWORD"), channel, stream, maxLen,
+		notifyType, source, retries, interval, logger)
+}
+
+// ForType returns a sink that publishes notifications of `notifyType`
+// The returned sink shares the connection of `r`
+func (r *RedisSink) ForType(notifyType NotifyType) *RedisSink {
+	sink := *r
+	sink.notifyType = string(notifyType)
+	return &sink
+}
+
+// NewRedisEndpoint creates a `NotifyEndpoint` that publishes notifications
+// of `notifyTypes` with `sink`. All types share the connection of `sink`
+func NewRedisEndpoint(sink *RedisSink, notifyTypes []NotifyType) NotifyEndpoint {
+	ep := NotifyEndpoint{}
+	for _, notifyType := range notifyTypes {
+		switch notifyType {
+		case NotifyTypeService:
+			ep.ServiceChan = make(chan internalNotification)
+			ep.ServiceNotifier = sink.ForType(notifyType)
+		case NotifyTypeNode:
+			ep.NodeChan = make(chan internalNotification)
+			ep.NodeNotifier = sink.ForType(notifyType)
+		default:
+			if ep.Notifiers == nil {
+				ep.Notifiers = map[NotifyType]NotificationSender{}
+			}
+			ep.Notifiers[notifyType] = sink.ForType(notifyType)
+		}
+	}
+	return ep
+}
+
+// RedisTypesFromEnv returns the notification types of `DF_REDIS_TYPES`
+// Defaults to service and node notifications. An error is returned for
+// unknown types
+func RedisTypesFromEnv() ([]NotifyType, error) {
+	typesStr := os.Getenv("DF_REDIS_TYPES")
+	if len(typesStr) == 0 {
+		typesStr = redisDefaultTypes
+	}
+	notifyTypes := []NotifyType{}
+	for _, t := range strings.Split(typesStr, ",") {
+		if t = strings.TrimSpace(t); len(t) > 0 {
+			notifyType, err := parseNotifyType(t)
+			if err != nil {
+				return nil, fmt.Errorf("DF_REDIS_TYPES: %v", err)
+			}
+			notifyTypes = append(notifyTypes, notifyType)
+		}
+	}
+	return notifyTypes, nil
+}
+
+// Create publishes a create notification
+func (r *RedisSink) Create(ctx context.Context, params string) error {
+	return r.send(ctx, EventTypeCreate, params)
+}
+
+// Remove publishes a remove notification
+func (r *RedisSink) Remove(ctx context.Context, params string) error {
+	return r.send(ctx, EventTypeRemove, params)
+}
+
+// GetCreateAddr returns the address of the Redis server
+func (r *RedisSink) GetCreateAddr() string {
+	return r.Addr
+}
+
+// GetRemoveAddr returns the address of the Redis server
+func (r *RedisSink) GetRemoveAddr() string {
+	return r.Addr
+}
+
+// send publishes the notification to the channel and appends it to the
+// stream. Each command is retried on its own, so that a failed XADD does
+// not publish the notification to the channel again
+func (r *RedisSink) send(ctx context.Context, eventType EventType, params string) error {
+	n, _ := notificationFromContext(ctx)
+	event, err := NewCloudEvent(r.notifyType, eventType, r.source, n, params)
+	if err != nil {
+		r.log.Printf("ERROR: %v", err)
+		metrics.RecordError("redisPublish")
+		return err
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		r.log.Printf("ERROR: %v", err)
+		metrics.RecordError("redisPublish")
+		return err
+	}
+
+	var attempt notificationAttempt
+	defer func() {
+		recordNotificationAttempt(ctx, r.Addr, attempt)
+	}()
+	for _, args := range r.commands(event.Type, string(payload)) {
+		attempts, err := r.retry(ctx, eventType, args)
+		if attempts > attempt.Attempts {
+			attempt.Attempts = attempts
+		}
+		if err == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			r.log.Printf("Canceling %s %s notification to redis %s", r.notifyType, eventType, r.Addr)
+			attempt.Canceled = true
+			return nil
+		}
+		r.log.Printf("ERROR: Unable to publish %s %s notification to redis %s: %v", r.notifyType, eventType, r.Addr, err)
+		metrics.RecordError("redisPublish")
+		return err
+	}
+	return nil
+}
+
+// commands returns the commands that send `payload` to the channel and
+// the stream of the sink
+func (r *RedisSink) commands(eventType, payload string) [][]string {
+	commands := [][]string{}
+	if len(r.Channel) > 0 {
+		commands = append(commands, []string{"PUBLISH", r.Channel, payload})
+	}
+	if len(r.Stream) > 0 {
+		args := []string{"XADD", r.Stream}
+		if r.MaxLen > 0 {
+			args = append(args, "MAXLEN", "~", strconv.Itoa(r.MaxLen))
+		}
+		args = append(args, "*", "type", eventType, "event", payload)
+		commands = append(commands, args)
+	}
+	return commands
+}
+
+// retry sends the command `args` and retries until it succeeds
+// The number of attempts is returned
+func (r *RedisSink) retry(ctx context.Context, eventType EventType, args []string) (int, error) {
+	for i := 1; ; i++ {
+		_, err := r.client.Do(ctx, args...)
+		if err == nil || ctx.Err() != nil || i > r.retries || r.interval <= 0 {
+			return i, err
+		}
+		r.log.Printf("Retrying %s %s notification to redis %s with %s (%d try)", r.notifyType, eventType, r.Addr, args[0], i)
+		time.Sleep(time.Second * time.Duration(r.interval))
+	}
+}
@@ -0,0 +1,346 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeRedisServer is an in-process stand-in that speaks the RESP protocol
+// It records received commands and replies to them with `reply`
+type fakeRedisServer struct {
+	listener net.Listener
+	mux      sync.Mutex
+	commands [][]string
+	conns    int
+	reply    func(args []string) string
+}
+
+func newFakeRedisServer() (*fakeRedisServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	srv := &fakeRedisServer{
+		listener: listener,
+		reply: func(args []string) string {
+			if args[0] == "PUBLISH" {
+				return ":1\r\n"
+			}
+			return "+OK\r\n"
+		},
+	}
+	go srv.serve()
+	return srv, nil
+}
+
+func (f *fakeRedisServer) Addr() string {
+	return f.listener.Addr().String()
+}
+
+func (f *fakeRedisServer) Close() {
+	f.listener.Close()
+}
+
+func (f *fakeRedisServer) Commands() [][]string {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return f.commands
+}
+
+func (f *fakeRedisServer) Conns() int {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return f.conns
+}
+
+func (f *fakeRedisServer) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		f.mux.Lock()
+		f.conns++
+		f.mux.Unlock()
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeRedisServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readFakeRedisCommand(r)
+		if err != nil {
+			return
+		}
+		f.mux.Lock()
+		f.commands = append(f.commands, args)
+		reply := f.reply(args)
+		f.mux.Unlock()
+		io.WriteString(conn, reply)
+	}
+}
+
+func readFakeRedisCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, count)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+type RedisTestSuite struct {
+	suite.Suite
+	Server   *fakeRedisServer
+	Logger   *log.Logger
+	LogBytes *bytes.Buffer
+	Ctx      context.Context
+}
+
+func TestRedisUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(RedisTestSuite))
+}
+
+func (s *RedisTestSuite) SetupTest() {
+	srv, err := newFakeRedisServer()
+	s.Require().NoError(err)
+	s.Server = srv
+	s.LogBytes = new(bytes.Buffer)
+	s.Logger = log.New(s.LogBytes, "", 0)
+	s.Ctx = contextWithNotification(context.Background(), Notification{
+		EventType: EventTypeCreate, ID: "serviceID1", TimeNano: 1000000000,
+	})
+}
+
+func (s *RedisTestSuite) TearDownTest() {
+	s.Server.Close()
+}
+
+func (s *RedisTestSuite) Test_Create_PublishesCloudEventToChannel() {
+	sink := NewRedisSink(
+		s.Server.Addr(), "", "swarm", "", 0, "service", "cluster1", 1, 0, s.Logger)
+
+	err := sink.Create(s.Ctx, "serviceName=hello")
+	s.Require().NoError(err)
+
+	commands := s.Server.Commands()
+	s.Require().Len(commands, 1)
+	s.Equal([]string{"PUBLISH", "swarm"}, commands[0][:2])
+
+	var event CloudEvent
+	s.Require().NoError(json.Unmarshal([]byte(commands[0][2]), &event))
+	s.Equal(CloudEvent{
+		SpecVersion:     "1.0",
+		Type:            "com.dockerflow.swarm.service.created",
+		Source:          "cluster1",
+		ID:              "serviceID1-1000000000",
+		Time:            "1970-01-01T00:00:01Z",
+		Subject:         "serviceID1",
+		DataContentType: "application/json",
+		Data:            map[string]string{"serviceName": "hello"},
+	}, event)
+}
+
+func (s *RedisTestSuite) Test_Remove_AppendsToCappedStream() {
+	sink := NewRedisSink(
+		s.Server.Addr(), "", "", "swarm-events", 100, "node", "", 1, 0, s.Logger)
+
+	err := sink.Remove(s.Ctx, "id=nodeID1")
+	s.Require().NoError(err)
+
+	commands := s.Server.Commands()
+	s.Require().Len(commands, 1)
+	s.Require().Len(commands[0], 10)
+	s.Equal([]string{
+		"XADD", "swarm-events", "MAXLEN", "~", "100", "*",
+		"type", "com.dockerflow.swarm.node.removed", "event"}, commands[0][:9])
+
+	var event CloudEvent
+	s.Require().NoError(json.Unmarshal([]byte(commands[0][9]), &event))
+	s.Equal("docker-flow-swarm-listener", event.Source)
+	s.Equal(map[string]string{"id": "nodeID1"}, event.Data)
+}
+
+func (s *RedisTestSuite) Test_Create_Password_AuthenticatesOnce() {
+	sink := NewRedisSink(
+		s.Server.Addr(), "secret", "swarm", "", 0, "service", "", 1, 0, s.Logger)
+
+	s.Require().NoError(sink.Create(s.Ctx, "serviceName=hello"))
+	s.Require().NoError(sink.Create(s.Ctx, "serviceName=world"))
+
+	commands := s.Server.Commands()
+	s.Require().Len(commands, 3)
+	s.Equal([]string{"AUTH", "secret"}, commands[0])
+	s.Equal("PUBLISH", commands[1][0])
+	s.Equal("PUBLISH", commands[2][0])
+}
+
+func (s *RedisTestSuite) Test_Create_ErrorReply_ReturnsError() {
+	s.Server.reply = func(args []string) string {
+		return "-ERR unknown command\r\n"
+	}
+	sink := NewRedisSink(
+		s.Server.Addr(), "", "swarm", "", 0, "service", "", 1, 0, s.Logger)
+
+	err := sink.Create(s.Ctx, "serviceName=hello")
+	s.Require().Error(err)
+	s.Equal("ERR unknown command", err.Error())
+	s.Contains(s.LogBytes.String(), "ERROR: Unable to publish service create notification to redis")
+}
+
+func (s *RedisTestSuite) Test_Create_StreamError_RetriesOnlyStream() {
+	xaddFailed := false
+	s.Server.reply = func(args []string) string {
+		if args[0] == "XADD" && !xaddFailed {
+			xaddFailed = true
+			return "-LOADING Redis is loading the dataset in memory\r\n"
+		}
+		if args[0] == "PUBLISH" {
+			return ":1\r\n"
+		}
+		return "+OK\r\n"
+	}
+	sink := NewRedisSink(
+		s.Server.Addr(), "", "swarm", "swarm-events", 0, "service", "", 1, 1, s.Logger)
+
+	s.Require().NoError(sink.Create(s.Ctx, "serviceName=hello"))
+
+	commands := s.Server.Commands()
+	s.Require().Len(commands, 3)
+	s.Equal("PUBLISH", commands[0][0])
+	s.Equal("XADD", commands[1][0])
+	s.Equal("XADD", commands[2][0])
+	s.Contains(s.LogBytes.String(), "Retrying service create notification to redis")
+}
+
+func (s *RedisTestSuite) Test_Create_ServerDown_ReturnsError() {
+	addr := s.Server.Addr()
+	s.Server.Close()
+	sink := NewRedisSink(addr, "", "swarm", "", 0, "service", "", 1, 0, s.Logger)
+
+	err := sink.Create(s.Ctx, "serviceName=hello")
+	s.Error(err)
+}
+
+func (s *RedisTestSuite) Test_Create_CanceledContext_ReturnsNil() {
+	ctx, cancel := context.WithCancel(s.Ctx)
+	cancel()
+	sink := NewRedisSink(
+		s.Server.Addr(), "", "swarm", "", 0, "service", "", 1, 0, s.Logger)
+
+	err := sink.Create(ctx, "serviceName=hello")
+	s.NoError(err)
+	s.Contains(s.LogBytes.String(), "Canceling service create notification to redis")
+}
+
+func (s *RedisTestSuite) Test_ReadRedisReply_ParsesReplies() {
+	r := bufio.NewReader(strings.NewReader(
+		"+OK\r\n:3\r\n$5\r\nhello\r\n$-1\r\n*2\r\n:1\r\n$1\r\na\r\n"))
+
+	for _, expected := range []interface{}{
+		"OK", int64(3), "hello", nil, []interface{}{int64(1), "a"},
+	} {
+		reply, err := readRedisReply(r)
+		s.Require().NoError(err)
+		s.Equal(expected, reply)
+	}
+}
+
+func (s *RedisTestSuite) Test_NewRedisEndpoint_SharesConnection() {
+	sink := NewRedisSink(
+		s.Server.Addr(), "", "swarm", "", 0, "service", "cluster1", 1, 0, s.Logger)
+	ep := NewRedisEndpoint(sink, []NotifyType{NotifyTypeService, NotifyTypeTask})
+
+	s.Require().NotNil(ep.ServiceNotifier)
+	s.Nil(ep.NodeNotifier)
+	s.Require().Len(ep.Notifiers, 1)
+	s.Require().NotNil(ep.Notifiers[NotifyTypeTask])
+
+	s.Require().NoError(ep.ServiceNotifier.Create(s.Ctx, "serviceName=demo"))
+	s.Require().NoError(ep.Notifiers[NotifyTypeTask].Create(s.Ctx, "serviceName=demo"))
+
+	commands := s.Server.Commands()
+	s.Require().Len(commands, 2)
+	s.Contains(commands[0][2], "com.dockerflow.swarm.service.created")
+	s.Contains(commands[1][2], "com.dockerflow.swarm.task.created")
+	s.Equal(1, s.Server.Conns())
+}
+
+func (s *RedisTestSuite) Test_RedisTypesFromEnv() {
+	defer os.Unsetenv("DF_REDIS_TYPES")
+	notifyTypes, err := RedisTypesFromEnv()
+	s.Require().NoError(err)
+	s.Equal([]NotifyType{NotifyTypeService, NotifyTypeNode}, notifyTypes)
+
+	os.Setenv("DF_REDIS_TYPES", "service, stack")
+	notifyTypes, err = RedisTypesFromEnv()
+	s.Require().NoError(err)
+	s.Equal([]NotifyType{NotifyTypeService, NotifyTypeStack}, notifyTypes)
+}
+
+func (s *RedisTestSuite) Test_RedisTypesFromEnv_UnknownType_ReturnsError() {
+	defer os.Unsetenv("DF_REDIS_TYPES")
+	os.Setenv("DF_REDIS_TYPES", "service,services")
+
+	_, err := RedisTypesFromEnv()
+	s.Require().Error(err)
+	s.Contains(err.Error(), "services")
+	s.Contains(err.Error(), "service, node, task")
+}
+
+func (s *RedisTestSuite) Test_NewRedisSinkFromEnv() {
+	defer func() {
+		os.Unsetenv("DF_REDIS_ADDR")
+		os.Unsetenv("DF_REDIS_STREAM")
+		os.Unsetenv("DF_REDIS_STREAM_MAX_LEN")
+	}()
+	s.Nil(NewRedisSinkFromEnv("service", "", 1, 0, s.Logger))
+
+	os.Setenv("DF_REDIS_ADDR", s.Server.Addr())
+	sink := NewRedisSinkFromEnv("service", "", 1, 0, s.Logger)
+	s.Require().NotNil(sink)
+	s.Equal(redisDefaultChannel, sink.Channel)
+	s.Empty(sink.Stream)
+	s.Equal(redisDefaultMaxLen, sink.MaxLen)
+
+	os.Setenv("DF_REDIS_STREAM", "swarm-events")
+	os.Setenv("DF_REDIS_STREAM_MAX_LEN", "50")
+	sink = NewRedisSinkFromEnv("service", "", 1, 0, s.Logger)
+	s.Require().NotNil(sink)
+	s.Empty(sink.Channel)
+	s.Equal("swarm-events", sink.Stream)
+	s.Equal(50, sink.MaxLen)
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sync"
+	"text/template"
+)
+
+// RequestTemplate defines Go templates for the path, headers, and body of
+// notification requests sent to an endpoint
+type RequestTemplate struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+
+	path     *template.Template
+	headers  map[string]*template.Template
+	body     *template.Template
+	parseErr error
+	once     sync.Once
+}
+
+// RequestTemplates are the create and remove templates of a notification
+// type
+type RequestTemplates struct {
+	Create *RequestTemplate `json:"create"`
+	Remove *RequestTemplate `json:"remove"`
+}
+
+// EndpointRequestTemplates are the service and node templates of an
+// endpoint
+type EndpointRequestTemplates struct {
+	Service *RequestTemplates `json:"service"`
+	Node    *RequestTemplates `json:"node"`
+}
+
+var requestTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func init() {
+	for k, v := range templateFuncs {
+		requestTemplateFuncs[k] = v
+	}
+}
+
+// parse parses the templates of `t` once
+func (t *RequestTemplate) parse() error {
+	t.once.Do(func() {
+		t.parseErr = t.parseTemplates()
+	})
+	return t.parseErr
+}
+
+func (t *RequestTemplate) parseTemplates() error {
+	var err error
+	if t.path, err = template.New("path").Funcs(requestTemplateFuncs).Parse(t.Path); err != nil {
+		return err
+	}
+	if t.body, err = template.New("body").Funcs(requestTemplateFuncs).Parse(t.Body); err != nil {
+		return err
+	}
+	t.headers = map[string]*template.Template{}
+	for k, v := range t.Headers {
+		if t.headers[k], err = template.New(k).Funcs(requestTemplateFuncs).Parse(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RenderedRequest is a request rendered from a `RequestTemplate`
+type RenderedRequest struct {
+	Method  string
+	URL     string
+	Headers map[string]string
+	Body    string
+}
+
+// Render renders the request to `addr`
+// The notification parameters are available at the root of the template
+// data, for example `{{.serviceName}}`. The data also contains `EventType`,
+// `ID`, and the cached `Service` or `Node` of the notification
+func (t *RequestTemplate) Render(ctx context.Context, eventType EventType, addr string, params string) (RenderedRequest, error) {
+	if err := t.parse(); err != nil {
+		return RenderedRequest{}, err
+	}
+	data, err := newRequestTemplateData(ctx, eventType, params)
+	if err != nil {
+		return RenderedRequest{}, err
+	}
+
+	urlObj, err := url.Parse(addr)
+	if err != nil {
+		return RenderedRequest{}, err
+	}
+	urlObj.RawQuery = ""
+	if len(t.Path) > 0 {
+		path, err := executeTemplate(t.path, data)
+		if err != nil {
+			return RenderedRequest{}, err
+		}
+		ref, err := url.Parse(path)
+		if err != nil {
+			return RenderedRequest{}, err
+		}
+		urlObj = urlObj.ResolveReference(ref)
+	}
+
+	body, err := executeTemplate(t.body, data)
+	if err != nil {
+		return RenderedRequest{}, err
+	}
+	headers := map[string]string{}
+	for k, tmpl := range t.headers {
+		if headers[k], err = executeTemplate(tmpl, data); err != nil {
+			return RenderedRequest{}, err
+		}
+	}
+
+	method := t.Method
+	if len(method) == 0 {
+		method = "GET"
+		if len(body) > 0 {
+			method = "POST"
+		}
+	}
+	return RenderedRequest{
+		Method:  method,
+		URL:     urlObj.String(),
+		Headers: headers,
+		Body:    body,
+	}, nil
+}
+
+func newRequestTemplateData(ctx context.Context, eventType EventType, params string) (map[string]interface{}, error) {
+	values, err := url.ParseQuery(params)
+	if err != nil {
+		return nil, err
+	}
+	data := map[string]interface{}{}
+	for k := range values {
+		data[k] = values.Get(k)
+	}
+	data["EventType"] = eventType
+	if n, ok := notificationFromContext(ctx); ok {
+		data["ID"] = n.ID
+		if n.Service != nil {
+			data["Service"] = *n.Service
+		}
+		if n.Node != nil {
+			data["Node"] = *n.Node
+		}
+	}
+	return data, nil
+}
+
+func executeTemplate(tmpl *template.Template, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// LoadRequestTemplates reads endpoint request templates from a JSON file
+// The file maps the host of an endpoint to its templates
+func LoadRequestTemplates(path string) (map[string]EndpointRequestTemplates, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	templates := map[string]EndpointRequestTemplates{}
+	if err := json.Unmarshal(content, &templates); err != nil {
+		return nil, err
+	}
+	for host, ept := range templates {
+		for _, rts := range []*RequestTemplates{ept.Service, ept.Node} {
+			if rts == nil {
+				continue
+			}
+			for _, rt := range []*RequestTemplate{rts.Create, rts.Remove} {
+				if rt == nil {
+					continue
+				}
+				if err := rt.parse(); err != nil {
+					return nil, fmt.Errorf("Invalid request template for %s: %v", host, err)
+				}
+			}
+		}
+	}
+	return templates, nil
+}
+
+// LoadRequestTemplatesFromEnv reads endpoint request templates from the
+// file in environment variable `DF_NOTIFY_REQUEST_TEMPLATES`. Returns nil
+// when the variable is not set
+func LoadRequestTemplatesFromEnv() (map[string]EndpointRequestTemplates, error) {
+	path := os.Getenv("DF_NOTIFY_REQUEST_TEMPLATES")
+	if len(path) == 0 {
+		return nil, nil
+	}
+	return LoadRequestTemplates(path)
+}
+
+// SetRequestTemplates sets the request templates of the notifiers in
+// `NotifyEndpoints`
+func (d *NotifyDistributor) SetRequestTemplates(templates map[string]EndpointRequestTemplates) {
+	for host, endpoint := range d.NotifyEndpoints {
+		ept, ok := templates[host]
+		if !ok {
+			continue
+		}
+		if notifier, ok := endpoint.ServiceNotifier.(*Notifier); ok && ept.Service != nil {
+			notifier.createTemplate = ept.Service.Create
+			notifier.removeTemplate = ept.Service.Remove
+		}
+		if notifier, ok := endpoint.NodeNotifier.(*Notifier); ok && ept.Node != nil {
+			notifier.createTemplate = ept.Node.Create
+			notifier.removeTemplate = ept.Node.Remove
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RequestTemplateTestSuite struct {
+	suite.Suite
+	TmpDir string
+}
+
+func TestRequestTemplateUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(RequestTemplateTestSuite))
+}
+
+func (s *RequestTemplateTestSuite) SetupTest() {
+	tmpDir, err := ioutil.TempDir("", "requesttemplate")
+	s.Require().NoError(err)
+	s.TmpDir = tmpDir
+}
+
+func (s *RequestTemplateTestSuite) TearDownTest() {
+	os.RemoveAll(s.TmpDir)
+}
+
+func (s *RequestTemplateTestSuite) Test_Render_NoTemplates_DropsQuery() {
+	tmpl := &RequestTemplate{}
+	rendered, err := tmpl.Render(
+		context.Background(), EventTypeCreate, "http://proxy:8080/v1/reconfigure?a=b", "serviceName=hello")
+	s.Require().NoError(err)
+
+	s.Equal("GET", rendered.Method)
+	s.Equal("http://proxy:8080/v1/reconfigure", rendered.URL)
+	s.Empty(rendered.Body)
+	s.Empty(rendered.Headers)
+}
+
+func (s *RequestTemplateTestSuite) Test_Render_RendersPathHeadersAndBody() {
+	tmpl := &RequestTemplate{
+		Method:  "PUT",
+		Path:    "services/{{.serviceName}}",
+		Headers: map[string]string{"X-Event": "{{.EventType}}"},
+		Body:    `{"name": {{json .serviceName}}}`,
+	}
+	rendered, err := tmpl.Render(
+		context.Background(), EventTypeRemove, "http://proxy:8080/v1/", "serviceName=hello")
+	s.Require().NoError(err)
+
+	s.Equal("PUT", rendered.Method)
+	s.Equal("http://proxy:8080/v1/services/hello", rendered.URL)
+	s.Equal(map[string]string{"X-Event": "remove"}, rendered.Headers)
+	s.Equal(`{"name": "hello"}`, rendered.Body)
+}
+
+func (s *RequestTemplateTestSuite) Test_Render_BodyDefaultsMethodToPost() {
+	tmpl := &RequestTemplate{Body: "{{.serviceName}}"}
+	rendered, err := tmpl.Render(
+		context.Background(), EventTypeCreate, "http://proxy:8080", "serviceName=hello")
+	s.Require().NoError(err)
+
+	s.Equal("POST", rendered.Method)
+	s.Equal("hello", rendered.Body)
+}
+
+func (s *RequestTemplateTestSuite) Test_Render_NotificationInContext_AddsServiceToData() {
+	ssm := SwarmServiceMini{
+		ID:     "serviceID1",
+		Name:   "demo_go",
+		Labels: map[string]string{"com.df.servicePath": "/demo"},
+	}
+	ctx := contextWithNotification(context.Background(), Notification{
+		ID: "serviceID1", Service: &ssm,
+	})
+	tmpl := &RequestTemplate{
+		Body: `{{.ID}} {{.Service.Name}} {{index .Service.Labels "com.df.servicePath"}}`,
+	}
+	rendered, err := tmpl.Render(ctx, EventTypeCreate, "http://proxy:8080", "")
+	s.Require().NoError(err)
+
+	s.Equal("serviceID1 demo_go /demo", rendered.Body)
+}
+
+func (s *RequestTemplateTestSuite) Test_LoadRequestTemplates_ReadsFile() {
+	path := filepath.Join(s.TmpDir, "templates.json")
+	content := `{"proxy:8080": {"service": {"create": {"path": "/{{.serviceName}}"}}}}`
+	s.Require().NoError(ioutil.WriteFile(path, []byte(content), 0644))
+
+	templates, err := LoadRequestTemplates(path)
+	s.Require().NoError(err)
+
+	s.Require().Contains(templates, "proxy:8080")
+	ept := templates["proxy:8080"]
+	s.Nil(ept.Node)
+	s.Require().NotNil(ept.Service)
+	s.Require().NotNil(ept.Service.Create)
+	s.Nil(ept.Service.Remove)
+	s.Equal("/{{.serviceName}}", ept.Service.Create.Path)
+}
+
+func (s *RequestTemplateTestSuite) Test_LoadRequestTemplates_InvalidTemplate_ReturnsError() {
+	path := filepath.Join(s.TmpDir, "templates.json")
+	content := `{"proxy:8080": {"node": {"remove": {"body": "{{.serviceName"}}}}`
+	s.Require().NoError(ioutil.WriteFile(path, []byte(content), 0644))
+
+	_, err := LoadRequestTemplates(path)
+	s.Error(err)
+	s.Contains(err.Error(), "proxy:8080")
+}
+
+func (s *RequestTemplateTestSuite) Test_LoadRequestTemplatesFromEnv_NotSet_ReturnsNil() {
+	defer os.Setenv("DF_NOTIFY_REQUEST_TEMPLATES", os.Getenv("DF_NOTIFY_REQUEST_TEMPLATES"))
+	os.Unsetenv("DF_NOTIFY_REQUEST_TEMPLATES")
+
+	templates, err := LoadRequestTemplatesFromEnv()
+	s.NoError(err)
+	s.Nil(templates)
+}
+
+func (s *RequestTemplateTestSuite) Test_SetRequestTemplates_SetsNotifierTemplates() {
+	logger := log.New(ioutil.Discard, "", 0)
+	d := newNotifyDistributorfromStrings(
+		"http://proxy:8080/create", "http://proxy:8080/remove",
+		"http://other:8080/create", "http://other:8080/remove", 1, 0, logger)
+	create := &RequestTemplate{Path: "/created"}
+	remove := &RequestTemplate{Path: "/removed"}
+
+	d.SetRequestTemplates(map[string]EndpointRequestTemplates{
+		"proxy:8080": {Service: &RequestTemplates{Create: create, Remove: remove}},
+	})
+
+	proxy := d.NotifyEndpoints["proxy:8080"]
+	s.Equal(create, proxy.ServiceNotifier.(*Notifier).createTemplate)
+	s.Equal(remove, proxy.ServiceNotifier.(*Notifier).removeTemplate)
+	s.Nil(d.NotifyEndpoints["other:8080"].NodeNotifier.(*Notifier).createTemplate)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
@@ -24,6 +25,16 @@ type SwarmServiceClient struct {
 	FilterLabel    string
 	FilterKey      string
 	ScrapeNetLabel string
+	UpdateObserver func(swarm.Service)
+	StackObserver  func(swarm.Service)
+	SpecObserver   func(swarm.Service)
+	// ConvergenceTimeout is the time services have to converge, 0 waits
+	// forever. The `com.df.convergenceTimeout` label overrides it
+	ConvergenceTimeout time.Duration
+	// WaitForHealthy waits for the containers of services to be healthy
+	// before they are converged. The `com.df.waitForHealthy` label
+	// overrides it
+	WaitForHealthy bool
 	Log            *log.Logger
 }
 
@@ -41,6 +52,8 @@ func NewSwarmServiceClient(c *client.Client, filterLabel, scrapNetLabel string,
 // SwarmServiceInspect returns `SwarmService` from its ID
 // Returns nil when service doesnt not have the `FilterLabel`
 // When `includeNodeIPInfo` is true, return node info as well
+// The observers of the client are called every time the service is polled
+// while it converges
 func (c SwarmServiceClient) SwarmServiceInspect(ctx context.Context, serviceID string, includeNodeIPInfo bool) (*SwarmService, error) {
 	service, _, err := c.DockerClient.ServiceInspectWithRaw(ctx, serviceID, types.ServiceInspectOptions{})
 	if err != nil {
@@ -53,7 +66,11 @@ func (c SwarmServiceClient) SwarmServiceInspect(ctx context.Context, serviceID s
 	}
 
 	ss := SwarmService{service, nil}
-	taskList, err := GetTaskList(ctx, c.DockerClient, ss.ID)
+	// Only services inspected for events are observed. Listed services
+	// would report updates and convergence failures a second time
+	opts := c.taskListOptions()
+	opts.observe = c.observe
+	taskList, err := getTaskList(ctx, c.DockerClient, ss.ID, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -81,7 +98,7 @@ func (c SwarmServiceClient) SwarmServiceList(ctx context.Context, includeNodeIPI
 	for _, s := range services {
 		ss := SwarmService{s, nil}
 		if includeNodeIPInfo {
-			taskList, err := GetTaskList(ctx, c.DockerClient, ss.ID)
+			taskList, err := getTaskList(ctx, c.DockerClient, ss.ID, c.taskListOptions())
 			if err != nil {
 				c.Log.Printf("%v", err)
 			} else {
@@ -98,6 +115,28 @@ func (c SwarmServiceClient) SwarmServiceList(ctx context.Context, includeNodeIPI
 	return swarmServices, nil
 }
 
+// observe passes `service` to the observers of the client
+func (c SwarmServiceClient) observe(service swarm.Service) {
+	if c.SpecObserver != nil {
+		c.SpecObserver(service)
+	}
+	if c.UpdateObserver != nil {
+		c.UpdateObserver(service)
+	}
+	if c.StackObserver != nil {
+		c.StackObserver(service)
+	}
+}
+
+func (c SwarmServiceClient) taskListOptions() taskListOptions {
+	return taskListOptions{
+		timeout:             c.ConvergenceTimeout,
+		timeoutLabel:        "com.df.convergenceTimeout",
+		waitForHealthy:      c.WaitForHealthy,
+		waitForHealthyLabel: "com.df.waitForHealthy",
+	}
+}
+
 func (c SwarmServiceClient) getNodeInfo(ctx context.Context, taskList []swarm.Task, ss swarm.Service) (NodeIPSet, error) {
 
 	networkName, ok := ss.Spec.Labels[c.ScrapeNetLabel]
@@ -7,6 +7,7 @@ type SwarmServiceCacher interface {
 	InsertAndCheck(ss SwarmServiceMini) bool
 	Delete(ID string)
 	Get(ID string) (SwarmServiceMini, bool)
+	GetAll() []SwarmServiceMini
 	Len() int
 }
 
@@ -51,6 +52,17 @@ func (c *SwarmServiceCache) Get(ID string) (SwarmServiceMini, bool) {
 	return v, ok
 }
 
+// GetAll returns all services in cache
+func (c *SwarmServiceCache) GetAll() []SwarmServiceMini {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	services := make([]SwarmServiceMini, 0, len(c.cache))
+	for _, v := range c.cache {
+		services = append(services, v)
+	}
+	return services
+}
+
 // Len returns the number of items in cache
 func (c *SwarmServiceCache) Len() int {
 	c.mux.RLock()
@@ -141,6 +141,19 @@ func (s *SwarmServiceCacheTestSuite) Test_GetAndRemove_NotInCache_ReturnsFalse()
 	s.False(ok)
 }
 
+func (s *SwarmServiceCacheTestSuite) Test_GetAll_ReturnsAllServices() {
+	s.Cache.InsertAndCheck(s.SSMini)
+
+	newSSMini := getNewSwarmServiceMini()
+	newSSMini.ID = "serviceID2"
+	s.Cache.InsertAndCheck(newSSMini)
+
+	services := s.Cache.GetAll()
+	s.Len(services, 2)
+	s.Contains(services, s.SSMini)
+	s.Contains(services, newSSMini)
+}
+
 func (s *SwarmServiceCacheTestSuite) AssertInCache(ssm SwarmServiceMini) {
 	ss, ok := s.Cache.Get(ssm.ID)
 	s.True(ok)
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"sort"
+)
+
+// LabelChange is the previous and current value of a changed label or name
+type LabelChange struct {
+	Previous string `json:"previous"`
+	Current  string `json:"current"`
+}
+
+// ServiceDiff is the difference between the cached `SwarmServiceMini` and
+// its updated version
+type ServiceDiff struct {
+	NameChanged         *LabelChange           `json:"nameChanged,omitempty"`
+	GlobalChanged       bool                   `json:"globalChanged,omitempty"`
+	LabelsAdded         map[string]string      `json:"labelsAdded,omitempty"`
+	LabelsRemoved       map[string]string      `json:"labelsRemoved,omitempty"`
+	LabelsChanged       map[string]LabelChange `json:"labelsChanged,omitempty"`
+	PreviousReplicas    uint64                 `json:"previousReplicas"`
+	Replicas            uint64                 `json:"replicas"`
+	NodeInfoAdded       []NodeIP               `json:"nodeInfoAdded,omitempty"`
+	NodeInfoRemoved     []NodeIP               `json:"nodeInfoRemoved,omitempty"`
+	EndpointChanged     bool                   `json:"endpointChanged,omitempty"`
+	EndpointModeChanged *LabelChange           `json:"endpointModeChanged,omitempty"`
+	PortsAdded          []PortMini             `json:"portsAdded,omitempty"`
+	PortsRemoved        []PortMini             `json:"portsRemoved,omitempty"`
+	VirtualIPsAdded     []VirtualIPMini        `json:"virtualIPsAdded,omitempty"`
+	VirtualIPsRemoved   []VirtualIPMini        `json:"virtualIPsRemoved,omitempty"`
+}
+
+// DiffSwarmServiceMini returns the difference between `previous` and
+// `current`
+func DiffSwarmServiceMini(previous, current SwarmServiceMini) ServiceDiff {
+	diff := ServiceDiff{
+		LabelsAdded:      map[string]string{},
+		LabelsRemoved:    map[string]string{},
+		LabelsChanged:    map[string]LabelChange{},
+		PreviousReplicas: previous.Replicas,
+		Replicas:         current.Replicas,
+		NodeInfoAdded:    nodeIPsNotIn(current.NodeInfo, previous.NodeInfo),
+		NodeInfoRemoved:  nodeIPsNotIn(previous.NodeInfo, current.NodeInfo),
+		GlobalChanged:    previous.Global != current.Global,
+		EndpointChanged:  !EqualEndpointMini(previous.Endpoint, current.Endpoint),
+	}
+	if diff.EndpointChanged {
+		diffEndpointMini(&diff, previous.Endpoint, current.Endpoint)
+	}
+	if previous.Name != current.Name {
+		diff.NameChanged = &LabelChange{Previous: previous.Name, Current: current.Name}
+	}
+	for k, v := range current.Labels {
+		pv, ok := previous.Labels[k]
+		if !ok {
+			diff.LabelsAdded[k] = v
+		} else if pv != v {
+			diff.LabelsChanged[k] = LabelChange{Previous: pv, Current: v}
+		}
+	}
+	for k, v := range previous.Labels {
+		if _, ok := current.Labels[k]; !ok {
+			diff.LabelsRemoved[k] = v
+		}
+	}
+	return diff
+}
+
+// LabelsModified returns true when labels were added, removed, or changed
+func (d ServiceDiff) LabelsModified() bool {
+	return len(d.LabelsAdded) > 0 ||
+		len(d.LabelsRemoved) > 0 ||
+		len(d.LabelsChanged) > 0
+}
+
+// ScaleOnly returns true when the number of replicas changed and nothing
+// else did. The node info of a scaled service changes with its replicas
+func (d ServiceDiff) ScaleOnly() bool {
+	return d.PreviousReplicas != d.Replicas &&
+		!d.LabelsModified() &&
+		d.NameChanged == nil &&
+		!d.GlobalChanged &&
+		!d.EndpointChanged
+}
+
+// diffEndpointMini adds the changes of the endpoint to `diff`. A missing
+// endpoint is compared as an endpoint without ports or virtual IPs
+func diffEndpointMini(diff *ServiceDiff, previous, current *EndpointMini) {
+	if previous == nil {
+		previous = &EndpointMini{}
+	}
+	if current == nil {
+		current = &EndpointMini{}
+	}
+	if previous.Mode != current.Mode {
+		diff.EndpointModeChanged = &LabelChange{
+			Previous: string(previous.Mode), Current: string(current.Mode)}
+	}
+	diff.PortsAdded = portsNotIn(current.Ports, previous.Ports)
+	diff.PortsRemoved = portsNotIn(previous.Ports, current.Ports)
+	diff.VirtualIPsAdded = virtualIPsNotIn(current.VirtualIPs, previous.VirtualIPs)
+	diff.VirtualIPsRemoved = virtualIPsNotIn(previous.VirtualIPs, current.VirtualIPs)
+}
+
+// portsNotIn returns the ports of `l` that are not in `r`
+func portsNotIn(l []PortMini, r []PortMini) []PortMini {
+	ports := []PortMini{}
+	for _, p := range l {
+		found := false
+		for _, rp := range r {
+			if p == rp {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ports = append(ports, p)
+		}
+	}
+	return ports
+}
+
+// virtualIPsNotIn returns the virtual IPs of `l` that are not in `r`
+func virtualIPsNotIn(l []VirtualIPMini, r []VirtualIPMini) []VirtualIPMini {
+	vips := []VirtualIPMini{}
+	for _, v := range l {
+		found := false
+		for _, rv := range r {
+			if v == rv {
+				found = true
+				break
+			}
+		}
+		if !found {
+			vips = append(vips, v)
+		}
+	}
+	return vips
+}
+
+// nodeIPsNotIn returns the sorted `NodeIP`s of `l` that are not in `r`
+func nodeIPsNotIn(l NodeIPSet, r NodeIPSet) []NodeIP {
+	ips := []NodeIP{}
+	for ip := range l {
+		if _, ok := r[ip]; !ok {
+			ips = append(ips, ip)
+		}
+	}
+	sort.Slice(ips, func(i, j int) bool {
+		if ips[i].Name != ips[j].Name {
+			return ips[i].Name < ips[j].Name
+		}
+		if ips[i].Addr != ips[j].Addr {
+			return ips[i].Addr < ips[j].Addr
+		}
+		return ips[i].ID < ips[j].ID
+	})
+	return ips
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ServiceDiffTestSuite struct {
+	suite.Suite
+}
+
+func TestServiceDiffUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ServiceDiffTestSuite))
+}
+
+func (s *ServiceDiffTestSuite) Test_DiffSwarmServiceMini_Labels() {
+	previous := SwarmServiceMini{
+		ID: "serviceID1",
+		Labels: map[string]string{
+			"com.df.port":          "80",
+			"com.df.servicePath":   "/demo",
+			"com.df.reqMode":       "http",
+			"com.df.distribute":    "true",
+			"com.df.notify":        "true",
+			"com.df.serviceDomain": "demo.com",
+		},
+		Replicas: 2,
+	}
+	current := SwarmServiceMini{
+		ID: "serviceID1",
+		Labels: map[string]string{
+			"com.df.port":        "8080",
+			"com.df.servicePath": "/demo",
+			"com.df.reqMode":     "http",
+			"com.df.distribute":  "true",
+			"com.df.notify":      "true",
+			"com.df.srcPort":     "443",
+		},
+		Replicas: 2,
+	}
+
+	diff := DiffSwarmServiceMini(previous, current)
+	s.Equal(map[string]string{"com.df.srcPort": "443"}, diff.LabelsAdded)
+	s.Equal(map[string]string{"com.df.serviceDomain": "demo.com"}, diff.LabelsRemoved)
+	s.Equal(map[string]LabelChange{
+		"com.df.port": {Previous: "80", Current: "8080"},
+	}, diff.LabelsChanged)
+	s.True(diff.LabelsModified())
+	s.False(diff.ScaleOnly())
+}
+
+func (s *ServiceDiffTestSuite) Test_DiffSwarmServiceMini_ScaleOnly() {
+	previous := SwarmServiceMini{
+		ID:       "serviceID1",
+		Labels:   map[string]string{"com.df.notify": "true"},
+		Replicas: 2,
+		NodeInfo: NodeIPSet{},
+	}
+	previous.NodeInfo.Add("node-1", "1.0.0.1", "id1")
+	current := SwarmServiceMini{
+		ID:       "serviceID1",
+		Labels:   map[string]string{"com.df.notify": "true"},
+		Replicas: 3,
+		NodeInfo: NodeIPSet{},
+	}
+	current.NodeInfo.Add("node-1", "1.0.0.1", "id1")
+	current.NodeInfo.Add("node-2", "1.0.0.2", "id2")
+
+	diff := DiffSwarmServiceMini(previous, current)
+	s.False(diff.LabelsModified())
+	s.True(diff.ScaleOnly())
+	s.Equal(uint64(2), diff.PreviousReplicas)
+	s.Equal(uint64(3), diff.Replicas)
+	s.Equal([]NodeIP{{Name: "node-2", Addr: "1.0.0.2", ID: "id2"}}, diff.NodeInfoAdded)
+	s.Empty(diff.NodeInfoRemoved)
+}
+
+func (s *ServiceDiffTestSuite) Test_DiffSwarmServiceMini_ScaleAndRename_IsNotScaleOnly() {
+	previous := SwarmServiceMini{ID: "serviceID1", Name: "demo", Replicas: 2}
+	current := SwarmServiceMini{ID: "serviceID1", Name: "demo-v2", Replicas: 3}
+
+	diff := DiffSwarmServiceMini(previous, current)
+	s.Equal(&LabelChange{Previous: "demo", Current: "demo-v2"}, diff.NameChanged)
+	s.False(diff.ScaleOnly())
+}
+
+func (s *ServiceDiffTestSuite) Test_DiffSwarmServiceMini_ScaleAndGlobal_IsNotScaleOnly() {
+	previous := SwarmServiceMini{ID: "serviceID1", Name: "demo", Replicas: 2}
+	current := SwarmServiceMini{ID: "serviceID1", Name: "demo", Global: true}
+
+	diff := DiffSwarmServiceMini(previous, current)
+	s.True(diff.GlobalChanged)
+	s.Nil(diff.NameChanged)
+	s.False(diff.ScaleOnly())
+}
+
+func (s *ServiceDiffTestSuite) Test_DiffSwarmServiceMini_ScaleAndPorts_IsNotScaleOnly() {
+	port80 := PortMini{Protocol: "tcp", TargetPort: 80, PublishedPort: 8080, PublishMode: "ingress"}
+	port443 := PortMini{Protocol: "tcp", TargetPort: 443, PublishedPort: 8443, PublishMode: "ingress"}
+	vip := VirtualIPMini{NetworkID: "network1", Addr: "10.0.0.2/24"}
+	previous := SwarmServiceMini{
+		ID:       "serviceID1",
+		Name:     "demo",
+		Replicas: 2,
+		Endpoint: &EndpointMini{
+			Mode:       "vip",
+			Ports:      []PortMini{port80},
+			VirtualIPs: []VirtualIPMini{vip},
+		},
+	}
+	current := SwarmServiceMini{
+		ID:       "serviceID1",
+		Name:     "demo",
+		Replicas: 3,
+		Endpoint: &EndpointMini{
+			Mode:       "vip",
+			Ports:      []PortMini{port443},
+			VirtualIPs: []VirtualIPMini{vip},
+		},
+	}
+
+	diff := DiffSwarmServiceMini(previous, current)
+	s.True(diff.EndpointChanged)
+	s.Nil(diff.EndpointModeChanged)
+	s.Equal([]PortMini{port443}, diff.PortsAdded)
+	s.Equal([]PortMini{port80}, diff.PortsRemoved)
+	s.Empty(diff.VirtualIPsAdded)
+	s.Empty(diff.VirtualIPsRemoved)
+	s.False(diff.ScaleOnly())
+}
+
+func (s *ServiceDiffTestSuite) Test_DiffSwarmServiceMini_EndpointMode() {
+	vip := VirtualIPMini{NetworkID: "network1", Addr: "10.0.0.2/24"}
+	previous := SwarmServiceMini{
+		ID:       "serviceID1",
+		Endpoint: &EndpointMini{Mode: "vip", VirtualIPs: []VirtualIPMini{vip}},
+	}
+	current := SwarmServiceMini{
+		ID:       "serviceID1",
+		Endpoint: &EndpointMini{Mode: "dnsrr"},
+	}
+
+	diff := DiffSwarmServiceMini(previous, current)
+	s.True(diff.EndpointChanged)
+	s.Equal(&LabelChange{Previous: "vip", Current: "dnsrr"}, diff.EndpointModeChanged)
+	s.Equal([]VirtualIPMini{vip}, diff.VirtualIPsRemoved)
+	s.Empty(diff.VirtualIPsAdded)
+}
+
+func (s *ServiceDiffTestSuite) Test_DiffSwarmServiceMini_SameEndpoint() {
+	endpoint := &EndpointMini{Mode: "vip", Ports: []PortMini{{TargetPort: 80}}}
+	previous := SwarmServiceMini{ID: "serviceID1", Replicas: 2, Endpoint: endpoint}
+	current := SwarmServiceMini{ID: "serviceID1", Replicas: 3, Endpoint: endpoint}
+
+	diff := DiffSwarmServiceMini(previous, current)
+	s.False(diff.EndpointChanged)
+	s.Nil(diff.PortsAdded)
+	s.True(diff.ScaleOnly())
+}
+
+func (s *ServiceDiffTestSuite) Test_DiffSwarmServiceMini_NodeInfoOnly() {
+	previous := SwarmServiceMini{
+		ID:       "serviceID1",
+		Replicas: 1,
+		NodeInfo: NodeIPSet{},
+	}
+	previous.NodeInfo.Add("node-1", "1.0.0.1", "id1")
+	current := SwarmServiceMini{
+		ID:       "serviceID1",
+		Replicas: 1,
+		NodeInfo: NodeIPSet{},
+	}
+	current.NodeInfo.Add("node-2", "1.0.0.2", "id2")
+
+	diff := DiffSwarmServiceMini(previous, current)
+	s.False(diff.ScaleOnly())
+	s.Equal([]NodeIP{{Name: "node-2", Addr: "1.0.0.2", ID: "id2"}}, diff.NodeInfoAdded)
+	s.Equal([]NodeIP{{Name: "node-1", Addr: "1.0.0.1", ID: "id1"}}, diff.NodeInfoRemoved)
+}
+
+func (s *ServiceDiffTestSuite) Test_DiffSwarmServiceMini_NilNodeInfo() {
+	previous := SwarmServiceMini{ID: "serviceID1", Replicas: 1}
+	current := SwarmServiceMini{ID: "serviceID1", Replicas: 1, NodeInfo: NodeIPSet{}}
+	current.NodeInfo.Add("node-1", "1.0.0.1", "id1")
+
+	diff := DiffSwarmServiceMini(previous, current)
+	s.Len(diff.NodeInfoAdded, 1)
+	s.Empty(diff.NodeInfoRemoved)
+	s.False(diff.LabelsModified())
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+)
+
+// SpecTracker notifies services as soon as their spec changed
+// `GetTaskList` polls services until they converge. The tracker is called
+// with every polled service and places a notification on its channel when
+// the service changed, without waiting for the service to converge. The
+// notifications do not include `NodeInfo`, which is only known once tasks
+// are running
+type SpecTracker struct {
+	IgnoreKey            string
+	IncludeKey           string
+	IncludeEndpointInfo  bool
+	FullRemoveParameters bool
+	notiChan             chan<- Notification
+	services             map[string]SwarmServiceMini
+	mux                  sync.RWMutex
+}
+
+// NewSpecTracker creates a `SpecTracker`
+func NewSpecTracker(ignoreKey, includeKey string) *SpecTracker {
+	return &SpecTracker{
+		IgnoreKey:  ignoreKey,
+		IncludeKey: includeKey,
+		services:   map[string]SwarmServiceMini{},
+	}
+}
+
+// Run places spec notifications on `notiChan`
+func (t *SpecTracker) Run(notiChan chan<- Notification) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.notiChan = notiChan
+}
+
+// Observe notifies `service` when it changed since it was last observed
+func (t *SpecTracker) Observe(service swarm.Service) {
+	n, ok := t.changed(service)
+	if ok {
+		t.notify(n)
+	}
+}
+
+// Removed notifies that service `serviceID` was removed
+// Services that were never observed are ignored
+func (t *SpecTracker) Removed(serviceID string) {
+	n, ok := t.removed(serviceID)
+	if ok {
+		t.notify(n)
+	}
+}
+
+func (t *SpecTracker) changed(service swarm.Service) (Notification, bool) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	ssm := minifySwarmService(
+		SwarmService{service, nil}, t.IgnoreKey, t.IncludeKey, t.IncludeEndpointInfo)
+	previous, ok := t.services[ssm.ID]
+	if ok && previous.Equal(ssm) {
+		return Notification{}, false
+	}
+	t.services[ssm.ID] = ssm
+
+	params := GetSwarmServiceMiniCreateParameters(ssm)
+	var diff *ServiceDiff
+	if ok {
+		d := DiffSwarmServiceMini(previous, ssm)
+		diff = &d
+		for k, v := range GetServiceDiffParameters(d) {
+			params[k] = v
+		}
+	}
+	return Notification{
+		EventType:  EventTypeCreate,
+		ID:         ssm.ID,
+		Parameters: ConvertMapStringStringToURLValues(params).Encode(),
+		TimeNano:   time.Now().UTC().UnixNano(),
+		Service:    &ssm,
+		Diff:       diff,
+	}, true
+}
+
+func (t *SpecTracker) removed(serviceID string) (Notification, bool) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	ssm, ok := t.services[serviceID]
+	if !ok {
+		return Notification{}, false
+	}
+	delete(t.services, serviceID)
+
+	params := GetSwarmServiceMiniRemoveParameters(ssm)
+	if t.FullRemoveParameters {
+		params = GetSwarmServiceMiniFullRemoveParameters(ssm)
+	}
+	return Notification{
+		EventType:  EventTypeRemove,
+		ID:         ssm.ID,
+		Parameters: ConvertMapStringStringToURLValues(params).Encode(),
+		TimeNano:   time.Now().UTC().UnixNano(),
+		Service:    &ssm,
+	}, true
+}
+
+func (t *SpecTracker) notify(n Notification) {
+	t.mux.RLock()
+	notiChan := t.notiChan
+	t.mux.RUnlock()
+	if notiChan != nil {
+		notiChan <- n
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/suite"
+)
+
+type SpecTrackerTestSuite struct {
+	suite.Suite
+	Tracker  *SpecTracker
+	NotiChan chan Notification
+}
+
+func TestSpecTrackerUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(SpecTrackerTestSuite))
+}
+
+func (s *SpecTrackerTestSuite) SetupTest() {
+	s.Tracker = NewSpecTracker("com.df.notify", "com.docker.stack.namespace")
+	s.NotiChan = make(chan Notification, 10)
+	s.Tracker.Run(s.NotiChan)
+}
+
+func (s *SpecTrackerTestSuite) Test_Observe_NewService_Notifies() {
+	s.Tracker.Observe(newSpecService("serviceID1", "demo", "/demo", 2))
+
+	s.Require().Len(s.NotiChan, 1)
+	n := <-s.NotiChan
+	s.Equal(EventTypeCreate, n.EventType)
+	s.Equal("serviceID1", n.ID)
+	s.Nil(n.Diff)
+	s.Require().NotNil(n.Service)
+	s.Equal("demo", n.Service.Name)
+	params, err := url.ParseQuery(n.Parameters)
+	s.Require().NoError(err)
+	s.Equal("demo", params.Get("serviceName"))
+	s.Equal("/demo", params.Get("servicePath"))
+	s.Equal("2", params.Get("replicas"))
+}
+
+func (s *SpecTrackerTestSuite) Test_Observe_UnchangedService_NotifiesOnce() {
+	service := newSpecService("serviceID1", "demo", "/demo", 2)
+
+	s.Tracker.Observe(service)
+	service.Version.Index++
+	s.Tracker.Observe(service)
+
+	s.Len(s.NotiChan, 1)
+}
+
+func (s *SpecTrackerTestSuite) Test_Observe_ChangedService_NotifiesDiff() {
+	s.Tracker.Observe(newSpecService("serviceID1", "demo", "/demo", 2))
+	<-s.NotiChan
+
+	s.Tracker.Observe(newSpecService("serviceID1", "demo", "/demo/v2", 2))
+
+	s.Require().Len(s.NotiChan, 1)
+	n := <-s.NotiChan
+	s.Equal(EventTypeCreate, n.EventType)
+	s.Require().NotNil(n.Diff)
+	s.Equal(LabelChange{Previous: "/demo", Current: "/demo/v2"},
+		n.Diff.LabelsChanged["com.df.servicePath"])
+	params, err := url.ParseQuery(n.Parameters)
+	s.Require().NoError(err)
+	s.Equal("/demo/v2", params.Get("servicePath"))
+	s.NotEmpty(params.Get(DiffParam))
+}
+
+func (s *SpecTrackerTestSuite) Test_Removed_ObservedService_Notifies() {
+	s.Tracker.Observe(newSpecService("serviceID1", "demo", "/demo", 2))
+	<-s.NotiChan
+
+	s.Tracker.Removed("serviceID1")
+
+	s.Require().Len(s.NotiChan, 1)
+	n := <-s.NotiChan
+	s.Equal(EventTypeRemove, n.EventType)
+	s.Equal("serviceID1", n.ID)
+	params, err := url.ParseQuery(n.Parameters)
+	s.Require().NoError(err)
+	s.Equal("demo", params.Get("serviceName"))
+	s.Empty(params.Get("servicePath"))
+
+	s.Tracker.Observe(newSpecService("serviceID1", "demo", "/demo", 2))
+	s.Len(s.NotiChan, 1)
+}
+
+func (s *SpecTrackerTestSuite) Test_Removed_FullRemoveParameters() {
+	s.Tracker.FullRemoveParameters = true
+	s.Tracker.Observe(newSpecService("serviceID1", "demo", "/demo", 2))
+	<-s.NotiChan
+
+	s.Tracker.Removed("serviceID1")
+
+	s.Require().Len(s.NotiChan, 1)
+	n := <-s.NotiChan
+	params, err := url.ParseQuery(n.Parameters)
+	s.Require().NoError(err)
+	s.Equal("remove", params.Get(EventParam))
+	s.Equal("/demo", params.Get("servicePath"))
+}
+
+func (s *SpecTrackerTestSuite) Test_Removed_UnknownService_IsIgnored() {
+	s.Tracker.Removed("serviceID1")
+	s.Empty(s.NotiChan)
+}
+
+func newSpecService(ID, name, servicePath string, replicas uint64) swarm.Service {
+	service := swarm.Service{
+		ID: ID,
+		Spec: swarm.ServiceSpec{
+			Annotations: swarm.Annotations{
+				Name: name,
+				Labels: map[string]string{
+					"com.df.notify":      "true",
+					"com.df.servicePath": servicePath,
+				},
+			},
+			Mode: swarm.ServiceMode{
+				Replicated: &swarm.ReplicatedService{Replicas: &replicas},
+			},
+		},
+	}
+	service.Version.Index = 10
+	return service
+}
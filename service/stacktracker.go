@@ -0,0 +1,199 @@
+package service
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+)
+
+// stackState is the state of a stack tracked by `StackTracker`
+type stackState struct {
+	// services maps the IDs of converged services to their name and the
+	// version index they converged at
+	services map[string]convergedService
+	// pending maps the IDs of converging services to the last version
+	// index they were observed at
+	pending  map[string]uint64
+	deployed bool
+}
+
+type convergedService struct {
+	Name         string
+	VersionIndex uint64
+}
+
+// StackTracker aggregates services into stacks
+// Services are grouped by the `com.docker.stack.namespace` label. A stack
+// is deployed once none of its services are converging, and removed when
+// its last service is removed
+type StackTracker struct {
+	StackKey string
+	notiChan chan<- Notification
+	stacks   map[string]*stackState
+	mux      sync.Mutex
+}
+
+// NewStackTracker creates a `StackTracker`
+func NewStackTracker() *StackTracker {
+	return &StackTracker{
+		StackKey: "com.docker.stack.namespace",
+		stacks:   map[string]*stackState{},
+	}
+}
+
+// Run places stack notifications on `notiChan`
+func (t *StackTracker) Run(notiChan chan<- Notification) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.notiChan = notiChan
+}
+
+// Observe records that `service` is converging
+// Services are observed every time they are inspected while waiting for
+// them to converge. Services that did not change since they converged are
+// ignored
+func (t *StackTracker) Observe(service swarm.Service) {
+	stackName := service.Spec.Labels[t.StackKey]
+	if len(stackName) == 0 {
+		return
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	stack := t.getStack(stackName)
+	if cs, ok := stack.services[service.ID]; ok && cs.VersionIndex == service.Version.Index {
+		return
+	}
+	if service.Version.Index > stack.pending[service.ID] {
+		stack.pending[service.ID] = service.Version.Index
+	}
+	stack.deployed = false
+}
+
+// Converged records that `service` converged
+// The stack of the service is notified as deployed when none of its
+// services are converging
+func (t *StackTracker) Converged(service swarm.Service) {
+	stackName := service.Spec.Labels[t.StackKey]
+	if len(stackName) == 0 {
+		return
+	}
+	n, ok := t.converged(stackName, service)
+	if ok {
+		t.notify(n)
+	}
+}
+
+// Failed records that service `serviceID` stopped converging without
+// converging, for example when it did not converge in time
+func (t *StackTracker) Failed(serviceID string) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	for _, stack := range t.stacks {
+		delete(stack.pending, serviceID)
+	}
+}
+
+// Removed records that `ssm` was removed
+// The stack of the service is notified as removed when it was its last
+// service
+func (t *StackTracker) Removed(ssm SwarmServiceMini) {
+	n, ok := t.removed(ssm.ID)
+	if ok {
+		t.notify(n)
+	}
+}
+
+func (t *StackTracker) converged(stackName string, service swarm.Service) (Notification, bool) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	// The service is observed while it converges, so the last observed
+	// version can be newer than `service`
+	stack := t.getStack(stackName)
+	versionIndex := service.Version.Index
+	if observed := stack.pending[service.ID]; observed > versionIndex {
+		versionIndex = observed
+	}
+	delete(stack.pending, service.ID)
+	stack.services[service.ID] = convergedService{
+		Name:         service.Spec.Name,
+		VersionIndex: versionIndex,
+	}
+	if len(stack.pending) > 0 || stack.deployed {
+		return Notification{}, false
+	}
+	stack.deployed = true
+
+	names := []string{}
+	for _, cs := range stack.services {
+		names = append(names, cs.Name)
+	}
+	sort.Strings(names)
+	params := map[string]string{
+		"event":    string(EventTypeStackDeployed),
+		"stack":    stackName,
+		"services": strings.Join(names, ","),
+	}
+	return Notification{
+		EventType:  EventTypeStackDeployed,
+		ID:         stackName,
+		Parameters: ConvertMapStringStringToURLValues(params).Encode(),
+		TimeNano:   time.Now().UTC().UnixNano(),
+	}, true
+}
+
+func (t *StackTracker) removed(serviceID string) (Notification, bool) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	for stackName, stack := range t.stacks {
+		_, converged := stack.services[serviceID]
+		_, pending := stack.pending[serviceID]
+		if !converged && !pending {
+			continue
+		}
+		delete(stack.services, serviceID)
+		delete(stack.pending, serviceID)
+		if len(stack.services) > 0 || len(stack.pending) > 0 {
+			return Notification{}, false
+		}
+		delete(t.stacks, stackName)
+
+		params := map[string]string{
+			"event": string(EventTypeStackRemoved),
+			"stack": stackName,
+		}
+		return Notification{
+			EventType:  EventTypeStackRemoved,
+			ID:         stackName,
+			Parameters: ConvertMapStringStringToURLValues(params).Encode(),
+			TimeNano:   time.Now().UTC().UnixNano(),
+		}, true
+	}
+	return Notification{}, false
+}
+
+func (t *StackTracker) getStack(stackName string) *stackState {
+	stack, ok := t.stacks[stackName]
+	if !ok {
+		stack = &stackState{
+			services: map[string]convergedService{},
+			pending:  map[string]uint64{},
+		}
+		t.stacks[stackName] = stack
+	}
+	return stack
+}
+
+func (t *StackTracker) notify(n Notification) {
+	t.mux.Lock()
+	notiChan := t.notiChan
+	t.mux.Unlock()
+	if notiChan != nil {
+		notiChan <- n
+	}
+}
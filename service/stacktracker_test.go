@@ -0,0 +1,148 @@
+package service
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/suite"
+)
+
+type StackTrackerTestSuite struct {
+	suite.Suite
+	Tracker  *StackTracker
+	NotiChan chan Notification
+}
+
+func TestStackTrackerUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(StackTrackerTestSuite))
+}
+
+func (s *StackTrackerTestSuite) SetupTest() {
+	s.Tracker = NewStackTracker()
+	s.NotiChan = make(chan Notification, 10)
+	s.Tracker.Run(s.NotiChan)
+}
+
+func (s *StackTrackerTestSuite) Test_Converged_AllServices_Deployed() {
+	main := newStackService("serviceID1", "demo_main", "demo", 10)
+	db := newStackService("serviceID2", "demo_db", "demo", 11)
+
+	s.Tracker.Observe(main)
+	s.Tracker.Observe(db)
+	s.Tracker.Converged(main)
+	s.Empty(s.NotiChan)
+
+	s.Tracker.Converged(db)
+	s.Require().Len(s.NotiChan, 1)
+	n := <-s.NotiChan
+	s.Equal(EventTypeStackDeployed, n.EventType)
+	s.Equal("demo", n.ID)
+	params, err := url.ParseQuery(n.Parameters)
+	s.Require().NoError(err)
+	s.Equal("stack-deployed", params.Get("event"))
+	s.Equal("demo", params.Get("stack"))
+	s.Equal("demo_db,demo_main", params.Get("services"))
+}
+
+func (s *StackTrackerTestSuite) Test_Converged_WithoutStack_IsIgnored() {
+	service := newStackService("serviceID1", "demo", "", 10)
+
+	s.Tracker.Observe(service)
+	s.Tracker.Converged(service)
+	s.Empty(s.NotiChan)
+}
+
+func (s *StackTrackerTestSuite) Test_Converged_DeployedOnce() {
+	main := newStackService("serviceID1", "demo_main", "demo", 10)
+
+	s.Tracker.Observe(main)
+	s.Tracker.Converged(main)
+	s.Require().Len(s.NotiChan, 1)
+	<-s.NotiChan
+
+	// Unchanged services are not converging
+	s.Tracker.Observe(main)
+	s.Tracker.Converged(main)
+	s.Empty(s.NotiChan)
+}
+
+func (s *StackTrackerTestSuite) Test_Observe_UpdatedService_DeploysAgain() {
+	main := newStackService("serviceID1", "demo_main", "demo", 10)
+	s.Tracker.Observe(main)
+	s.Tracker.Converged(main)
+	<-s.NotiChan
+
+	updating := newStackService("serviceID1", "demo_main", "demo", 12)
+	s.Tracker.Observe(updating)
+	updated := newStackService("serviceID1", "demo_main", "demo", 14)
+	s.Tracker.Observe(updated)
+	// The inspected service can be older than the observed ones
+	s.Tracker.Converged(updating)
+
+	s.Require().Len(s.NotiChan, 1)
+	n := <-s.NotiChan
+	s.Equal(EventTypeStackDeployed, n.EventType)
+
+	s.Tracker.Observe(updated)
+	s.Tracker.Converged(updated)
+	s.Empty(s.NotiChan)
+}
+
+func (s *StackTrackerTestSuite) Test_Failed_ServiceDoesNotBlockStack() {
+	main := newStackService("serviceID1", "demo_main", "demo", 10)
+	db := newStackService("serviceID2", "demo_db", "demo", 11)
+
+	s.Tracker.Observe(main)
+	s.Tracker.Observe(db)
+	s.Tracker.Failed(db.ID)
+	s.Tracker.Converged(main)
+
+	s.Require().Len(s.NotiChan, 1)
+	n := <-s.NotiChan
+	params, err := url.ParseQuery(n.Parameters)
+	s.Require().NoError(err)
+	s.Equal("demo_main", params.Get("services"))
+}
+
+func (s *StackTrackerTestSuite) Test_Removed_LastService_Removed() {
+	main := newStackService("serviceID1", "demo_main", "demo", 10)
+	db := newStackService("serviceID2", "demo_db", "demo", 11)
+	s.Tracker.Observe(main)
+	s.Tracker.Observe(db)
+	s.Tracker.Converged(main)
+	s.Tracker.Converged(db)
+	<-s.NotiChan
+
+	s.Tracker.Removed(SwarmServiceMini{ID: "serviceID1", Name: "demo_main"})
+	s.Empty(s.NotiChan)
+
+	s.Tracker.Removed(SwarmServiceMini{ID: "serviceID2", Name: "demo_db"})
+	s.Require().Len(s.NotiChan, 1)
+	n := <-s.NotiChan
+	s.Equal(EventTypeStackRemoved, n.EventType)
+	s.Equal("demo", n.ID)
+	params, err := url.ParseQuery(n.Parameters)
+	s.Require().NoError(err)
+	s.Equal("stack-removed", params.Get("event"))
+	s.Equal("demo", params.Get("stack"))
+}
+
+func (s *StackTrackerTestSuite) Test_Removed_UnknownService_IsIgnored() {
+	s.Tracker.Removed(SwarmServiceMini{ID: "serviceID1"})
+	s.Empty(s.NotiChan)
+}
+
+func newStackService(ID, name, stack string, versionIndex uint64) swarm.Service {
+	labels := map[string]string{}
+	if len(stack) > 0 {
+		labels["com.docker.stack.namespace"] = stack
+	}
+	return swarm.Service{
+		ID:   ID,
+		Meta: swarm.Meta{Version: swarm.Version{Index: versionIndex}},
+		Spec: swarm.ServiceSpec{
+			Annotations: swarm.Annotations{Name: name, Labels: labels},
+		},
+	}
+}
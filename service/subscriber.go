@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	// NotifyTypeService is the type of service notifications
+	NotifyTypeService NotifyType = "service"
+	// NotifyTypeNode is the type of node notifications
+	NotifyTypeNode NotifyType = "node"
+	// NotifyTypeTask is the type of task notifications
+	NotifyTypeTask NotifyType = "task"
+	// NotifyTypeConfig is the type of config notifications
+	NotifyTypeConfig NotifyType = "config"
+	// NotifyTypeSecret is the type of secret notifications
+	NotifyTypeSecret NotifyType = "secret"
+	// NotifyTypeNetwork is the type of network notifications
+	NotifyTypeNetwork NotifyType = "network"
+	// NotifyTypeUpdate is the type of service update notifications
+	NotifyTypeUpdate NotifyType = "update"
+	// NotifyTypeConvergence is the type of convergence failure
+	// notifications
+	NotifyTypeConvergence NotifyType = "convergence"
+	// NotifyTypeStack is the type of stack notifications
+	NotifyTypeStack NotifyType = "stack"
+	// NotifyTypeSpec is the type of service notifications that are sent
+	// when the spec changed, without waiting for services to converge
+	NotifyTypeSpec NotifyType = "spec"
+)
+
+// notifyTypes are the types of notifications
+var notifyTypes = []NotifyType{
+	NotifyTypeService, NotifyTypeNode, NotifyTypeTask, NotifyTypeConfig,
+	NotifyTypeSecret, NotifyTypeNetwork, NotifyTypeUpdate,
+	NotifyTypeConvergence, NotifyTypeStack, NotifyTypeSpec,
+}
+
+// parseNotifyType returns the type of notifications named `name`
+// The error of unknown names lists the accepted names
+func parseNotifyType(name string) (NotifyType, error) {
+	accepted := []string{}
+	for _, notifyType := range notifyTypes {
+		if string(notifyType) == name {
+			return notifyType, nil
+		}
+		accepted = append(accepted, string(notifyType))
+	}
+	return "", fmt.Errorf("Unknown notification type %s, accepted types are %s",
+		name, strings.Join(accepted, ", "))
+}
+
+// NotificationFilter selects the notifications a subscriber receives
+// Empty fields match all notifications
+type NotificationFilter struct {
+	Type      NotifyType
+	EventType EventType
+	ID        string
+}
+
+// Match returns true when a notification of `notifyType` matches the filter
+func (f NotificationFilter) Match(notifyType NotifyType, n Notification) bool {
+	return (len(f.Type) == 0 || f.Type == notifyType) &&
+		(len(f.EventType) == 0 || f.EventType == n.EventType) &&
+		(len(f.ID) == 0 || f.ID == n.ID)
+}
+
+type subscription struct {
+	filter NotificationFilter
+	fn     func(Notification)
+}
+
+// subscriptions are in-process consumers of notifications
+type subscriptions struct {
+	subs   map[int]subscription
+	nextID int
+	mux    sync.RWMutex
+}
+
+func newSubscriptions() *subscriptions {
+	return &subscriptions{subs: map[int]subscription{}}
+}
+
+// add subscribes `fn` to notifications that match `filter` and returns a
+// function that removes the subscription
+func (s *subscriptions) add(filter NotificationFilter, fn func(Notification)) func() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	id := s.nextID
+	s.nextID++
+	s.subs[id] = subscription{filter: filter, fn: fn}
+
+	return func() {
+		s.mux.Lock()
+		defer s.mux.Unlock()
+		delete(s.subs, id)
+	}
+}
+
+// publish calls every subscriber whose filter matches `n`
+// Notifications whose context is canceled are dropped
+func (s *subscriptions) publish(ctx context.Context, notifyType NotifyType, n Notification) {
+	if s == nil || ctx.Err() != nil {
+		return
+	}
+	s.mux.RLock()
+	fns := []func(Notification){}
+	for _, sub := range s.subs {
+		if sub.filter.Match(notifyType, n) {
+			fns = append(fns, sub.fn)
+		}
+	}
+	s.mux.RUnlock()
+
+	n.Done = nil
+	for _, fn := range fns {
+		fn(n)
+	}
+}
+
+// has returns true when a subscription receives notifications of
+// `notifyType`
+func (s *subscriptions) has(notifyType NotifyType) bool {
+	if s == nil {
+		return false
+	}
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	for _, sub := range s.subs {
+		if len(sub.filter.Type) == 0 || sub.filter.Type == notifyType {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *subscriptions) len() int {
+	if s == nil {
+		return 0
+	}
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return len(s.subs)
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SubscriberTestSuite struct {
+	suite.Suite
+}
+
+func TestSubscriberUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(SubscriberTestSuite))
+}
+
+func (s *SubscriberTestSuite) Test_NotificationFilter_Match() {
+	n := Notification{EventType: EventTypeCreate, ID: "id1"}
+
+	s.True(NotificationFilter{}.Match(NotifyTypeService, n))
+	s.True(NotificationFilter{Type: NotifyTypeService, EventType: EventTypeCreate, ID: "id1"}.Match(NotifyTypeService, n))
+	s.False(NotificationFilter{Type: NotifyTypeNode}.Match(NotifyTypeService, n))
+	s.False(NotificationFilter{EventType: EventTypeRemove}.Match(NotifyTypeService, n))
+	s.False(NotificationFilter{ID: "id2"}.Match(NotifyTypeService, n))
+}
+
+func (s *SubscriberTestSuite) Test_Publish_CallsMatchingSubscribers() {
+	subs := newSubscriptions()
+	services := []Notification{}
+	nodes := []Notification{}
+	subs.add(NotificationFilter{Type: NotifyTypeService}, func(n Notification) {
+		services = append(services, n)
+	})
+	unsubscribe := subs.add(NotificationFilter{Type: NotifyTypeNode}, func(n Notification) {
+		nodes = append(nodes, n)
+	})
+
+	done := make(chan struct{})
+	subs.publish(context.Background(), NotifyTypeService, Notification{ID: "sid1", Done: done})
+	subs.publish(context.Background(), NotifyTypeNode, Notification{ID: "nid1"})
+	unsubscribe()
+	subs.publish(context.Background(), NotifyTypeNode, Notification{ID: "nid2"})
+
+	s.Require().Len(services, 1)
+	s.Equal("sid1", services[0].ID)
+	s.Nil(services[0].Done)
+	s.Require().Len(nodes, 1)
+	s.Equal("nid1", nodes[0].ID)
+	s.Equal(1, subs.len())
+}
+
+func (s *SubscriberTestSuite) Test_Publish_CanceledContext_DropsNotification() {
+	subs := newSubscriptions()
+	called := false
+	subs.add(NotificationFilter{}, func(n Notification) {
+		called = true
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	subs.publish(ctx, NotifyTypeService, Notification{ID: "sid1"})
+
+	s.False(called)
+}
+
+func (s *SubscriberTestSuite) Test_Subscribe_ReceivesDistributedNotifications() {
+	logger := log.New(ioutil.Discard, "", 0)
+	notifyD := newNotifyDistributor(map[string]NotifyEndpoint{},
+		NewCancelManager(true), NewCancelManager(true), 1, logger)
+	s.False(notifyD.HasServiceListeners())
+
+	received := make(chan Notification, 1)
+	notifyD.Subscribe(NotificationFilter{Type: NotifyTypeService}, func(n Notification) {
+		received <- n
+	})
+	s.True(notifyD.HasServiceListeners())
+
+	serviceChan := make(chan Notification)
+	notifyD.Run(serviceChan, nil)
+	serviceChan <- Notification{
+		EventType:  EventTypeCreate,
+		ID:         "sid1",
+		Parameters: "hello=world",
+		TimeNano:   int64(1),
+	}
+
+	select {
+	case n := <-received:
+		s.Equal("sid1", n.ID)
+		s.Equal("hello=world", n.Parameters)
+	case <-time.After(time.Second * 5):
+		s.Fail("Timeout")
+	}
+}
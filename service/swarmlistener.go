@@ -2,22 +2,26 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"../metrics"
+	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
 )
 
 // SwarmListening provides public api for interacting with swarm listener
 type SwarmListening interface {
-	Run()
+	Run() error
 	NotifyServices(ignoreCache bool)
 	NotifyNodes(ignoreCache bool)
 	GetServicesParameters(ctx context.Context) ([]map[string]string, error)
 	GetNodesParameters(ctx context.Context) ([]map[string]string, error)
+	GetPrometheusTargets() []PrometheusTargetGroup
+	GetAuditEntries(limit int, filter AuditFilter) ([]AuditEntry, error)
 }
 
 // CreateRemoveCancelManager combines two cancel managers for creating and
@@ -55,6 +59,35 @@ func (c *CreateRemoveCancelManager) RemoveEvent(event Event) bool {
 	return c.removeCancelManager.Delete(event.ID, event.TimeNano)
 }
 
+// SwarmObjectWatch listens for, caches, and notifies docker configs or
+// secrets of `Kind`
+type SwarmObjectWatch struct {
+	Kind                      NotifyType
+	Listener                  SwarmObjectListening
+	Client                    SwarmObjectInspector
+	Cache                     SwarmObjectCacher
+	EventChan                 chan Event
+	NotificationChan          chan Notification
+	CreateRemoveCancelManager *CreateRemoveCancelManager
+}
+
+// NewSwarmObjectWatch creates a `SwarmObjectWatch`
+func NewSwarmObjectWatch(
+	kind NotifyType, listener SwarmObjectListening,
+	client SwarmObjectInspector, cache SwarmObjectCacher) *SwarmObjectWatch {
+	return &SwarmObjectWatch{
+		Kind:             kind,
+		Listener:         listener,
+		Client:           client,
+		Cache:            cache,
+		EventChan:        make(chan Event),
+		NotificationChan: make(chan Notification),
+		CreateRemoveCancelManager: &CreateRemoveCancelManager{
+			createCancelManager: NewCancelManager(false),
+			removeCancelManager: NewCancelManager(false)},
+	}
+}
+
 // SwarmListener provides public api
 type SwarmListener struct {
 	SSListener         SwarmServiceListening
@@ -69,11 +102,45 @@ type SwarmListener struct {
 	NodeEventChan        chan Event
 	NodeNotificationChan chan Notification
 
+	NetworkListener         NetworkListening
+	NetworkClient           NetworkInspector
+	NetworkCache            NetworkCacher
+	NetworkEventChan        chan Event
+	NetworkNotificationChan chan Notification
+
+	TaskWatcher          *TaskWatcher
+	Reconciler           *Reconciler
+	TaskNotificationChan chan Notification
+
+	UpdateTracker          *UpdateTracker
+	UpdateNotificationChan chan Notification
+
+	StackTracker          *StackTracker
+	StackNotificationChan chan Notification
+
+	SpecTracker          *SpecTracker
+	SpecNotificationChan chan Notification
+
+	ConvergenceNotificationChan chan Notification
+
+	ConfigWatch *SwarmObjectWatch
+	SecretWatch *SwarmObjectWatch
+
+	ConsulRegistrar *ConsulRegistrar
+	XDSServer       *XDSServer
+	DNSServer       *DNSServer
+
 	NotifyDistributor NotifyDistributing
 
 	ServiceCreateRemoveCancelManager *CreateRemoveCancelManager
 	NodeCreateRemoveCancelManager    *CreateRemoveCancelManager
+	NetworkCreateRemoveCancelManager *CreateRemoveCancelManager
 	IncludeNodeInfo                  bool
+	IncludeEndpointInfo              bool
+	FullRemoveParameters             bool
+	CacheServices                    bool
+	CacheNodes                       bool
+	Audit                            AuditLogging
 	IgnoreKey                        string
 	IncludeKey                       string
 	Log                              *log.Logger
@@ -101,23 +168,28 @@ func newSwarmListener(
 ) *SwarmListener {
 
 	return &SwarmListener{
-		SSListener:           ssListener,
-		SSClient:             ssClient,
-		SSCache:              ssCache,
-		SSEventChan:          make(chan Event),
-		SSNotificationChan:   make(chan Notification),
-		NodeListener:         nodeListener,
-		NodeClient:           nodeClient,
-		NodeCache:            nodeCache,
-		NodeEventChan:        make(chan Event),
-		NodeNotificationChan: make(chan Notification),
-		NotifyDistributor:    notifyDistributor,
+		SSListener:              ssListener,
+		SSClient:                ssClient,
+		SSCache:                 ssCache,
+		SSEventChan:             make(chan Event),
+		SSNotificationChan:      make(chan Notification),
+		NodeListener:            nodeListener,
+		NodeClient:              nodeClient,
+		NodeCache:               nodeCache,
+		NodeEventChan:           make(chan Event),
+		NodeNotificationChan:    make(chan Notification),
+		NetworkEventChan:        make(chan Event),
+		NetworkNotificationChan: make(chan Notification),
+		NotifyDistributor:       notifyDistributor,
 		ServiceCreateRemoveCancelManager: &CreateRemoveCancelManager{
 			createCancelManager: serviceCreateCancelManager,
 			removeCancelManager: serviceRemoveCancelManager},
 		NodeCreateRemoveCancelManager: &CreateRemoveCancelManager{
 			createCancelManager: nodeCreateCancelManager,
 			removeCancelManager: nodeRemoveCancelManager},
+		NetworkCreateRemoveCancelManager: &CreateRemoveCancelManager{
+			createCancelManager: NewCancelManager(false),
+			removeCancelManager: NewCancelManager(false)},
 		IncludeNodeInfo: includeNodeInfo,
 		IgnoreKey:       ignoreKey,
 		IncludeKey:      includeKey,
@@ -126,46 +198,158 @@ func newSwarmListener(
 }
 
 // NewSwarmListenerFromEnv creats `SwarmListener` from environment variables
+// The variables are converted into options of `NewSwarmListener`
 func NewSwarmListenerFromEnv(retries, interval int, logger *log.Logger) (*SwarmListener, error) {
-	ignoreKey := os.Getenv("DF_NOTIFY_LABEL")
-	includeNodeInfo := os.Getenv("DF_INCLUDE_NODE_IP_INFO") == "true"
-
 	dockerClient, err := NewDockerClientFromEnv()
 	if err != nil {
 		return nil, err
 	}
-	ssListener := NewSwarmServiceListener(dockerClient, logger)
-	ssClient := NewSwarmServiceClient(dockerClient, ignoreKey, "com.df.scrapeNetwork", logger)
-	ssCache := NewSwarmServiceCache()
 
-	nodeListener := NewNodeListener(dockerClient, logger)
-	nodeClient := NewNodeClient(dockerClient)
-	nodeCache := NewNodeCache()
+	var clusterID string
+	if len(os.Getenv("DF_NOTIFY_FORMAT")) > 0 || len(os.Getenv("DF_REDIS_ADDR")) > 0 {
+		info, err := dockerClient.Info(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		if info.Swarm.Cluster != nil {
+			clusterID = info.Swarm.Cluster.ID
+		}
+	}
+
+	opts, err := optionsFromEnv(retries, interval, clusterID, logger)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, WithDockerClient(dockerClient), WithLogger(logger))
+	return NewSwarmListener(opts...)
+}
 
-	notifyDistributor := NewNotifyDistributorFromEnv(retries, interval, logger)
+// optionsFromEnv converts environment variables into options of
+// `NewSwarmListener`. `source` is used as the CloudEvents source
+func optionsFromEnv(retries, interval int, source string, logger *log.Logger) ([]Option, error) {
+	enablePrometheusSD := os.Getenv("DF_ENABLE_PROMETHEUS_SD") == "true"
+	format := NotificationFormatQuery
+	if len(os.Getenv("DF_NOTIFY_FORMAT")) > 0 {
+		var err error
+		format, err = parseNotificationFormat(os.Getenv("DF_NOTIFY_FORMAT"))
+		if err != nil {
+			return nil, fmt.Errorf("DF_NOTIFY_FORMAT: %v", err)
+		}
+	}
+	createServiceAddrs, removeServiceAddrs := serviceNotifyAddrsFromEnv()
 
-	return newSwarmListener(
-		ssListener,
-		ssClient,
-		ssCache,
-		nodeListener,
-		nodeClient,
-		nodeCache,
-		notifyDistributor,
-		NewCancelManager(false),
-		NewCancelManager(false),
-		NewCancelManager(false),
-		NewCancelManager(false),
-		includeNodeInfo,
-		ignoreKey,
-		"com.docker.stack.namespace",
-		logger,
-	), nil
+	opts := []Option{
+		WithIncludeNodeInfo(os.Getenv("DF_INCLUDE_NODE_IP_INFO") == "true"),
+		WithIncludeEndpointInfo(os.Getenv("DF_INCLUDE_ENDPOINT_INFO") == "true"),
+		WithFullRemoveParameters(os.Getenv("DF_NOTIFY_FULL_REMOVE_PARAMETERS") == "true"),
+		WithWaitForHealthy(os.Getenv("DF_WAIT_FOR_HEALTHY") == "true"),
+		WithCache(enablePrometheusSD, false),
+		WithRetry(retries, interval),
+		WithNotificationFormat(format, source),
+		WithServiceNotifyURLs(
+			splitAddrs(createServiceAddrs), splitAddrs(removeServiceAddrs)),
+		WithNodeNotifyURLs(
+			splitAddrs(os.Getenv("DF_NOTIFY_CREATE_NODE_URL")),
+			splitAddrs(os.Getenv("DF_NOTIFY_REMOVE_NODE_URL"))),
+		WithTaskNotifyURLs(splitAddrs(os.Getenv("DF_NOTIFY_TASK_URL"))),
+		WithUpdateNotifyURLs(splitAddrs(os.Getenv("DF_NOTIFY_UPDATE_URL"))),
+		WithConvergenceNotifyURLs(splitAddrs(os.Getenv("DF_NOTIFY_CONVERGENCE_URL"))),
+		WithStackNotifyURLs(splitAddrs(os.Getenv("DF_NOTIFY_STACK_URL"))),
+		WithServiceSpecNotifyURLs(
+			splitAddrs(os.Getenv("DF_NOTIFY_CREATE_SERVICE_SPEC_URL")),
+			splitAddrs(os.Getenv("DF_NOTIFY_REMOVE_SERVICE_SPEC_URL"))),
+		WithConfigNotifyURLs(
+			splitAddrs(os.Getenv("DF_NOTIFY_CREATE_CONFIG_URL")),
+			splitAddrs(os.Getenv("DF_NOTIFY_REMOVE_CONFIG_URL"))),
+		WithSecretNotifyURLs(
+			splitAddrs(os.Getenv("DF_NOTIFY_CREATE_SECRET_URL")),
+			splitAddrs(os.Getenv("DF_NOTIFY_REMOVE_SECRET_URL"))),
+		WithNetworkNotifyURLs(
+			splitAddrs(os.Getenv("DF_NOTIFY_CREATE_NETWORK_URL")),
+			splitAddrs(os.Getenv("DF_NOTIFY_REMOVE_NETWORK_URL"))),
+		WithTemplates(templateConfigsFromEnv()),
+	}
+	if notifyLabel := os.Getenv("DF_NOTIFY_LABEL"); len(notifyLabel) > 0 {
+		opts = append(opts, WithNotifyLabel(notifyLabel))
+	}
+	if maxGap, err := strconv.Atoi(os.Getenv("DF_EVENT_STREAM_MAX_GAP")); err == nil && maxGap >= 0 {
+		opts = append(opts, WithEventStreamMaxGap(time.Duration(maxGap)*time.Second))
+	}
+	if timeout, err := strconv.Atoi(os.Getenv("DF_SERVICE_CONVERGENCE_TIMEOUT")); err == nil && timeout > 0 {
+		opts = append(opts, WithConvergenceTimeout(time.Duration(timeout)*time.Second))
+	}
+	if watchInterval, err := strconv.Atoi(os.Getenv("DF_TASK_WATCH_INTERVAL")); err == nil && watchInterval > 0 {
+		opts = append(opts, WithTaskWatchInterval(time.Duration(watchInterval)*time.Second))
+	}
+	if reconcileInterval, err := strconv.Atoi(os.Getenv("DF_RECONCILE_INTERVAL")); err == nil && reconcileInterval > 0 {
+		opts = append(opts, WithReconcileInterval(time.Duration(reconcileInterval)*time.Second))
+	}
 
+	requestTemplates, err := LoadRequestTemplatesFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, WithRequestTemplates(requestTemplates))
+	if addr, token, syncInterval := consulConfigFromEnv(); len(addr) > 0 {
+		opts = append(opts, WithConsul(addr, token, syncInterval))
+	}
+	if redisSink := NewRedisSinkFromEnv("service", source, retries, interval, logger); redisSink != nil {
+		redisTypes, err := RedisTypesFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithRedisSink(redisSink, redisTypes))
+	}
+	if addr, listenerPort := xdsConfigFromEnv(); len(addr) > 0 {
+		opts = append(opts, WithXDS(addr, listenerPort))
+	}
+	if addr := os.Getenv("DF_DNS_ADDR"); len(addr) > 0 {
+		domain := "swarm"
+		if len(os.Getenv("DF_DNS_DOMAIN")) > 0 {
+			domain = os.Getenv("DF_DNS_DOMAIN")
+		}
+		opts = append(opts, WithDNSServer(addr, domain))
+	}
+	auditLog, err := NewFileAuditLogFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if auditLog != nil {
+		opts = append(opts, WithAuditLog(auditLog))
+	}
+	return opts, nil
+}
+
+// splitAddrs splits comma separated addresses. Empty addresses are
+// skipped
+func splitAddrs(addrs string) []string {
+	split := []string{}
+	for _, addr := range strings.Split(addrs, ",") {
+		if len(addr) > 0 {
+			split = append(split, addr)
+		}
+	}
+	return split
 }
 
 // Run starts swarm listener
-func (l *SwarmListener) Run() {
+// An error is returned when the xDS or DNS server can not listen on its
+// address
+func (l *SwarmListener) Run() error {
+	if l.XDSServer != nil {
+		if err := l.XDSServer.Run(); err != nil {
+			return err
+		}
+	}
+	if l.DNSServer != nil {
+		if err := l.DNSServer.Run(); err != nil {
+			return err
+		}
+	}
+	if l.ConsulRegistrar != nil {
+		l.ConsulRegistrar.Run()
+	}
+
 	l.connectServiceChannels()
 	l.connectNodeChannels()
 
@@ -175,14 +359,317 @@ func (l *SwarmListener) Run() {
 	if l.NodeEventChan != nil {
 		l.NodeListener.ListenForNodeEvents(l.NodeEventChan)
 	}
+	if l.NetworkListener != nil {
+		l.runNetworkListener()
+	}
 
 	l.NotifyDistributor.Run(l.SSNotificationChan, l.NodeNotificationChan)
+
+	if l.TaskWatcher != nil {
+		l.runTaskWatcher()
+	}
+	if l.Reconciler != nil {
+		l.Reconciler.Run(l.SSEventChan, l.NodeEventChan)
+	}
+	if l.UpdateTracker != nil {
+		l.runUpdateTracker()
+	}
+	if l.StackTracker != nil {
+		l.runStackTracker()
+	}
+	if l.SpecTracker != nil {
+		l.runSpecTracker()
+	}
+	if l.ConvergenceNotificationChan != nil {
+		l.runConvergenceNotifications()
+	}
+	if l.ConfigWatch != nil {
+		l.runSwarmObjectWatch(l.ConfigWatch)
+	}
+	if l.SecretWatch != nil {
+		l.runSwarmObjectWatch(l.SecretWatch)
+	}
+	return nil
+}
+
+// runNetworkListener starts listening for network events. Network events
+// are ignored when there are no network listeners
+func (l *SwarmListener) runNetworkListener() {
+	if !l.NotifyDistributor.HasListeners(NotifyTypeNetwork) {
+		l.NetworkEventChan = nil
+		l.NetworkNotificationChan = nil
+		return
+	}
+
+	go func() {
+		for event := range l.NetworkEventChan {
+			if event.Type == EventTypeCreate {
+				go l.processNetworkEventCreate(event)
+			} else {
+				go l.processNetworkEventRemove(event)
+			}
+		}
+	}()
+	l.NetworkListener.ListenForNetworkEvents(l.NetworkEventChan)
+	l.NotifyDistributor.RunType(NotifyTypeNetwork, l.NetworkNotificationChan)
+}
+
+func (l *SwarmListener) processNetworkEventCreate(event Event) {
+	ctx := l.NetworkCreateRemoveCancelManager.AddEvent(event)
+	defer l.NetworkCreateRemoveCancelManager.RemoveEvent(event)
+
+	doneChan := make(chan struct{})
+
+	go func() {
+		network, err := l.NetworkClient.NetworkInspect(ctx, event.ID)
+		if err != nil {
+			if !strings.Contains(err.Error(), "context canceled") {
+				l.Log.Printf("ERROR: %v", err)
+			}
+			return
+		}
+		// Only overlay networks are notified
+		if network.Driver != "overlay" {
+			return
+		}
+		nm := MinifyNetwork(network)
+
+		// Store in cache
+		isUpdated := l.NetworkCache.InsertAndCheck(nm)
+		if !isUpdated {
+			return
+		}
+
+		params := GetNetworkMiniCreateParameters(nm)
+		paramsEncoded := ConvertMapStringStringToURLValues(params).Encode()
+		l.placeOnNotificationChan(l.NetworkNotificationChan, Notification{
+			EventType:  event.Type,
+			ID:         nm.ID,
+			Parameters: paramsEncoded,
+			TimeNano:   event.TimeNano,
+			Done:       doneChan,
+		})
+	}()
+
+	for {
+		select {
+		case <-doneChan:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (l *SwarmListener) processNetworkEventRemove(event Event) {
+	ctx := l.NetworkCreateRemoveCancelManager.AddEvent(event)
+	defer l.NetworkCreateRemoveCancelManager.RemoveEvent(event)
+
+	doneChan := make(chan struct{})
+	go func() {
+		nm, ok := l.NetworkCache.Get(event.ID)
+		if !ok {
+			return
+		}
+		l.NetworkCache.Delete(nm.ID)
+
+		params := GetNetworkMiniRemoveParameters(nm)
+		paramsEncoded := ConvertMapStringStringToURLValues(params).Encode()
+		l.placeOnNotificationChan(l.NetworkNotificationChan, Notification{
+			EventType:  event.Type,
+			ID:         nm.ID,
+			Parameters: paramsEncoded,
+			TimeNano:   event.TimeNano,
+			Done:       doneChan,
+		})
+	}()
+
+	for {
+		select {
+		case <-doneChan:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runSwarmObjectWatch starts listening for config or secret events. The
+// watch is disabled when there are no listeners of its kind
+func (l *SwarmListener) runSwarmObjectWatch(w *SwarmObjectWatch) {
+	if !l.NotifyDistributor.HasListeners(w.Kind) {
+		w.EventChan = nil
+		w.NotificationChan = nil
+		return
+	}
+
+	go func() {
+		for event := range w.EventChan {
+			if event.Type == EventTypeCreate {
+				go l.processSwarmObjectEventCreate(w, event)
+			} else {
+				go l.processSwarmObjectEventRemove(w, event)
+			}
+		}
+	}()
+	w.Listener.ListenForSwarmObjectEvents(w.EventChan)
+	l.NotifyDistributor.RunType(w.Kind, w.NotificationChan)
+}
+
+func (l *SwarmListener) processSwarmObjectEventCreate(w *SwarmObjectWatch, event Event) {
+	ctx := w.CreateRemoveCancelManager.AddEvent(event)
+	defer w.CreateRemoveCancelManager.RemoveEvent(event)
+
+	doneChan := make(chan struct{})
+
+	go func() {
+		om, err := w.Client.SwarmObjectInspect(ctx, w.Kind, event.ID)
+		if err != nil {
+			if !strings.Contains(err.Error(), "context canceled") {
+				l.Log.Printf("ERROR: %v", err)
+			}
+			return
+		}
+
+		// Store in cache
+		isUpdated := w.Cache.InsertAndCheck(om)
+		if !isUpdated {
+			return
+		}
+
+		params := GetSwarmObjectMiniCreateParameters(om)
+		paramsEncoded := ConvertMapStringStringToURLValues(params).Encode()
+		l.placeOnNotificationChan(w.NotificationChan, Notification{
+			EventType:  event.Type,
+			ID:         om.ID,
+			Parameters: paramsEncoded,
+			TimeNano:   event.TimeNano,
+			Done:       doneChan,
+		})
+	}()
+
+	for {
+		select {
+		case <-doneChan:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (l *SwarmListener) processSwarmObjectEventRemove(w *SwarmObjectWatch, event Event) {
+	ctx := w.CreateRemoveCancelManager.AddEvent(event)
+	defer w.CreateRemoveCancelManager.RemoveEvent(event)
+
+	doneChan := make(chan struct{})
+
+	go func() {
+		om, ok := w.Cache.Get(event.ID)
+		if !ok {
+			return
+		}
+		w.Cache.Delete(om.ID)
+
+		params := GetSwarmObjectMiniRemoveParameters(om)
+		paramsEncoded := ConvertMapStringStringToURLValues(params).Encode()
+		l.placeOnNotificationChan(w.NotificationChan, Notification{
+			EventType:  event.Type,
+			ID:         om.ID,
+			Parameters: paramsEncoded,
+			TimeNano:   event.TimeNano,
+			Done:       doneChan,
+		})
+	}()
+
+	for {
+		select {
+		case <-doneChan:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runTaskWatcher starts the task watcher. Task notifications are only
+// sent when there are task listeners and services are only refreshed
+// when their `NodeInfo` is included
+func (l *SwarmListener) runTaskWatcher() {
+	if !l.NotifyDistributor.HasListeners(NotifyTypeTask) {
+		l.TaskNotificationChan = nil
+	} else if l.TaskNotificationChan == nil {
+		l.TaskNotificationChan = make(chan Notification)
+	}
+	var serviceEventChan chan<- Event
+	if l.IncludeNodeInfo && l.SSEventChan != nil {
+		serviceEventChan = l.SSEventChan
+	}
+	l.TaskWatcher.Run(l.TaskNotificationChan, serviceEventChan)
+	l.NotifyDistributor.RunType(NotifyTypeTask, l.TaskNotificationChan)
+}
+
+// runUpdateTracker starts distributing update notifications. Updates are
+// observed while services are inspected, so they are only tracked when
+// service events are processed
+func (l *SwarmListener) runUpdateTracker() {
+	if !l.NotifyDistributor.HasListeners(NotifyTypeUpdate) || l.SSEventChan == nil {
+		l.UpdateNotificationChan = nil
+		return
+	}
+	if l.UpdateNotificationChan == nil {
+		l.UpdateNotificationChan = make(chan Notification)
+	}
+	l.UpdateTracker.Run(l.UpdateNotificationChan)
+	l.NotifyDistributor.RunType(NotifyTypeUpdate, l.UpdateNotificationChan)
+}
+
+// runStackTracker starts distributing stack notifications. Stacks are
+// aggregated while services are inspected, so they are only tracked when
+// service events are processed
+func (l *SwarmListener) runStackTracker() {
+	if !l.NotifyDistributor.HasListeners(NotifyTypeStack) || l.SSEventChan == nil {
+		l.StackNotificationChan = nil
+		return
+	}
+	if l.StackNotificationChan == nil {
+		l.StackNotificationChan = make(chan Notification)
+	}
+	l.StackTracker.Run(l.StackNotificationChan)
+	l.NotifyDistributor.RunType(NotifyTypeStack, l.StackNotificationChan)
+}
+
+// runSpecTracker starts distributing spec notifications. Specs are observed
+// while services are inspected, so they are only tracked when service
+// events are processed
+func (l *SwarmListener) runSpecTracker() {
+	if !l.NotifyDistributor.HasListeners(NotifyTypeSpec) || l.SSEventChan == nil {
+		l.SpecNotificationChan = nil
+		return
+	}
+	if l.SpecNotificationChan == nil {
+		l.SpecNotificationChan = make(chan Notification)
+	}
+	l.SpecTracker.Run(l.SpecNotificationChan)
+	l.NotifyDistributor.RunType(NotifyTypeSpec, l.SpecNotificationChan)
+}
+
+// runConvergenceNotifications starts distributing convergence failures
+// Failures are only notified when there are convergence listeners and
+// service events are processed
+func (l *SwarmListener) runConvergenceNotifications() {
+	if !l.NotifyDistributor.HasListeners(NotifyTypeConvergence) || l.SSEventChan == nil {
+		l.ConvergenceNotificationChan = nil
+		return
+	}
+	l.NotifyDistributor.RunType(NotifyTypeConvergence, l.ConvergenceNotificationChan)
 }
 
 func (l *SwarmListener) connectServiceChannels() {
 
-	// Remove service channels if there are no service listeners
-	if !l.NotifyDistributor.HasServiceListeners() {
+	// Remove service channels if there are no service listeners and the
+	// service cache is not required
+	if !l.NotifyDistributor.HasServiceListeners() && !l.CacheServices {
 		l.SSEventChan = nil
 		l.SSNotificationChan = nil
 		return
@@ -208,18 +695,43 @@ func (l *SwarmListener) processServiceEventCreate(event Event) {
 	go func() {
 		service, err := l.SSClient.SwarmServiceInspect(ctx, event.ID, l.IncludeNodeInfo)
 		if err != nil {
-			if !strings.Contains(err.Error(), "context canceled") {
+			// Inspections are canceled by newer events of the service,
+			// which keep the service converging
+			canceled := strings.Contains(err.Error(), "context canceled")
+			if !canceled {
 				l.Log.Printf("ERROR: %v", err)
 			}
+			if l.StackTracker != nil && !canceled {
+				l.StackTracker.Failed(event.ID)
+			}
+			convergenceErr, ok := err.(*ConvergenceError)
+			if ok {
+				metrics.RecordConvergenceFailure()
+			}
+			if ok && l.ConvergenceNotificationChan != nil {
+				params := GetConvergenceErrorParameters(convergenceErr)
+				paramsEncoded := ConvertMapStringStringToURLValues(params).Encode()
+				l.placeOnNotificationChan(l.ConvergenceNotificationChan, Notification{
+					EventType:  EventTypeConvergenceFailed,
+					ID:         convergenceErr.ServiceID,
+					Parameters: paramsEncoded,
+					TimeNano:   event.TimeNano,
+					Done:       doneChan,
+				})
+			}
 			return
 		}
 		// Ignored service (filtered by `com.df.notify`)
 		if service == nil {
 			return
 		}
-		ssm := MinifySwarmService(*service, l.IgnoreKey, l.IncludeKey)
+		if l.StackTracker != nil {
+			l.StackTracker.Converged(service.Service)
+		}
+		ssm := minifySwarmService(*service, l.IgnoreKey, l.IncludeKey, l.IncludeEndpointInfo)
 
 		// Store in cache
+		previous, isCached := l.SSCache.Get(ssm.ID)
 		isUpdated := l.SSCache.InsertAndCheck(ssm)
 		if !isUpdated {
 			return
@@ -227,9 +739,24 @@ func (l *SwarmListener) processServiceEventCreate(event Event) {
 		metrics.RecordService(l.SSCache.Len())
 
 		params := GetSwarmServiceMiniCreateParameters(ssm)
+		var diff *ServiceDiff
+		if isCached {
+			d := DiffSwarmServiceMini(previous, ssm)
+			diff = &d
+			for k, v := range GetServiceDiffParameters(d) {
+				params[k] = v
+			}
+		}
 		paramsEncoded := ConvertMapStringStringToURLValues(params).Encode()
-		l.placeOnNotificationChan(
-			l.SSNotificationChan, event.Type, event.TimeNano, ssm.ID, paramsEncoded, doneChan)
+		l.placeOnNotificationChan(l.SSNotificationChan, Notification{
+			EventType:  event.Type,
+			ID:         ssm.ID,
+			Parameters: paramsEncoded,
+			TimeNano:   event.TimeNano,
+			Done:       doneChan,
+			Service:    &ssm,
+			Diff:       diff,
+		})
 	}()
 
 	for {
@@ -249,17 +776,37 @@ func (l *SwarmListener) processServiceEventRemove(event Event) {
 	doneChan := make(chan struct{})
 
 	go func() {
+		// Services are observed before they converge, so they can be
+		// removed before they are cached
+		if l.SpecTracker != nil {
+			l.SpecTracker.Removed(event.ID)
+		}
 		ssm, ok := l.SSCache.Get(event.ID)
 		if !ok {
 			return
 		}
 		l.SSCache.Delete(ssm.ID)
 		metrics.RecordService(l.SSCache.Len())
+		if l.UpdateTracker != nil {
+			l.UpdateTracker.Forget(ssm.ID)
+		}
+		if l.StackTracker != nil {
+			l.StackTracker.Removed(ssm)
+		}
 
 		params := GetSwarmServiceMiniRemoveParameters(ssm)
+		if l.FullRemoveParameters {
+			params = GetSwarmServiceMiniFullRemoveParameters(ssm)
+		}
 		paramsEncoded := ConvertMapStringStringToURLValues(params).Encode()
-		l.placeOnNotificationChan(
-			l.SSNotificationChan, event.Type, event.TimeNano, ssm.ID, paramsEncoded, doneChan)
+		l.placeOnNotificationChan(l.SSNotificationChan, Notification{
+			EventType:  event.Type,
+			ID:         ssm.ID,
+			Parameters: paramsEncoded,
+			TimeNano:   event.TimeNano,
+			Done:       doneChan,
+			Service:    &ssm,
+		})
 	}()
 
 	for {
@@ -274,8 +821,11 @@ func (l *SwarmListener) processServiceEventRemove(event Event) {
 
 func (l *SwarmListener) connectNodeChannels() {
 
-	// Remove node channels if there are no service listeners
-	if !l.NotifyDistributor.HasNodeListeners() {
+	// Remove node channels if there are no node listeners and the
+	// node cache is not required. Node events are still needed to refresh
+	// the `NodeInfo` of services
+	if !l.NotifyDistributor.HasNodeListeners() && !l.CacheNodes &&
+		!l.refreshesServicesOnNodeEvents() {
 		l.NodeEventChan = nil
 		l.NodeNotificationChan = nil
 		return
@@ -314,9 +864,18 @@ func (l *SwarmListener) processNodeEventCreate(event Event) {
 		if !isUpdated {
 			return
 		}
+		go l.refreshServicesOnNode(nm.ID, event.TimeNano)
+
 		params := GetNodeMiniCreateParameters(nm)
 		paramsEncoded := ConvertMapStringStringToURLValues(params).Encode()
-		l.placeOnNotificationChan(l.NodeNotificationChan, event.Type, event.TimeNano, nm.ID, paramsEncoded, doneChan)
+		l.placeOnNotificationChan(l.NodeNotificationChan, Notification{
+			EventType:  event.Type,
+			ID:         nm.ID,
+			Parameters: paramsEncoded,
+			TimeNano:   event.TimeNano,
+			Done:       doneChan,
+			Node:       &nm,
+		})
 	}()
 
 	for {
@@ -340,10 +899,21 @@ func (l *SwarmListener) processNodeEventRemove(event Event) {
 			return
 		}
 		l.NodeCache.Delete(nm.ID)
+		go l.refreshServicesOnNode(nm.ID, event.TimeNano)
 
 		params := GetNodeMiniRemoveParameters(nm)
+		if l.FullRemoveParameters {
+			params = GetNodeMiniFullRemoveParameters(nm)
+		}
 		paramsEncoded := ConvertMapStringStringToURLValues(params).Encode()
-		l.placeOnNotificationChan(l.NodeNotificationChan, event.Type, event.TimeNano, nm.ID, paramsEncoded, doneChan)
+		l.placeOnNotificationChan(l.NodeNotificationChan, Notification{
+			EventType:  event.Type,
+			ID:         nm.ID,
+			Parameters: paramsEncoded,
+			TimeNano:   event.TimeNano,
+			Done:       doneChan,
+			Node:       &nm,
+		})
 	}()
 
 	for {
@@ -376,11 +946,17 @@ func (l SwarmListener) NotifyServices(useCache bool) {
 		// Send directly to notification chan, skipping the cache
 		go func() {
 			for _, s := range services {
-				ssm := MinifySwarmService(s, l.IgnoreKey, l.IncludeKey)
+				ssm := minifySwarmService(s, l.IgnoreKey, l.IncludeKey, l.IncludeEndpointInfo)
 
 				params := GetSwarmServiceMiniCreateParameters(ssm)
 				paramsEncoded := ConvertMapStringStringToURLValues(params).Encode()
-				l.placeOnNotificationChan(l.SSNotificationChan, EventTypeCreate, nowTimeNano, ssm.ID, paramsEncoded, nil)
+				l.placeOnNotificationChan(l.SSNotificationChan, Notification{
+					EventType:  EventTypeCreate,
+					ID:         ssm.ID,
+					Parameters: paramsEncoded,
+					TimeNano:   nowTimeNano,
+					Service:    &ssm,
+				})
 			}
 		}()
 	}
@@ -409,19 +985,88 @@ func (l SwarmListener) NotifyNodes(useCache bool) {
 				nm := MinifyNode(n)
 				params := GetNodeMiniCreateParameters(nm)
 				paramsEncoded := ConvertMapStringStringToURLValues(params).Encode()
-				l.placeOnNotificationChan(l.NodeNotificationChan, EventTypeCreate, nowTimeNano, nm.ID, paramsEncoded, nil)
+				l.placeOnNotificationChan(l.NodeNotificationChan, Notification{
+					EventType:  EventTypeCreate,
+					ID:         nm.ID,
+					Parameters: paramsEncoded,
+					TimeNano:   nowTimeNano,
+					Node:       &nm,
+				})
 			}
 		}()
 	}
 }
 
-func (l SwarmListener) placeOnNotificationChan(notiChan chan<- Notification, eventType EventType, timeNano int64, ID string, parameters string, doneChan chan struct{}) {
-	notiChan <- Notification{
-		EventType:  eventType,
-		ID:         ID,
-		Parameters: parameters,
-		TimeNano:   timeNano,
-		Done:       doneChan,
+// ReconcileServices places the services that drifted from the cache on
+// queue. Services that are no longer running are placed on queue as remove
+// events
+func (l SwarmListener) ReconcileServices() {
+	if l.SSEventChan == nil {
+		return
+	}
+	events := l.reconciler().ReconcileServices(context.Background())
+	go func() {
+		for _, event := range events {
+			l.SSEventChan <- event
+		}
+	}()
+}
+
+// ReconcileNodes places the nodes that drifted from the cache on queue
+// Nodes that left the swarm are placed on queue as remove events
+func (l SwarmListener) ReconcileNodes() {
+	if l.NodeEventChan == nil {
+		return
+	}
+	events := l.reconciler().ReconcileNodes(context.Background())
+	go func() {
+		for _, event := range events {
+			l.NodeEventChan <- event
+		}
+	}()
+}
+
+// reconciler returns `Reconciler`, or a reconciler of the clients and
+// caches of the listener when periodic reconciliation is disabled
+func (l SwarmListener) reconciler() *Reconciler {
+	if l.Reconciler != nil {
+		return l.Reconciler
+	}
+	return l.newReconciler(0)
+}
+
+// newReconciler creates a `Reconciler` of the clients and caches of the
+// listener that reconciles every `interval`
+func (l SwarmListener) newReconciler(interval time.Duration) *Reconciler {
+	r := NewReconciler(l.SSClient, l.SSCache, l.NodeClient, l.NodeCache, interval, l.Log)
+	r.IncludeNodeInfo = l.IncludeNodeInfo
+	r.IncludeEndpointInfo = l.IncludeEndpointInfo
+	r.IgnoreKey = l.IgnoreKey
+	r.IncludeKey = l.IncludeKey
+	return r
+}
+
+func (l SwarmListener) placeOnNotificationChan(notiChan chan<- Notification, n Notification) {
+	notiChan <- n
+}
+
+// refreshesServicesOnNodeEvents returns true when node events refresh the
+// `NodeInfo` of cached services
+func (l SwarmListener) refreshesServicesOnNodeEvents() bool {
+	return l.IncludeNodeInfo && l.SSEventChan != nil
+}
+
+// refreshServicesOnNode re-inspects cached services with tasks on node
+// `nodeID`. The services are only notified when they changed, for example
+// when their `NodeInfo` changed after their tasks moved to other nodes
+func (l SwarmListener) refreshServicesOnNode(nodeID string, timeNano int64) {
+	if !l.refreshesServicesOnNodeEvents() {
+		return
+	}
+	for _, ssm := range l.SSCache.GetAll() {
+		if ssm.NodeInfo.HasNodeID(nodeID) {
+			l.placeOnEventChan(l.SSEventChan, EventTypeCreate, ssm.ID, timeNano)
+		}
 	}
 }
 
@@ -441,7 +1086,7 @@ func (l SwarmListener) GetServicesParameters(ctx context.Context) ([]map[string]
 	}
 	params := []map[string]string{}
 	for _, s := range services {
-		ssm := MinifySwarmService(s, l.IgnoreKey, l.IncludeKey)
+		ssm := minifySwarmService(s, l.IgnoreKey, l.IncludeKey, l.IncludeEndpointInfo)
 		newParams := GetSwarmServiceMiniCreateParameters(ssm)
 		if len(newParams) > 0 {
 			params = append(params, newParams)
@@ -466,3 +1111,26 @@ func (l SwarmListener) GetNodesParameters(ctx context.Context) ([]map[string]str
 	}
 	return params, nil
 }
+
+// Subscribe calls `fn` with every notification that matches `filter`
+// Subscribers receive the same create and remove notifications as HTTP
+// endpoints. The returned function removes the subscription
+// Subscriptions must be added before `Run`, which only produces the
+// notifications that have listeners
+func (l SwarmListener) Subscribe(filter NotificationFilter, fn func(Notification)) func() {
+	return l.NotifyDistributor.Subscribe(filter, fn)
+}
+
+// GetPrometheusTargets returns Prometheus target groups for cached services
+func (l SwarmListener) GetPrometheusTargets() []PrometheusTargetGroup {
+	return GetPrometheusTargetGroups(l.SSCache.GetAll())
+}
+
+// GetAuditEntries returns the last `limit` audit entries that match `filter`
+// `ErrAuditLogDisabled` is returned when the audit log is not enabled
+func (l SwarmListener) GetAuditEntries(limit int, filter AuditFilter) ([]AuditEntry, error) {
+	if l.Audit == nil {
+		return nil, ErrAuditLogDisabled
+	}
+	return l.Audit.Tail(limit, filter)
+}
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -76,7 +77,8 @@ func (s *SwarmListenerTestSuite) Test_Run_ServicesChannel() {
 
 	s.SSListenerMock.On("ListenForServiceEvents", mock.AnythingOfType("chan<- service.Event"))
 	s.SSClientMock.On("SwarmServiceInspect", mock.AnythingOfType("*context.cancelCtx"), "serviceID1", false).Return(&ss1, nil)
-	s.SSCacheMock.On("InsertAndCheck", ss1m).Return(true).
+	s.SSCacheMock.On("Get", "serviceID1").Return(SwarmServiceMini{}, false).
+		On("InsertAndCheck", ss1m).Return(true).
 		On("Get", "serviceID2").Return(ss2m, true).
 		On("Delete", "serviceID2").
 		On("Len").Return(2)
@@ -144,6 +146,712 @@ func (s *SwarmListenerTestSuite) Test_Run_ServicesChannel() {
 
 }
 
+func (s *SwarmListenerTestSuite) Test_Run_TaskWatcher() {
+	s.SwarmListener.IncludeNodeInfo = false
+	s.SwarmListener.CacheServices = true
+	s.SwarmListener.TaskWatcher = NewTaskWatcher(
+		new(taskInspectorMock), s.SSCacheMock, time.Hour, s.Logger)
+
+	s.SSListenerMock.On("ListenForServiceEvents", mock.AnythingOfType("chan<- service.Event"))
+	s.NotifyDistributorMock.
+		On("HasServiceListeners").Return(false).
+		On("HasNodeListeners").Return(false).
+		On("HasListeners", NotifyTypeTask).Return(true).
+		On("Run", mock.AnythingOfType("<-chan service.Notification"), mock.AnythingOfType("<-chan service.Notification")).
+		On("RunType", NotifyTypeTask, mock.AnythingOfType("<-chan service.Notification"))
+	s.SwarmListener.Run()
+
+	s.NotNil(s.SwarmListener.TaskNotificationChan)
+	s.NotNil(s.SwarmListener.SSEventChan)
+	s.SSListenerMock.AssertExpectations(s.T())
+	s.NotifyDistributorMock.AssertExpectations(s.T())
+}
+
+func (s *SwarmListenerTestSuite) Test_Run_TaskWatcherWithoutTaskListeners() {
+	s.SwarmListener.IncludeNodeInfo = false
+	s.SwarmListener.CacheServices = true
+	s.SwarmListener.TaskWatcher = NewTaskWatcher(
+		new(taskInspectorMock), s.SSCacheMock, time.Hour, s.Logger)
+
+	s.SSListenerMock.On("ListenForServiceEvents", mock.AnythingOfType("chan<- service.Event"))
+	s.NotifyDistributorMock.
+		On("HasServiceListeners").Return(false).
+		On("HasNodeListeners").Return(false).
+		On("HasListeners", NotifyTypeTask).Return(false).
+		On("Run", mock.AnythingOfType("<-chan service.Notification"), mock.AnythingOfType("<-chan service.Notification")).
+		On("RunType", NotifyTypeTask, (<-chan Notification)(nil))
+	s.SwarmListener.Run()
+
+	s.Nil(s.SwarmListener.TaskNotificationChan)
+	s.NotifyDistributorMock.AssertExpectations(s.T())
+}
+
+func (s *SwarmListenerTestSuite) Test_Run_NetworkChannel() {
+	s.SwarmListener.IncludeNodeInfo = false
+	listenerMock := new(networkListeningMock)
+	clientMock := new(networkInspectorMock)
+	cacheMock := new(networkCacherMock)
+	s.SwarmListener.NetworkListener = listenerMock
+	s.SwarmListener.NetworkClient = clientMock
+	s.SwarmListener.NetworkCache = cacheMock
+
+	n1 := types.NetworkResource{ID: "networkID1", Name: "proxy", Driver: "overlay", Scope: "swarm"}
+	n1m := NetworkMini{ID: "networkID1", Name: "proxy", Driver: "overlay", Scope: "swarm",
+		Labels: map[string]string{}}
+	n2m := NetworkMini{ID: "networkID2", Name: "go-demo_default", Driver: "overlay", Scope: "swarm",
+		Labels: map[string]string{}}
+
+	listenerMock.On("ListenForNetworkEvents", mock.AnythingOfType("chan<- service.Event"))
+	clientMock.On("NetworkInspect", mock.AnythingOfType("*context.cancelCtx"), "networkID1").Return(n1, nil)
+	cacheMock.On("InsertAndCheck", n1m).Return(true).
+		On("Get", "networkID2").Return(n2m, true).
+		On("Delete", "networkID2")
+	s.NotifyDistributorMock.
+		On("HasServiceListeners").Return(false).
+		On("HasNodeListeners").Return(false).
+		On("HasListeners", NotifyTypeNetwork).Return(true).
+		On("Run", mock.Anything, mock.Anything).
+		On("RunType", NotifyTypeNetwork, mock.AnythingOfType("<-chan service.Notification"))
+
+	s.SwarmListener.Run()
+
+	go func() {
+		s.SwarmListener.NetworkEventChan <- Event{
+			ID:       "networkID1",
+			Type:     EventTypeCreate,
+			TimeNano: int64(1),
+		}
+	}()
+	go func() {
+		s.SwarmListener.NetworkEventChan <- Event{
+			ID:       "networkID2",
+			Type:     EventTypeRemove,
+			TimeNano: int64(2),
+		}
+	}()
+
+	notificationMap := map[string]Notification{}
+	timeout := time.NewTimer(time.Second * 5).C
+
+L:
+	for {
+		select {
+		case n := <-s.SwarmListener.NetworkNotificationChan:
+			notificationMap[n.ID] = n
+			if len(notificationMap) == 2 {
+				break L
+			}
+		case <-timeout:
+			s.Fail("Timeout")
+			return
+		}
+	}
+
+	s.Equal(EventTypeCreate, notificationMap["networkID1"].EventType)
+	s.Equal("driver=overlay&id=networkID1&labels=%7B%7D&name=proxy&scope=swarm",
+		notificationMap["networkID1"].Parameters)
+	s.Equal(EventTypeRemove, notificationMap["networkID2"].EventType)
+	s.Equal("driver=overlay&id=networkID2&name=go-demo_default&scope=swarm",
+		notificationMap["networkID2"].Parameters)
+	listenerMock.AssertExpectations(s.T())
+	clientMock.AssertExpectations(s.T())
+	cacheMock.AssertExpectations(s.T())
+	s.NotifyDistributorMock.AssertExpectations(s.T())
+}
+
+func (s *SwarmListenerTestSuite) Test_Run_NetworkChannel_IgnoresNonOverlayNetworks() {
+	s.SwarmListener.IncludeNodeInfo = false
+	clientMock := new(networkInspectorMock)
+	cacheMock := new(networkCacherMock)
+	s.SwarmListener.NetworkClient = clientMock
+	s.SwarmListener.NetworkCache = cacheMock
+
+	clientMock.On("NetworkInspect", mock.AnythingOfType("*context.cancelCtx"), "networkID1").
+		Return(types.NetworkResource{ID: "networkID1", Driver: "bridge"}, nil)
+
+	done := make(chan struct{})
+	event := Event{ID: "networkID1", Type: EventTypeCreate, TimeNano: int64(1)}
+	go func() {
+		s.SwarmListener.processNetworkEventCreate(event)
+		close(done)
+	}()
+	// The event waits for its notification until it is canceled
+	time.Sleep(10 * time.Millisecond)
+	s.SwarmListener.NetworkCreateRemoveCancelManager.RemoveEvent(event)
+
+	select {
+	case <-done:
+	case <-time.NewTimer(time.Second * 5).C:
+		s.Fail("Timeout")
+	}
+	clientMock.AssertExpectations(s.T())
+	cacheMock.AssertNotCalled(s.T(), "InsertAndCheck", mock.Anything)
+}
+
+func (s *SwarmListenerTestSuite) Test_Run_NetworkChannelWithoutNetworkListeners() {
+	s.SwarmListener.IncludeNodeInfo = false
+	listenerMock := new(networkListeningMock)
+	s.SwarmListener.NetworkListener = listenerMock
+
+	s.NotifyDistributorMock.
+		On("HasServiceListeners").Return(false).
+		On("HasNodeListeners").Return(false).
+		On("HasListeners", NotifyTypeNetwork).Return(false).
+		On("Run", mock.Anything, mock.Anything)
+
+	s.SwarmListener.Run()
+
+	s.Nil(s.SwarmListener.NetworkEventChan)
+	s.Nil(s.SwarmListener.NetworkNotificationChan)
+	listenerMock.AssertNotCalled(s.T(), "ListenForNetworkEvents", mock.Anything)
+}
+
+func (s *SwarmListenerTestSuite) Test_Run_ConfigWatch() {
+	s.SwarmListener.IncludeNodeInfo = false
+	listenerMock := new(swarmObjectListeningMock)
+	clientMock := new(swarmObjectInspectorMock)
+	cache := NewSwarmObjectCache()
+	s.SwarmListener.ConfigWatch = NewSwarmObjectWatch(
+		NotifyTypeConfig, listenerMock, clientMock, cache)
+
+	om1 := SwarmObjectMini{ID: "configID1", Kind: NotifyTypeConfig, Name: "config1",
+		Labels: map[string]string{}, VersionIndex: 3, Services: []string{"proxy"}}
+	om2 := SwarmObjectMini{ID: "configID2", Kind: NotifyTypeConfig, Name: "config2",
+		Labels: map[string]string{}, Services: []string{"api", "proxy"}}
+	cache.InsertAndCheck(om2)
+
+	listenerMock.On("ListenForSwarmObjectEvents", mock.AnythingOfType("chan<- service.Event"))
+	clientMock.On("SwarmObjectInspect", mock.AnythingOfType("*context.cancelCtx"), NotifyTypeConfig, "configID1").
+		Return(om1, nil)
+	s.NotifyDistributorMock.
+		On("HasServiceListeners").Return(false).
+		On("HasNodeListeners").Return(false).
+		On("HasListeners", NotifyTypeConfig).Return(true).
+		On("Run", mock.Anything, mock.Anything).
+		On("RunType", NotifyTypeConfig, mock.AnythingOfType("<-chan service.Notification"))
+
+	s.SwarmListener.Run()
+	watch := s.SwarmListener.ConfigWatch
+
+	go func() {
+		watch.EventChan <- Event{ID: "configID1", Type: EventTypeCreate, TimeNano: int64(1)}
+	}()
+	go func() {
+		watch.EventChan <- Event{ID: "configID2", Type: EventTypeRemove, TimeNano: int64(2)}
+	}()
+
+	notificationMap := map[string]Notification{}
+	timeout := time.NewTimer(time.Second * 5).C
+
+L:
+	for {
+		select {
+		case n := <-watch.NotificationChan:
+			notificationMap[n.ID] = n
+			if len(notificationMap) == 2 {
+				break L
+			}
+		case <-timeout:
+			s.Fail("Timeout")
+			return
+		}
+	}
+
+	s.Equal(EventTypeCreate, notificationMap["configID1"].EventType)
+	s.Equal("id=configID1&name=config1&services=proxy&versionIndex=3",
+		notificationMap["configID1"].Parameters)
+	s.Equal(EventTypeRemove, notificationMap["configID2"].EventType)
+	s.Equal("id=configID2&name=config2&services=api%2Cproxy",
+		notificationMap["configID2"].Parameters)
+
+	_, ok := cache.Get("configID2")
+	s.False(ok)
+	listenerMock.AssertExpectations(s.T())
+	clientMock.AssertExpectations(s.T())
+	s.NotifyDistributorMock.AssertExpectations(s.T())
+}
+
+func (s *SwarmListenerTestSuite) Test_Run_SecretWatchWithoutSecretListeners() {
+	s.SwarmListener.IncludeNodeInfo = false
+	listenerMock := new(swarmObjectListeningMock)
+	s.SwarmListener.SecretWatch = NewSwarmObjectWatch(
+		NotifyTypeSecret, listenerMock, new(swarmObjectInspectorMock), NewSwarmObjectCache())
+
+	s.NotifyDistributorMock.
+		On("HasServiceListeners").Return(false).
+		On("HasNodeListeners").Return(false).
+		On("HasListeners", NotifyTypeSecret).Return(false).
+		On("Run", mock.Anything, mock.Anything)
+
+	s.SwarmListener.Run()
+
+	s.Nil(s.SwarmListener.SecretWatch.EventChan)
+	s.Nil(s.SwarmListener.SecretWatch.NotificationChan)
+	listenerMock.AssertNotCalled(s.T(), "ListenForSwarmObjectEvents", mock.Anything)
+	s.NotifyDistributorMock.AssertNotCalled(s.T(), "RunType", NotifyTypeSecret, mock.Anything)
+}
+
+func (s *SwarmListenerTestSuite) Test_Run_UpdateTracker() {
+	s.SwarmListener.IncludeNodeInfo = false
+	s.SwarmListener.UpdateTracker = NewUpdateTracker()
+
+	s.SSListenerMock.On("ListenForServiceEvents", mock.AnythingOfType("chan<- service.Event"))
+	s.NotifyDistributorMock.
+		On("HasServiceListeners").Return(true).
+		On("HasNodeListeners").Return(false).
+		On("HasListeners", NotifyTypeUpdate).Return(true).
+		On("Run", mock.Anything, mock.Anything).
+		On("RunType", NotifyTypeUpdate, mock.AnythingOfType("<-chan service.Notification"))
+	s.SwarmListener.Run()
+	s.Require().NotNil(s.SwarmListener.UpdateNotificationChan)
+
+	started := time.Now()
+	go func() {
+		s.SwarmListener.UpdateTracker.Observe(newUpdatingService("", time.Time{}, nil, ""))
+		s.SwarmListener.UpdateTracker.Observe(
+			newUpdatingService(swarm.UpdateStatePaused, started, nil, "update paused"))
+	}()
+
+	select {
+	case n := <-s.SwarmListener.UpdateNotificationChan:
+		s.Equal(EventTypeUpdatePaused, n.EventType)
+		s.Equal("serviceID1", n.ID)
+	case <-time.NewTimer(time.Second * 5).C:
+		s.Fail("Timeout")
+	}
+	s.NotifyDistributorMock.AssertExpectations(s.T())
+}
+
+func (s *SwarmListenerTestSuite) Test_Run_UpdateTrackerWithoutUpdateListeners() {
+	s.SwarmListener.IncludeNodeInfo = false
+	s.SwarmListener.UpdateTracker = NewUpdateTracker()
+
+	s.SSListenerMock.On("ListenForServiceEvents", mock.AnythingOfType("chan<- service.Event"))
+	s.NotifyDistributorMock.
+		On("HasServiceListeners").Return(true).
+		On("HasNodeListeners").Return(false).
+		On("HasListeners", NotifyTypeUpdate).Return(false).
+		On("Run", mock.Anything, mock.Anything)
+	s.SwarmListener.Run()
+
+	s.Nil(s.SwarmListener.UpdateNotificationChan)
+	s.NotifyDistributorMock.AssertNotCalled(s.T(), "RunType", NotifyTypeUpdate, mock.Anything)
+}
+
+func (s *SwarmListenerTestSuite) Test_Run_StackTracker() {
+	s.SwarmListener.IncludeNodeInfo = false
+	s.SwarmListener.SSCache = NewSwarmServiceCache()
+	s.SwarmListener.StackTracker = NewStackTracker()
+	ss1 := SwarmService{newStackService("serviceID1", "demo_main", "demo", 10), nil}
+
+	s.SSListenerMock.On("ListenForServiceEvents", mock.AnythingOfType("chan<- service.Event"))
+	s.SSClientMock.On("SwarmServiceInspect", mock.AnythingOfType("*context.cancelCtx"), "serviceID1", false).
+		Return(&ss1, nil)
+	s.NotifyDistributorMock.
+		On("HasServiceListeners").Return(false).
+		On("HasNodeListeners").Return(false).
+		On("HasListeners", NotifyTypeStack).Return(true).
+		On("Run", mock.Anything, mock.Anything).
+		On("RunType", NotifyTypeStack, mock.AnythingOfType("<-chan service.Notification"))
+	s.SwarmListener.CacheServices = true
+	s.SwarmListener.Run()
+	s.Require().NotNil(s.SwarmListener.StackNotificationChan)
+
+	go func() {
+		s.SwarmListener.SSEventChan <- Event{ID: "serviceID1", Type: EventTypeCreate, TimeNano: int64(1)}
+	}()
+	ssNotiChan := s.SwarmListener.SSNotificationChan
+	go func() {
+		for range ssNotiChan {
+		}
+	}()
+
+	select {
+	case n := <-s.SwarmListener.StackNotificationChan:
+		s.Equal(EventTypeStackDeployed, n.EventType)
+		s.Equal("demo", n.ID)
+	case <-time.NewTimer(time.Second * 5).C:
+		s.FailNow("Timeout")
+	}
+
+	go func() {
+		s.SwarmListener.SSEventChan <- Event{ID: "serviceID1", Type: EventTypeRemove, TimeNano: int64(2)}
+	}()
+
+	select {
+	case n := <-s.SwarmListener.StackNotificationChan:
+		s.Equal(EventTypeStackRemoved, n.EventType)
+		s.Equal("demo", n.ID)
+	case <-time.NewTimer(time.Second * 5).C:
+		s.Fail("Timeout")
+	}
+	s.NotifyDistributorMock.AssertExpectations(s.T())
+}
+
+func (s *SwarmListenerTestSuite) Test_Run_StackTracker_OverlappingUpdates() {
+	s.SwarmListener.IncludeNodeInfo = false
+	s.SwarmListener.SSCache = NewSwarmServiceCache()
+	s.SwarmListener.StackTracker = NewStackTracker()
+	tracker := s.SwarmListener.StackTracker
+	ss1 := SwarmService{newStackService("serviceID1", "demo_main", "demo", 11), nil}
+	ss2 := SwarmService{newStackService("serviceID2", "demo_db", "demo", 10), nil}
+	firstStarted := make(chan struct{})
+	secondStarted := make(chan struct{})
+	release := make(chan struct{})
+
+	s.SSListenerMock.On("ListenForServiceEvents", mock.AnythingOfType("chan<- service.Event"))
+	// The first update of serviceID1 is canceled by the second one
+	s.SSClientMock.On("SwarmServiceInspect", mock.Anything, "serviceID1", false).
+		Return((*SwarmService)(nil), context.Canceled).
+		Run(func(args mock.Arguments) {
+			tracker.Observe(newStackService("serviceID1", "demo_main", "demo", 10))
+			close(firstStarted)
+			<-args.Get(0).(context.Context).Done()
+		}).Once()
+	s.SSClientMock.On("SwarmServiceInspect", mock.Anything, "serviceID1", false).
+		Return(&ss1, nil).
+		Run(func(args mock.Arguments) {
+			tracker.Observe(ss1.Service)
+			close(secondStarted)
+			<-release
+		}).Once()
+	s.SSClientMock.On("SwarmServiceInspect", mock.Anything, "serviceID2", false).
+		Return(&ss2, nil)
+	s.NotifyDistributorMock.
+		On("HasServiceListeners").Return(false).
+		On("HasNodeListeners").Return(false).
+		On("HasListeners", NotifyTypeStack).Return(true).
+		On("Run", mock.Anything, mock.Anything).
+		On("RunType", NotifyTypeStack, mock.AnythingOfType("<-chan service.Notification"))
+	s.SwarmListener.CacheServices = true
+	s.SwarmListener.Run()
+	s.Require().NotNil(s.SwarmListener.StackNotificationChan)
+
+	ssEventChan := s.SwarmListener.SSEventChan
+	ssNotiChan := s.SwarmListener.SSNotificationChan
+	go func() {
+		for range ssNotiChan {
+		}
+	}()
+
+	ssEventChan <- Event{ID: "serviceID1", Type: EventTypeCreate, TimeNano: int64(1)}
+	<-firstStarted
+	ssEventChan <- Event{ID: "serviceID1", Type: EventTypeCreate, TimeNano: int64(2)}
+	<-secondStarted
+	// Wait for the canceled inspection to return
+	time.Sleep(100 * time.Millisecond)
+	ssEventChan <- Event{ID: "serviceID2", Type: EventTypeCreate, TimeNano: int64(3)}
+
+	// serviceID1 is still converging, so the stack is not deployed
+	select {
+	case n := <-s.SwarmListener.StackNotificationChan:
+		s.FailNow("Unexpected notification", "%v", n)
+	case <-time.NewTimer(200 * time.Millisecond).C:
+	}
+
+	close(release)
+	select {
+	case n := <-s.SwarmListener.StackNotificationChan:
+		s.Equal(EventTypeStackDeployed, n.EventType)
+		s.Equal("demo", n.ID)
+	case <-time.NewTimer(time.Second * 5).C:
+		s.FailNow("Timeout")
+	}
+}
+
+func (s *SwarmListenerTestSuite) Test_Run_StackTrackerWithoutStackListeners() {
+	s.SwarmListener.IncludeNodeInfo = false
+	s.SwarmListener.StackTracker = NewStackTracker()
+
+	s.SSListenerMock.On("ListenForServiceEvents", mock.AnythingOfType("chan<- service.Event"))
+	s.NotifyDistributorMock.
+		On("HasServiceListeners").Return(true).
+		On("HasNodeListeners").Return(false).
+		On("HasListeners", NotifyTypeStack).Return(false).
+		On("Run", mock.Anything, mock.Anything)
+	s.SwarmListener.Run()
+
+	s.Nil(s.SwarmListener.StackNotificationChan)
+	s.NotifyDistributorMock.AssertNotCalled(s.T(), "RunType", NotifyTypeStack, mock.Anything)
+}
+
+func (s *SwarmListenerTestSuite) Test_Run_SpecTracker() {
+	s.SwarmListener.IncludeNodeInfo = false
+	s.SwarmListener.SSCache = NewSwarmServiceCache()
+	s.SwarmListener.SpecTracker = NewSpecTracker("com.df.notify", "")
+
+	s.SSListenerMock.On("ListenForServiceEvents", mock.AnythingOfType("chan<- service.Event"))
+	s.NotifyDistributorMock.
+		On("HasServiceListeners").Return(false).
+		On("HasNodeListeners").Return(false).
+		On("HasListeners", NotifyTypeSpec).Return(true).
+		On("Run", mock.Anything, mock.Anything).
+		On("RunType", NotifyTypeSpec, mock.AnythingOfType("<-chan service.Notification"))
+	s.SwarmListener.CacheServices = true
+	s.SwarmListener.Run()
+	s.Require().NotNil(s.SwarmListener.SpecNotificationChan)
+
+	// Services are observed while they are inspected, before they converge
+	go s.SwarmListener.SpecTracker.Observe(newSpecService("serviceID1", "demo", "/demo", 2))
+
+	select {
+	case n := <-s.SwarmListener.SpecNotificationChan:
+		s.Equal(EventTypeCreate, n.EventType)
+		s.Equal("serviceID1", n.ID)
+	case <-time.NewTimer(time.Second * 5).C:
+		s.FailNow("Timeout")
+	}
+
+	// The service is removed before it converged and was cached
+	go func() {
+		s.SwarmListener.SSEventChan <- Event{ID: "serviceID1", Type: EventTypeRemove, TimeNano: int64(2)}
+	}()
+
+	select {
+	case n := <-s.SwarmListener.SpecNotificationChan:
+		s.Equal(EventTypeRemove, n.EventType)
+		s.Equal("serviceID1", n.ID)
+	case <-time.NewTimer(time.Second * 5).C:
+		s.Fail("Timeout")
+	}
+	s.NotifyDistributorMock.AssertExpectations(s.T())
+}
+
+func (s *SwarmListenerTestSuite) Test_Run_SpecTrackerWithoutSpecListeners() {
+	s.SwarmListener.IncludeNodeInfo = false
+	s.SwarmListener.SpecTracker = NewSpecTracker("com.df.notify", "")
+
+	s.SSListenerMock.On("ListenForServiceEvents", mock.AnythingOfType("chan<- service.Event"))
+	s.NotifyDistributorMock.
+		On("HasServiceListeners").Return(true).
+		On("HasNodeListeners").Return(false).
+		On("HasListeners", NotifyTypeSpec).Return(false).
+		On("Run", mock.Anything, mock.Anything)
+	s.SwarmListener.Run()
+
+	s.Nil(s.SwarmListener.SpecNotificationChan)
+	s.NotifyDistributorMock.AssertNotCalled(s.T(), "RunType", NotifyTypeSpec, mock.Anything)
+}
+
+func (s *SwarmListenerTestSuite) Test_Run_ServicesChannel_ConvergenceFailure() {
+	s.SwarmListener.IncludeNodeInfo = false
+	convergenceErr := &ConvergenceError{
+		ServiceID:   "serviceID1",
+		ServiceName: "serviceName1",
+		Timeout:     time.Minute,
+		TaskErrors:  []string{"No such image: demo:bad"},
+	}
+
+	s.SSListenerMock.On("ListenForServiceEvents", mock.AnythingOfType("chan<- service.Event"))
+	s.SSClientMock.On("SwarmServiceInspect", mock.AnythingOfType("*context.cancelCtx"), "serviceID1", false).
+		Return((*SwarmService)(nil), convergenceErr)
+	s.NotifyDistributorMock.
+		On("HasServiceListeners").Return(true).
+		On("HasNodeListeners").Return(false).
+		On("HasListeners", NotifyTypeConvergence).Return(true).
+		On("Run", mock.Anything, mock.Anything).
+		On("RunType", NotifyTypeConvergence, mock.AnythingOfType("<-chan service.Notification"))
+	s.SwarmListener.ConvergenceNotificationChan = make(chan Notification)
+	s.SwarmListener.Run()
+	s.Require().NotNil(s.SwarmListener.ConvergenceNotificationChan)
+
+	go func() {
+		s.SwarmListener.SSEventChan <- Event{
+			ID:       "serviceID1",
+			Type:     EventTypeCreate,
+			TimeNano: int64(1),
+		}
+	}()
+
+	select {
+	case n := <-s.SwarmListener.ConvergenceNotificationChan:
+		s.Equal(EventTypeConvergenceFailed, n.EventType)
+		s.Equal("serviceID1", n.ID)
+		s.Equal(int64(1), n.TimeNano)
+		s.Contains(n.Parameters, "serviceName=serviceName1")
+	case <-time.NewTimer(time.Second * 5).C:
+		s.Fail("Timeout")
+	}
+	s.Contains(s.LogBytes.String(), "did not converge within 1m0s")
+	s.SSClientMock.AssertExpectations(s.T())
+	s.NotifyDistributorMock.AssertExpectations(s.T())
+}
+
+func (s *SwarmListenerTestSuite) Test_Run_ServicesChannel_ScaleOnly() {
+	s.SwarmListener.IncludeNodeInfo = false
+	s.SwarmListener.SSCache = NewSwarmServiceCache()
+	replicas := uint64(3)
+	ss1 := SwarmService{swarm.Service{ID: "serviceID1",
+		Spec: swarm.ServiceSpec{
+			Annotations: swarm.Annotations{Name: "serviceName1"},
+			Mode: swarm.ServiceMode{
+				Replicated: &swarm.ReplicatedService{Replicas: &replicas},
+			},
+		}}, nil}
+	s.SwarmListener.SSCache.InsertAndCheck(SwarmServiceMini{
+		ID: "serviceID1", Name: "serviceName1", Labels: map[string]string{}, Replicas: 2})
+
+	s.SSListenerMock.On("ListenForServiceEvents", mock.AnythingOfType("chan<- service.Event"))
+	s.SSClientMock.On("SwarmServiceInspect", mock.AnythingOfType("*context.cancelCtx"), "serviceID1", false).Return(&ss1, nil)
+	s.NotifyDistributorMock.
+		On("HasServiceListeners").Return(true).
+		On("HasNodeListeners").Return(false).
+		On("Run", mock.Anything, mock.Anything)
+	s.SwarmListener.Run()
+
+	go func() {
+		s.SwarmListener.SSEventChan <- Event{
+			ID:       "serviceID1",
+			Type:     EventTypeCreate,
+			TimeNano: int64(1),
+		}
+	}()
+
+	select {
+	case n := <-s.SwarmListener.SSNotificationChan:
+		s.Equal(EventTypeCreate, n.EventType)
+		s.Equal("serviceID1", n.ID)
+		s.Require().NotNil(n.Diff)
+		s.Equal(uint64(2), n.Diff.PreviousReplicas)
+		s.Equal(uint64(3), n.Diff.Replicas)
+		s.Contains(n.Parameters, "swarmListener.event=service-scaled")
+		s.Contains(n.Parameters, "replicas=3")
+	case <-time.NewTimer(time.Second * 5).C:
+		s.Fail("Timeout")
+	}
+	s.SSClientMock.AssertExpectations(s.T())
+}
+
+func (s *SwarmListenerTestSuite) Test_Run_ServicesChannel_LabelsDiff() {
+	s.SwarmListener.IncludeNodeInfo = false
+	s.SwarmListener.SSCache = NewSwarmServiceCache()
+	ss1 := SwarmService{swarm.Service{ID: "serviceID1",
+		Spec: swarm.ServiceSpec{
+			Annotations: swarm.Annotations{
+				Name:   "serviceName1",
+				Labels: map[string]string{"com.df.notify": "true", "com.df.port": "8080"},
+			},
+		}}, nil}
+	s.SwarmListener.SSCache.InsertAndCheck(SwarmServiceMini{
+		ID: "serviceID1", Name: "serviceName1",
+		Labels: map[string]string{"com.df.notify": "true", "com.df.port": "80"}})
+
+	s.SSListenerMock.On("ListenForServiceEvents", mock.AnythingOfType("chan<- service.Event"))
+	s.SSClientMock.On("SwarmServiceInspect", mock.AnythingOfType("*context.cancelCtx"), "serviceID1", false).Return(&ss1, nil)
+	s.NotifyDistributorMock.
+		On("HasServiceListeners").Return(true).
+		On("HasNodeListeners").Return(false).
+		On("Run", mock.Anything, mock.Anything)
+	s.SwarmListener.Run()
+
+	go func() {
+		s.SwarmListener.SSEventChan <- Event{
+			ID:       "serviceID1",
+			Type:     EventTypeCreate,
+			TimeNano: int64(1),
+		}
+	}()
+
+	select {
+	case n := <-s.SwarmListener.SSNotificationChan:
+		s.Equal(EventTypeCreate, n.EventType)
+		s.Require().NotNil(n.Diff)
+		s.Equal(map[string]LabelChange{
+			"com.df.port": {Previous: "80", Current: "8080"},
+		}, n.Diff.LabelsChanged)
+		s.NotContains(n.Parameters, "swarmListener.event=")
+		s.Contains(n.Parameters, "swarmListener.diff=")
+	case <-time.NewTimer(time.Second * 5).C:
+		s.Fail("Timeout")
+	}
+	s.SSClientMock.AssertExpectations(s.T())
+}
+
+func (s *SwarmListenerTestSuite) Test_Run_ServicesChannel_FullRemoveParameters() {
+	s.SwarmListener.IncludeNodeInfo = false
+	s.SwarmListener.FullRemoveParameters = true
+	s.SwarmListener.SSCache = NewSwarmServiceCache()
+	s.SwarmListener.SSCache.InsertAndCheck(SwarmServiceMini{
+		ID: "serviceID1", Name: "serviceName1", Replicas: 2,
+		Labels: map[string]string{"com.df.notify": "true", "com.df.servicePath": "/demo"}})
+
+	s.SSListenerMock.On("ListenForServiceEvents", mock.AnythingOfType("chan<- service.Event"))
+	s.NotifyDistributorMock.
+		On("HasServiceListeners").Return(true).
+		On("HasNodeListeners").Return(false).
+		On("Run", mock.Anything, mock.Anything)
+	s.SwarmListener.Run()
+
+	go func() {
+		s.SwarmListener.SSEventChan <- Event{
+			ID:       "serviceID1",
+			Type:     EventTypeRemove,
+			TimeNano: int64(1),
+		}
+	}()
+
+	select {
+	case n := <-s.SwarmListener.SSNotificationChan:
+		s.Equal(EventTypeRemove, n.EventType)
+		s.Equal("serviceID1", n.ID)
+		s.Contains(n.Parameters, "swarmListener.event=remove")
+		s.Contains(n.Parameters, "servicePath=%2Fdemo")
+		s.Contains(n.Parameters, "replicas=2")
+	case <-time.NewTimer(time.Second * 5).C:
+		s.Fail("Timeout")
+	}
+	s.Equal(0, s.SwarmListener.SSCache.Len())
+}
+
+func (s *SwarmListenerTestSuite) Test_ReconcileServices() {
+	s.SwarmListener.IncludeNodeInfo = false
+	s.SwarmListener.SSCache = NewSwarmServiceCache()
+	ss1 := SwarmService{swarm.Service{ID: "serviceID1"}, nil}
+	ss3 := SwarmService{swarm.Service{ID: "serviceID3"}, nil}
+	s.SwarmListener.SSCache.InsertAndCheck(SwarmServiceMini{ID: "serviceID1", Name: "serviceName1"})
+	s.SwarmListener.SSCache.InsertAndCheck(SwarmServiceMini{ID: "serviceID2", Name: "serviceName2"})
+
+	s.SSClientMock.On("SwarmServiceList", mock.Anything, false).
+		Return([]SwarmService{ss1, ss3}, nil)
+	s.SwarmListener.ReconcileServices()
+
+	eventMap := map[string]EventType{}
+	for len(eventMap) < 3 {
+		select {
+		case event := <-s.SwarmListener.SSEventChan:
+			eventMap[event.ID] = event.Type
+		case <-time.NewTimer(time.Second * 5).C:
+			s.FailNow("Timeout")
+		}
+	}
+	s.Equal(map[string]EventType{
+		"serviceID1": EventTypeCreate,
+		"serviceID2": EventTypeRemove,
+		"serviceID3": EventTypeCreate,
+	}, eventMap)
+	s.SSClientMock.AssertExpectations(s.T())
+}
+
+func (s *SwarmListenerTestSuite) Test_ReconcileNodes() {
+	n1 := swarm.Node{ID: "nodeID1", Description: swarm.NodeDescription{Hostname: "node1"}}
+	s.SwarmListener.NodeCache = NewNodeCache()
+	s.SwarmListener.NodeCache.InsertAndCheck(MinifyNode(n1))
+	s.SwarmListener.NodeCache.InsertAndCheck(NodeMini{ID: "nodeID2"})
+
+	s.NodeClientMock.On("NodeList", mock.Anything).
+		Return([]swarm.Node{n1}, nil)
+	s.SwarmListener.ReconcileNodes()
+
+	select {
+	case event := <-s.SwarmListener.NodeEventChan:
+		s.Equal("nodeID2", event.ID)
+		s.Equal(EventTypeRemove, event.Type)
+	case <-time.NewTimer(time.Second * 5).C:
+		s.FailNow("Timeout")
+	}
+	s.NodeClientMock.AssertExpectations(s.T())
+}
+
 func (s *SwarmListenerTestSuite) Test_Run_NodeChannel() {
 
 	n1 := swarm.Node{ID: "nodeID1",
@@ -218,6 +926,80 @@ L:
 
 }
 
+func (s *SwarmListenerTestSuite) Test_Run_NodeChannel_RefreshesServicesOnNode() {
+	n1 := swarm.Node{ID: "nodeID1", Description: swarm.NodeDescription{Hostname: "node1"}}
+	n1m := NodeMini{ID: "nodeID1",
+		EngineLabels: map[string]string{},
+		NodeLabels:   map[string]string{},
+		Hostname:     "node1",
+	}
+	nodeInfo1 := NodeIPSet{}
+	nodeInfo1.Add("node1", "10.0.0.1", "nodeID1")
+	nodeInfo2 := NodeIPSet{}
+	nodeInfo2.Add("node2", "10.0.0.2", "nodeID2")
+	ss1m := SwarmServiceMini{ID: "serviceID1", NodeInfo: nodeInfo1}
+	ss2m := SwarmServiceMini{ID: "serviceID2", NodeInfo: nodeInfo2}
+
+	s.SSListenerMock.On("ListenForServiceEvents", mock.AnythingOfType("chan<- service.Event"))
+	s.NodeListeningMock.On("ListenForNodeEvents", mock.AnythingOfType("chan<- service.Event"))
+	s.NodeClientMock.On("NodeInspect", "nodeID1").Return(n1, nil)
+	s.NodeCacheMock.On("InsertAndCheck", n1m).Return(true)
+	s.SSCacheMock.On("GetAll").Return([]SwarmServiceMini{ss1m, ss2m})
+	s.NotifyDistributorMock.
+		On("HasServiceListeners").Return(true).
+		On("HasNodeListeners").Return(false).
+		On("Run", mock.AnythingOfType("<-chan service.Notification"), mock.AnythingOfType("<-chan service.Notification"))
+
+	// Replace the service event channel before it is connected to observe
+	// refreshed services. Service channels are not connected, so the test
+	// is the only receiver of service events
+	ssEventChan := make(chan Event)
+	s.SwarmListener.SSEventChan = ssEventChan
+	s.SwarmListener.connectNodeChannels()
+
+	go func() {
+		s.SwarmListener.NodeEventChan <- Event{
+			ID:       "nodeID1",
+			Type:     EventTypeCreate,
+			TimeNano: int64(2),
+		}
+	}()
+	nodeNotiChan := s.SwarmListener.NodeNotificationChan
+	go func() {
+		for range nodeNotiChan {
+		}
+	}()
+
+	timeout := time.NewTimer(time.Second * 5).C
+	select {
+	case event := <-ssEventChan:
+		s.Equal(Event{ID: "serviceID1", Type: EventTypeCreate, TimeNano: int64(2)}, event)
+	case <-timeout:
+		s.Fail("Timeout")
+		return
+	}
+	select {
+	case event := <-ssEventChan:
+		s.Fail("Unexpected refresh of %s", event.ID)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func (s *SwarmListenerTestSuite) Test_Run_NodeChannel_NoNodeInfo_NoNodeListeners() {
+	s.SwarmListener.IncludeNodeInfo = false
+	s.SwarmListener.CacheServices = true
+
+	s.SSListenerMock.On("ListenForServiceEvents", mock.AnythingOfType("chan<- service.Event"))
+	s.NotifyDistributorMock.
+		On("HasServiceListeners").Return(true).
+		On("HasNodeListeners").Return(false).
+		On("Run", mock.AnythingOfType("<-chan service.Notification"), mock.AnythingOfType("<-chan service.Notification"))
+	s.SwarmListener.Run()
+
+	s.Nil(s.SwarmListener.NodeEventChan)
+	s.NodeListeningMock.AssertExpectations(s.T())
+}
+
 func (s *SwarmListenerTestSuite) Test_NotifyServices_WithCache() {
 
 	expServices := []SwarmService{
@@ -230,7 +1012,7 @@ func (s *SwarmListenerTestSuite) Test_NotifyServices_WithCache() {
 				ID: "serviceID2"}, nil,
 		},
 	}
-	s.SSClientMock.On("SwarmServiceList", mock.AnythingOfType("*context.emptyCtx"), true).Return(expServices, nil)
+	s.SSClientMock.On("SwarmServiceList", mock.Anything, true).Return(expServices, nil)
 
 	s.SwarmListener.NotifyServices(true)
 
@@ -265,7 +1047,7 @@ func (s *SwarmListenerTestSuite) Test_NotifyServices_WithoutCache() {
 			swarm.Service{ID: "serviceID2"}, nil,
 		},
 	}
-	s.SSClientMock.On("SwarmServiceList", mock.AnythingOfType("*context.emptyCtx"), true).Return(expServices, nil)
+	s.SSClientMock.On("SwarmServiceList", mock.Anything, true).Return(expServices, nil)
 
 	s.SwarmListener.NotifyServices(false)
 
@@ -299,7 +1081,7 @@ func (s *SwarmListenerTestSuite) Test_NotifyNodes_WithoutCache() {
 			ID: "nodeID2",
 		},
 	}
-	s.NodeClientMock.On("NodeList", mock.AnythingOfType("*context.emptyCtx")).Return(expNodes, nil)
+	s.NodeClientMock.On("NodeList", mock.Anything).Return(expNodes, nil)
 
 	s.SwarmListener.NotifyNodes(false)
 
@@ -333,7 +1115,7 @@ func (s *SwarmListenerTestSuite) Test_NotifyNodes_WithCache() {
 			ID: "nodeID2",
 		},
 	}
-	s.NodeClientMock.On("NodeList", mock.AnythingOfType("*context.emptyCtx")).Return(expNodes, nil)
+	s.NodeClientMock.On("NodeList", mock.Anything).Return(expNodes, nil)
 
 	s.SwarmListener.NotifyNodes(true)
 
@@ -368,7 +1150,7 @@ func (s *SwarmListenerTestSuite) Test_GetServices() {
 			swarm.Service{ID: "serviceID2"}, nil,
 		},
 	}
-	s.SSClientMock.On("SwarmServiceList", mock.AnythingOfType("*context.emptyCtx"), true).Return(expServices, nil)
+	s.SSClientMock.On("SwarmServiceList", mock.Anything, true).Return(expServices, nil)
 
 	params, err := s.SwarmListener.GetServicesParameters(context.Background())
 	s.Require().NoError(err)
@@ -383,7 +1165,7 @@ func (s *SwarmListenerTestSuite) Test_GetNodes() {
 		{ID: "nodeID1"},
 		{ID: "nodeID2"},
 	}
-	s.NodeClientMock.On("NodeList", mock.AnythingOfType("*context.emptyCtx")).Return(expServices, nil)
+	s.NodeClientMock.On("NodeList", mock.Anything).Return(expServices, nil)
 
 	params, err := s.SwarmListener.GetNodesParameters(context.Background())
 	s.Require().NoError(err)
@@ -391,3 +1173,15 @@ func (s *SwarmListenerTestSuite) Test_GetNodes() {
 
 	s.NodeClientMock.AssertExpectations(s.T())
 }
+
+func (s *SwarmListenerTestSuite) Test_GetPrometheusTargets() {
+	ssm := getNewSwarmServiceMini()
+	ssm.Labels["com.df.scrapePort"] = "8080"
+	s.SSCacheMock.On("GetAll").Return([]SwarmServiceMini{ssm})
+
+	targets := s.SwarmListener.GetPrometheusTargets()
+	s.Require().Len(targets, 1)
+	s.Equal([]string{"1.0.0.1:8080"}, targets[0].Targets)
+
+	s.SSCacheMock.AssertExpectations(s.T())
+}
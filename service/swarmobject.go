@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+)
+
+// SwarmObjectMini is a optimized version of docker configs and secrets for
+// caching purposes. The data of configs and secrets is never stored
+type SwarmObjectMini struct {
+	ID           string
+	Kind         NotifyType
+	Name         string
+	Labels       map[string]string
+	VersionIndex uint64
+	Services     []string
+}
+
+// Equal returns true when SwarmObjectMini is equal to `other`
+func (om SwarmObjectMini) Equal(other SwarmObjectMini) bool {
+	if len(om.Services) != len(other.Services) {
+		return false
+	}
+	for i := range om.Services {
+		if om.Services[i] != other.Services[i] {
+			return false
+		}
+	}
+	return (om.ID == other.ID) &&
+		(om.Kind == other.Kind) &&
+		(om.Name == other.Name) &&
+		EqualMapStringString(om.Labels, other.Labels) &&
+		(om.VersionIndex == other.VersionIndex)
+}
+
+// SwarmObjectInspector is able to inspect docker configs and secrets
+type SwarmObjectInspector interface {
+	SwarmObjectInspect(ctx context.Context, kind NotifyType, ID string) (SwarmObjectMini, error)
+}
+
+// SwarmObjectClient implements `SwarmObjectInspector` for docker
+type SwarmObjectClient struct {
+	DockerClient *client.Client
+}
+
+// NewSwarmObjectClient creates a `SwarmObjectClient`
+func NewSwarmObjectClient(c *client.Client) *SwarmObjectClient {
+	return &SwarmObjectClient{DockerClient: c}
+}
+
+// SwarmObjectInspect returns the config or secret with `ID` and the names
+// of the services that mount it
+func (c SwarmObjectClient) SwarmObjectInspect(ctx context.Context, kind NotifyType, ID string) (SwarmObjectMini, error) {
+	var om SwarmObjectMini
+	switch kind {
+	case NotifyTypeConfig:
+		config, _, err := c.DockerClient.ConfigInspectWithRaw(ctx, ID)
+		if err != nil {
+			return om, err
+		}
+		om = MinifySwarmObject(kind, config.ID, config.Meta, config.Spec.Annotations)
+	case NotifyTypeSecret:
+		secret, _, err := c.DockerClient.SecretInspectWithRaw(ctx, ID)
+		if err != nil {
+			return om, err
+		}
+		om = MinifySwarmObject(kind, secret.ID, secret.Meta, secret.Spec.Annotations)
+	default:
+		return om, fmt.Errorf("Unknown swarm object kind: %s", kind)
+	}
+
+	services, err := c.DockerClient.ServiceList(ctx, types.ServiceListOptions{})
+	if err != nil {
+		return om, err
+	}
+	om.Services = servicesMountingObject(services, kind, om.ID)
+	return om, nil
+}
+
+// MinifySwarmObject minifies a docker config or secret
+// only labels prefixed with `com.df.` will be used
+func MinifySwarmObject(kind NotifyType, ID string, meta swarm.Meta, annotations swarm.Annotations) SwarmObjectMini {
+	labels := map[string]string{}
+	for k, v := range annotations.Labels {
+		if strings.HasPrefix(k, "com.df.") {
+			labels[k] = v
+		}
+	}
+	return SwarmObjectMini{
+		ID:           ID,
+		Kind:         kind,
+		Name:         annotations.Name,
+		Labels:       labels,
+		VersionIndex: meta.Version.Index,
+		Services:     []string{},
+	}
+}
+
+// servicesMountingObject returns the sorted names of `services` that mount
+// the config or secret with `ID`
+func servicesMountingObject(services []swarm.Service, kind NotifyType, ID string) []string {
+	names := []string{}
+	for _, s := range services {
+		spec := s.Spec.TaskTemplate.ContainerSpec
+		if spec == nil {
+			continue
+		}
+		mounted := false
+		if kind == NotifyTypeConfig {
+			for _, ref := range spec.Configs {
+				if ref != nil && ref.ConfigID == ID {
+					mounted = true
+				}
+			}
+		} else {
+			for _, ref := range spec.Secrets {
+				if ref != nil && ref.SecretID == ID {
+					mounted = true
+				}
+			}
+		}
+		if mounted {
+			names = append(names, s.Spec.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetSwarmObjectMiniCreateParameters converts `SwarmObjectMini` into
+// parameters
+func GetSwarmObjectMiniCreateParameters(om SwarmObjectMini) map[string]string {
+	params := map[string]string{}
+	for k, v := range om.Labels {
+		key := strings.TrimPrefix(k, "com.df.")
+		if len(key) > 0 {
+			params[key] = v
+		}
+	}
+	params["id"] = om.ID
+	params["name"] = om.Name
+	params["versionIndex"] = fmt.Sprintf("%d", om.VersionIndex)
+	params["services"] = strings.Join(om.Services, ",")
+	return params
+}
+
+// GetSwarmObjectMiniRemoveParameters converts `SwarmObjectMini` into remove
+// parameters
+func GetSwarmObjectMiniRemoveParameters(om SwarmObjectMini) map[string]string {
+	return map[string]string{
+		"id":       om.ID,
+		"name":     om.Name,
+		"services": strings.Join(om.Services, ","),
+	}
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/suite"
+)
+
+type SwarmObjectTestSuite struct {
+	suite.Suite
+}
+
+func TestSwarmObjectUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(SwarmObjectTestSuite))
+}
+
+func (s *SwarmObjectTestSuite) Test_MinifySwarmObject_KeepsDFLabels() {
+	meta := swarm.Meta{Version: swarm.Version{Index: 12}}
+	annotations := swarm.Annotations{
+		Name: "proxy_config",
+		Labels: map[string]string{
+			"com.df.notify":              "true",
+			"com.docker.stack.namespace": "proxy",
+		},
+	}
+
+	om := MinifySwarmObject(NotifyTypeConfig, "configID1", meta, annotations)
+	s.Equal(SwarmObjectMini{
+		ID:           "configID1",
+		Kind:         NotifyTypeConfig,
+		Name:         "proxy_config",
+		Labels:       map[string]string{"com.df.notify": "true"},
+		VersionIndex: 12,
+		Services:     []string{},
+	}, om)
+}
+
+func (s *SwarmObjectTestSuite) Test_ServicesMountingObject() {
+	services := []swarm.Service{
+		newObjectService("proxy", []string{"configID1"}, []string{"secretID1"}),
+		newObjectService("api", []string{"configID1"}, nil),
+		newObjectService("db", nil, []string{"secretID1", "secretID2"}),
+		{Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "plugin"}}},
+	}
+
+	s.Equal([]string{"api", "proxy"},
+		servicesMountingObject(services, NotifyTypeConfig, "configID1"))
+	s.Equal([]string{"db", "proxy"},
+		servicesMountingObject(services, NotifyTypeSecret, "secretID1"))
+	s.Equal([]string{},
+		servicesMountingObject(services, NotifyTypeConfig, "secretID1"))
+}
+
+func (s *SwarmObjectTestSuite) Test_Equal() {
+	om := getNewSwarmObjectMini()
+	s.True(om.Equal(getNewSwarmObjectMini()))
+
+	other := getNewSwarmObjectMini()
+	other.Services = []string{"proxy"}
+	s.False(om.Equal(other))
+
+	other = getNewSwarmObjectMini()
+	other.VersionIndex = 20
+	s.False(om.Equal(other))
+
+	other = getNewSwarmObjectMini()
+	other.Labels["com.df.hello"] = "world"
+	s.False(om.Equal(other))
+}
+
+func (s *SwarmObjectTestSuite) Test_GetSwarmObjectMiniCreateParameters() {
+	params := GetSwarmObjectMiniCreateParameters(getNewSwarmObjectMini())
+	s.Equal(map[string]string{
+		"id":           "configID1",
+		"name":         "proxy_config",
+		"versionIndex": "10",
+		"services":     "api,proxy",
+		"notify":       "true",
+	}, params)
+}
+
+func (s *SwarmObjectTestSuite) Test_GetSwarmObjectMiniRemoveParameters() {
+	params := GetSwarmObjectMiniRemoveParameters(getNewSwarmObjectMini())
+	s.Equal(map[string]string{
+		"id":       "configID1",
+		"name":     "proxy_config",
+		"services": "api,proxy",
+	}, params)
+}
+
+func getNewSwarmObjectMini() SwarmObjectMini {
+	return SwarmObjectMini{
+		ID:           "configID1",
+		Kind:         NotifyTypeConfig,
+		Name:         "proxy_config",
+		Labels:       map[string]string{"com.df.notify": "true"},
+		VersionIndex: 10,
+		Services:     []string{"api", "proxy"},
+	}
+}
+
+func newObjectService(name string, configIDs, secretIDs []string) swarm.Service {
+	spec := &swarm.ContainerSpec{}
+	for _, ID := range configIDs {
+		spec.Configs = append(spec.Configs, &swarm.ConfigReference{ConfigID: ID})
+	}
+	for _, ID := range secretIDs {
+		spec.Secrets = append(spec.Secrets, &swarm.SecretReference{SecretID: ID})
+	}
+	return swarm.Service{Spec: swarm.ServiceSpec{
+		Annotations:  swarm.Annotations{Name: name},
+		TaskTemplate: swarm.TaskSpec{ContainerSpec: spec},
+	}}
+}
@@ -0,0 +1,70 @@
+package service
+
+import "sync"
+
+// SwarmObjectCacher caches configs and secrets
+type SwarmObjectCacher interface {
+	InsertAndCheck(om SwarmObjectMini) bool
+	Delete(ID string)
+	Get(ID string) (SwarmObjectMini, bool)
+	GetAll() []SwarmObjectMini
+	Len() int
+}
+
+// SwarmObjectCache implements `SwarmObjectCacher`
+type SwarmObjectCache struct {
+	cache map[string]SwarmObjectMini
+	mux   sync.RWMutex
+}
+
+// NewSwarmObjectCache creates a new `SwarmObjectCache`
+func NewSwarmObjectCache() *SwarmObjectCache {
+	return &SwarmObjectCache{
+		cache: map[string]SwarmObjectMini{},
+	}
+}
+
+// InsertAndCheck inserts `SwarmObjectMini` into cache
+// If the object is new or updated `InsertAndCheck` returns true.
+func (c *SwarmObjectCache) InsertAndCheck(om SwarmObjectMini) bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	cachedObject, ok := c.cache[om.ID]
+	c.cache[om.ID] = om
+
+	return !ok || !om.Equal(cachedObject)
+}
+
+// Delete removes object from cache
+func (c *SwarmObjectCache) Delete(ID string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	delete(c.cache, ID)
+}
+
+// Get gets object from cache
+func (c *SwarmObjectCache) Get(ID string) (SwarmObjectMini, bool) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	v, ok := c.cache[ID]
+	return v, ok
+}
+
+// GetAll returns all objects in cache
+func (c *SwarmObjectCache) GetAll() []SwarmObjectMini {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	objects := make([]SwarmObjectMini, 0, len(c.cache))
+	for _, v := range c.cache {
+		objects = append(objects, v)
+	}
+	return objects
+}
+
+// Len returns the number of objects in cache
+func (c *SwarmObjectCache) Len() int {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return len(c.cache)
+}
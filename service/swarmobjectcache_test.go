@@ -0,0 +1,59 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SwarmObjectCacheTestSuite struct {
+	suite.Suite
+	Cache *SwarmObjectCache
+	OMini SwarmObjectMini
+}
+
+func TestSwarmObjectCacheUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(SwarmObjectCacheTestSuite))
+}
+
+func (s *SwarmObjectCacheTestSuite) SetupTest() {
+	s.Cache = NewSwarmObjectCache()
+	s.OMini = getNewSwarmObjectMini()
+}
+
+func (s *SwarmObjectCacheTestSuite) Test_InsertAndCheck_NewObject_ReturnsTrue() {
+	s.True(s.Cache.InsertAndCheck(s.OMini))
+	s.AssertInCache(s.OMini)
+	s.Equal(1, s.Cache.Len())
+}
+
+func (s *SwarmObjectCacheTestSuite) Test_InsertAndCheck_SameObject_ReturnsFalse() {
+	s.True(s.Cache.InsertAndCheck(s.OMini))
+	s.False(s.Cache.InsertAndCheck(getNewSwarmObjectMini()))
+	s.AssertInCache(s.OMini)
+}
+
+func (s *SwarmObjectCacheTestSuite) Test_InsertAndCheck_NewServices_ReturnsTrue() {
+	s.True(s.Cache.InsertAndCheck(s.OMini))
+
+	newOMini := getNewSwarmObjectMini()
+	newOMini.Services = []string{"proxy"}
+	s.True(s.Cache.InsertAndCheck(newOMini))
+	s.AssertInCache(newOMini)
+}
+
+func (s *SwarmObjectCacheTestSuite) Test_Delete_RemovesObject() {
+	s.Cache.InsertAndCheck(s.OMini)
+	s.Cache.Delete(s.OMini.ID)
+
+	_, ok := s.Cache.Get(s.OMini.ID)
+	s.False(ok)
+	s.Empty(s.Cache.GetAll())
+	s.Equal(0, s.Cache.Len())
+}
+
+func (s *SwarmObjectCacheTestSuite) AssertInCache(om SwarmObjectMini) {
+	cachedOM, ok := s.Cache.Get(om.ID)
+	s.True(ok)
+	s.Equal(om, cachedOM)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/docker/docker/api/types"
@@ -57,7 +59,7 @@ func stateToProgress(state swarm.TaskState, rollback bool) int64 {
 	return numberedStates[swarm.TaskStateRunning] - numberedStates[state]
 }
 
-func getActiveNodes(ctx context.Context, client *client.Client) (map[string]struct{}, error) {
+func getActiveNodes(ctx context.Context, client taskListClient) (map[string]struct{}, error) {
 	nodes, err := client.NodeList(ctx, types.NodeListOptions{})
 	if err != nil {
 		return nil, err
@@ -86,8 +88,63 @@ type progressUpdater interface {
 	update(service swarm.Service, tasks []swarm.Task, activeNodes map[string]struct{}, rollback bool) (bool, error)
 }
 
+// ConvergenceError is returned when a service does not converge within
+// its convergence timeout
+type ConvergenceError struct {
+	ServiceID   string
+	ServiceName string
+	Timeout     time.Duration
+	TaskErrors  []string
+}
+
+func (e *ConvergenceError) Error() string {
+	msg := fmt.Sprintf("service %s did not converge within %s", e.ServiceName, e.Timeout)
+	if len(e.TaskErrors) > 0 {
+		msg = fmt.Sprintf("%s: %s", msg, strings.Join(e.TaskErrors, "; "))
+	}
+	return msg
+}
+
+// taskListOptions configures `getTaskList`
+type taskListOptions struct {
+	// observe is called with the service every time it is inspected
+	observe func(swarm.Service)
+	// timeout stops polling when the service does not converge in time
+	timeout time.Duration
+	// timeoutLabel overrides `timeout` with the value of the service label,
+	// in seconds
+	timeoutLabel string
+	// waitForHealthy waits for the containers of running tasks to be
+	// healthy before the service is converged
+	waitForHealthy bool
+	// waitForHealthyLabel overrides `waitForHealthy` with the value of the
+	// service label
+	waitForHealthyLabel string
+}
+
+// containerInspector is able to inspect containers
+type containerInspector interface {
+	ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error)
+}
+
+// taskListClient is able to inspect the services, tasks, nodes, and
+// containers polled by `getTaskList`
+type taskListClient interface {
+	ServiceInspectWithRaw(ctx context.Context, serviceID string, opts types.ServiceInspectOptions) (swarm.Service, []byte, error)
+	TaskList(ctx context.Context, options types.TaskListOptions) ([]swarm.Task, error)
+	NodeList(ctx context.Context, options types.NodeListOptions) ([]swarm.Node, error)
+	containerInspector
+}
+
 // GetTaskList returns tasks when it is the service is converged
 func GetTaskList(ctx context.Context, client *client.Client, serviceID string) ([]swarm.Task, error) {
+	return getTaskList(ctx, client, serviceID, taskListOptions{})
+}
+
+// getTaskList returns tasks when it is the service is converged
+// A `*ConvergenceError` is returned when the service does not converge
+// within the convergence timeout
+func getTaskList(ctx context.Context, client taskListClient, serviceID string, opts taskListOptions) ([]swarm.Task, error) {
 
 	taskFilter := filters.NewArgs()
 	taskFilter.Add("service", serviceID)
@@ -101,8 +158,10 @@ func GetTaskList(ctx context.Context, client *client.Client, serviceID string) (
 		updater     progressUpdater
 		converged   bool
 		convergedAt time.Time
+		completedAt time.Time
 		monitor     = 5 * time.Second
 		rollback    bool
+		startedAt   = time.Now()
 	)
 
 	taskList := []swarm.Task{}
@@ -113,6 +172,10 @@ func GetTaskList(ctx context.Context, client *client.Client, serviceID string) (
 			return taskList, err
 		}
 
+		if opts.observe != nil {
+			opts.observe(service)
+		}
+
 		if service.Spec.UpdateConfig != nil && service.Spec.UpdateConfig.Monitor != 0 {
 			monitor = service.Spec.UpdateConfig.Monitor
 		}
@@ -129,9 +192,29 @@ func GetTaskList(ctx context.Context, client *client.Client, serviceID string) (
 			case swarm.UpdateStateUpdating:
 				rollback = false
 			case swarm.UpdateStateCompleted:
-				if !converged {
+				// The update completes once tasks are running, which is
+				// before their containers are healthy. `converged`
+				// includes the health of the containers, which are given
+				// the monitor period of the update to become healthy
+				if !waitForHealthy(service, opts) {
+					if !converged {
+						return taskList, nil
+					}
+					break
+				}
+				if converged {
 					return taskList, nil
 				}
+				if completedAt.IsZero() {
+					completedAt = time.Now()
+				} else if time.Since(completedAt) >= monitor {
+					return taskList, &ConvergenceError{
+						ServiceID:   service.ID,
+						ServiceName: service.Spec.Name,
+						Timeout:     monitor,
+						TaskErrors:  []string{"containers are not healthy"},
+					}
+				}
 			case swarm.UpdateStatePaused:
 				return taskList, fmt.Errorf("service update paused: %s", service.UpdateStatus.Message)
 			case swarm.UpdateStateRollbackStarted:
@@ -147,6 +230,15 @@ func GetTaskList(ctx context.Context, client *client.Client, serviceID string) (
 		if converged && time.Since(convergedAt) >= monitor {
 			return taskList, nil
 		}
+		if timeout := convergenceTimeout(service, opts); timeout > 0 &&
+			!converged && time.Since(startedAt) >= timeout {
+			return taskList, &ConvergenceError{
+				ServiceID:   service.ID,
+				ServiceName: service.Spec.Name,
+				Timeout:     timeout,
+				TaskErrors:  getTaskErrors(taskList),
+			}
+		}
 
 		taskList, err = getUpToDateTasks()
 		if err != nil {
@@ -162,6 +254,12 @@ func GetTaskList(ctx context.Context, client *client.Client, serviceID string) (
 		if err != nil {
 			return taskList, err
 		}
+		if converged && waitForHealthy(service, opts) {
+			converged, err = tasksHealthy(ctx, client, taskList)
+			if err != nil {
+				return taskList, err
+			}
+		}
 		if converged {
 			if convergedAt.IsZero() {
 				convergedAt = time.Now()
@@ -175,6 +273,79 @@ func GetTaskList(ctx context.Context, client *client.Client, serviceID string) (
 
 }
 
+// convergenceTimeout returns the timeout of `service`. The service label
+// overrides the default timeout, `0` disables the timeout
+func convergenceTimeout(service swarm.Service, opts taskListOptions) time.Duration {
+	if len(opts.timeoutLabel) > 0 {
+		if value, ok := service.Spec.Labels[opts.timeoutLabel]; ok {
+			if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return opts.timeout
+}
+
+// waitForHealthy returns true when `service` waits for its containers to be
+// healthy. The service label overrides the default
+func waitForHealthy(service swarm.Service, opts taskListOptions) bool {
+	if len(opts.waitForHealthyLabel) > 0 {
+		if value, ok := service.Spec.Labels[opts.waitForHealthyLabel]; ok {
+			if wait, err := strconv.ParseBool(value); err == nil {
+				return wait
+			}
+		}
+	}
+	return opts.waitForHealthy
+}
+
+// tasksHealthy returns true when the containers of the running tasks are
+// healthy. Containers without a health check are healthy
+// Containers are inspected through the docker daemon of the listener.
+// Containers that are not found run on other nodes. Their health can not
+// be observed, so they do not block convergence
+func tasksHealthy(ctx context.Context, inspector containerInspector, tasks []swarm.Task) (bool, error) {
+	for _, task := range tasks {
+		if terminalState(task.DesiredState) || task.Status.State != swarm.TaskStateRunning {
+			continue
+		}
+		if task.Status.ContainerStatus == nil || len(task.Status.ContainerStatus.ContainerID) == 0 {
+			return false, nil
+		}
+		container, err := inspector.ContainerInspect(ctx, task.Status.ContainerStatus.ContainerID)
+		if err != nil {
+			if client.IsErrNotFound(err) {
+				continue
+			}
+			return false, err
+		}
+		if container.ContainerJSONBase == nil || container.State == nil || container.State.Health == nil {
+			continue
+		}
+		if container.State.Health.Status != types.Healthy {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// getTaskErrors returns the unique error messages of `tasks`
+func getTaskErrors(tasks []swarm.Task) []string {
+	taskErrors := []string{}
+	seen := map[string]struct{}{}
+	for _, task := range tasks {
+		if len(task.Status.Err) == 0 {
+			continue
+		}
+		if _, ok := seen[task.Status.Err]; ok {
+			continue
+		}
+		seen[task.Status.Err] = struct{}{}
+		taskErrors = append(taskErrors, task.Status.Err)
+	}
+	return taskErrors
+}
+
 type replicatedProgressUpdater struct {
 	initialized bool
 	done        bool
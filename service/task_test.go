@@ -1,9 +1,14 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"strconv"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/stretchr/testify/suite"
 )
@@ -248,9 +253,254 @@ func (s *TaskTestSuite) Test_GlobalProgressUpdaterManyNodes() {
 	}
 }
 
+func (s *TaskTestSuite) Test_ConvergenceTimeout_LabelOverridesDefault() {
+	opts := taskListOptions{timeout: time.Minute, timeoutLabel: "com.df.convergenceTimeout"}
+	service := swarm.Service{}
+	s.Equal(time.Minute, convergenceTimeout(service, opts))
+
+	service.Spec.Labels = map[string]string{"com.df.convergenceTimeout": "30"}
+	s.Equal(30*time.Second, convergenceTimeout(service, opts))
+
+	service.Spec.Labels["com.df.convergenceTimeout"] = "0"
+	s.Equal(time.Duration(0), convergenceTimeout(service, opts))
+
+	service.Spec.Labels["com.df.convergenceTimeout"] = "soon"
+	s.Equal(time.Minute, convergenceTimeout(service, opts))
+}
+
+func (s *TaskTestSuite) Test_WaitForHealthy_LabelOverridesDefault() {
+	opts := taskListOptions{waitForHealthy: true, waitForHealthyLabel: "com.df.waitForHealthy"}
+	service := swarm.Service{}
+	s.True(waitForHealthy(service, opts))
+
+	service.Spec.Labels = map[string]string{"com.df.waitForHealthy": "false"}
+	s.False(waitForHealthy(service, opts))
+
+	opts.waitForHealthy = false
+	service.Spec.Labels["com.df.waitForHealthy"] = "true"
+	s.True(waitForHealthy(service, opts))
+
+	service.Spec.Labels["com.df.waitForHealthy"] = "maybe"
+	s.False(waitForHealthy(service, opts))
+}
+
+func (s *TaskTestSuite) Test_TasksHealthy() {
+	inspector := fakeContainerInspector{
+		"starting":  types.Starting,
+		"healthy":   types.Healthy,
+		"nocheck":   "",
+		"unhealthy": types.Unhealthy,
+	}
+	task := func(containerID string, state swarm.TaskState) swarm.Task {
+		return swarm.Task{
+			DesiredState: swarm.TaskStateRunning,
+			Status: swarm.TaskStatus{
+				State:           state,
+				ContainerStatus: &swarm.ContainerStatus{ContainerID: containerID},
+			},
+		}
+	}
+
+	healthy, err := tasksHealthy(context.Background(), inspector, []swarm.Task{
+		task("healthy", swarm.TaskStateRunning),
+		task("nocheck", swarm.TaskStateRunning),
+		task("unhealthy", swarm.TaskStateShutdown),
+	})
+	s.Require().NoError(err)
+	s.True(healthy)
+
+	// Containers on other nodes are not found and do not block
+	healthy, err = tasksHealthy(context.Background(), inspector, []swarm.Task{
+		task("healthy", swarm.TaskStateRunning),
+		task("othernode", swarm.TaskStateRunning),
+	})
+	s.Require().NoError(err)
+	s.True(healthy)
+
+	healthy, err = tasksHealthy(context.Background(), inspector, []swarm.Task{
+		task("healthy", swarm.TaskStateRunning),
+		task("starting", swarm.TaskStateRunning),
+	})
+	s.Require().NoError(err)
+	s.False(healthy)
+
+	_, err = tasksHealthy(context.Background(), inspector, []swarm.Task{
+		task("error", swarm.TaskStateRunning),
+	})
+	s.Error(err)
+}
+
+func (s *TaskTestSuite) Test_GetTaskList_UpdateCompleted_WaitsForHealthy() {
+	client := newFakeTaskListClient(fakeContainerInspector{"container1": types.Starting})
+	opts := taskListOptions{waitForHealthy: true}
+
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		client.setHealth("container1", types.Healthy)
+	}()
+	startedAt := time.Now()
+	tasks, err := getTaskList(context.Background(), client, "serviceID1", opts)
+	s.Require().NoError(err)
+	s.Len(tasks, 1)
+	s.True(time.Since(startedAt) >= 500*time.Millisecond)
+}
+
+func (s *TaskTestSuite) Test_GetTaskList_UpdateCompleted_WithoutWaitForHealthy() {
+	client := newFakeTaskListClient(fakeContainerInspector{"container1": types.Starting})
+
+	tasks, err := getTaskList(context.Background(), client, "serviceID1", taskListOptions{})
+	s.Require().NoError(err)
+	s.Empty(tasks)
+}
+
+func (s *TaskTestSuite) Test_GetTaskList_ContainerOnOtherNode_DoesNotBlock() {
+	client := newFakeTaskListClient(fakeContainerInspector{})
+	opts := taskListOptions{waitForHealthy: true}
+
+	done := make(chan struct{})
+	var tasks []swarm.Task
+	var err error
+	go func() {
+		tasks, err = getTaskList(context.Background(), client, "serviceID1", opts)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		s.FailNow("Timeout")
+	}
+	s.Require().NoError(err)
+	s.Len(tasks, 1)
+}
+
+func (s *TaskTestSuite) Test_GetTaskList_UpdateCompleted_UnhealthyAfterMonitor_ReturnsConvergenceError() {
+	client := newFakeTaskListClient(fakeContainerInspector{"container1": types.Starting})
+	client.monitor = 300 * time.Millisecond
+	opts := taskListOptions{waitForHealthy: true}
+
+	_, err := getTaskList(context.Background(), client, "serviceID1", opts)
+	s.Require().Error(err)
+	s.Require().IsType(&ConvergenceError{}, err)
+	s.Equal(300*time.Millisecond, err.(*ConvergenceError).Timeout)
+	s.Equal([]string{"containers are not healthy"}, err.(*ConvergenceError).TaskErrors)
+}
+
+func (s *TaskTestSuite) Test_GetTaskErrors_ReturnsUniqueErrors() {
+	tasks := []swarm.Task{
+		{ID: "1", Status: swarm.TaskStatus{Err: "No such image: demo:bad"}},
+		{ID: "2", Status: swarm.TaskStatus{State: swarm.TaskStateRunning}},
+		{ID: "3", Status: swarm.TaskStatus{Err: "No such image: demo:bad"}},
+		{ID: "4", Status: swarm.TaskStatus{Err: "task: non-zero exit (1)"}},
+	}
+	s.Equal([]string{"No such image: demo:bad", "task: non-zero exit (1)"}, getTaskErrors(tasks))
+}
+
+func (s *TaskTestSuite) Test_ConvergenceError_Error() {
+	err := &ConvergenceError{
+		ServiceName: "demo_go",
+		Timeout:     time.Minute,
+		TaskErrors:  []string{"No such image: demo:bad"},
+	}
+	s.Equal("service demo_go did not converge within 1m0s: No such image: demo:bad", err.Error())
+
+	err.TaskErrors = []string{}
+	s.Equal("service demo_go did not converge within 1m0s", err.Error())
+}
+
 func (s *TaskTestSuite) AssertConvergence(expectedConvergence bool, tasks []swarm.Task) {
 	converged, err := s.updater.update(
 		s.service, tasks, s.activeNodes, s.rollback)
 	s.Require().NoError(err)
 	s.Equal(expectedConvergence, converged)
 }
+
+type containerNotFoundError struct{}
+
+func (e containerNotFoundError) Error() string {
+	return "No such container"
+}
+
+func (e containerNotFoundError) NotFound() bool {
+	return true
+}
+
+// fakeContainerInspector maps container IDs to their health status. An
+// empty status is a container without a health check
+type fakeContainerInspector map[string]string
+
+func (f fakeContainerInspector) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	if containerID == "error" {
+		return types.ContainerJSON{}, errors.New("connection refused")
+	}
+	status, ok := f[containerID]
+	if !ok {
+		return types.ContainerJSON{}, containerNotFoundError{}
+	}
+	state := &types.ContainerState{Running: true}
+	if len(status) > 0 {
+		state.Health = &types.Health{Status: status}
+	}
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{ID: containerID, State: state},
+	}, nil
+}
+
+// fakeTaskListClient is a service with one running replica whose last
+// update completed. `monitor` sets the monitor period of the update
+type fakeTaskListClient struct {
+	containers fakeContainerInspector
+	monitor    time.Duration
+	mux        sync.Mutex
+}
+
+func newFakeTaskListClient(containers fakeContainerInspector) *fakeTaskListClient {
+	return &fakeTaskListClient{containers: containers}
+}
+
+func (f *fakeTaskListClient) setHealth(containerID, status string) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.containers[containerID] = status
+}
+
+func (f *fakeTaskListClient) ServiceInspectWithRaw(ctx context.Context, serviceID string, opts types.ServiceInspectOptions) (swarm.Service, []byte, error) {
+	replicas := uint64(1)
+	service := swarm.Service{
+		ID: serviceID,
+		Spec: swarm.ServiceSpec{
+			Annotations: swarm.Annotations{Name: "demo"},
+			Mode: swarm.ServiceMode{
+				Replicated: &swarm.ReplicatedService{Replicas: &replicas},
+			},
+		},
+		UpdateStatus: &swarm.UpdateStatus{State: swarm.UpdateStateCompleted},
+	}
+	if f.monitor > 0 {
+		service.Spec.UpdateConfig = &swarm.UpdateConfig{Monitor: f.monitor}
+	}
+	return service, nil, nil
+}
+
+func (f *fakeTaskListClient) TaskList(ctx context.Context, options types.TaskListOptions) ([]swarm.Task, error) {
+	return []swarm.Task{{
+		ID:           "task1",
+		NodeID:       "node1",
+		Slot:         1,
+		DesiredState: swarm.TaskStateRunning,
+		Status: swarm.TaskStatus{
+			State:           swarm.TaskStateRunning,
+			ContainerStatus: &swarm.ContainerStatus{ContainerID: "container1"},
+		},
+	}}, nil
+}
+
+func (f *fakeTaskListClient) NodeList(ctx context.Context, options types.NodeListOptions) ([]swarm.Node, error) {
+	return []swarm.Node{{ID: "node1", Status: swarm.NodeStatus{State: swarm.NodeStateReady}}}, nil
+}
+
+func (f *fakeTaskListClient) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return f.containers.ContainerInspect(ctx, containerID)
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+)
+
+// TaskInspector is able to list the tasks of a service
+type TaskInspector interface {
+	TaskList(ctx context.Context, serviceID string) ([]swarm.Task, error)
+}
+
+// TaskClient implements `TaskInspector` for docker
+type TaskClient struct {
+	DockerClient *client.Client
+}
+
+// NewTaskClient creates a `TaskClient`
+func NewTaskClient(c *client.Client) *TaskClient {
+	return &TaskClient{DockerClient: c}
+}
+
+// TaskList returns the tasks of service `serviceID`
+func (c TaskClient) TaskList(ctx context.Context, serviceID string) ([]swarm.Task, error) {
+	filter := filters.NewArgs()
+	filter.Add("service", serviceID)
+	return c.DockerClient.TaskList(ctx, types.TaskListOptions{Filters: filter})
+}
+
+// taskSnapshot is the state of a task observed by `TaskWatcher`
+type taskSnapshot struct {
+	ID        string
+	NodeID    string
+	Slot      int
+	State     swarm.TaskState
+	Err       string
+	Addresses []string
+}
+
+func newTaskSnapshot(task swarm.Task) taskSnapshot {
+	addresses := []string{}
+	for _, attachment := range task.NetworksAttachments {
+		for _, addr := range attachment.Addresses {
+			addresses = append(addresses, strings.Split(addr, "/")[0])
+		}
+	}
+	return taskSnapshot{
+		ID:        task.ID,
+		NodeID:    task.NodeID,
+		Slot:      task.Slot,
+		State:     task.Status.State,
+		Err:       task.Status.Err,
+		Addresses: addresses,
+	}
+}
+
+func (t taskSnapshot) failed() bool {
+	return t.State == swarm.TaskStateFailed || t.State == swarm.TaskStateRejected
+}
+
+// TaskWatcher polls the tasks of cached services
+// Docker does not emit events for tasks, so a task that crashes and is
+// rescheduled does not trigger a service event. `TaskWatcher` compares the
+// tasks of every cached service with the previous poll and reports
+// started, failed, and moved tasks
+type TaskWatcher struct {
+	TaskClient TaskInspector
+	SSCache    SwarmServiceCacher
+	Interval   time.Duration
+	tasks      map[string]map[string]taskSnapshot
+	mux        sync.Mutex
+	log        *log.Logger
+}
+
+// NewTaskWatcher creates a `TaskWatcher`
+func NewTaskWatcher(taskClient TaskInspector, ssCache SwarmServiceCacher, interval time.Duration, logger *log.Logger) *TaskWatcher {
+	return &TaskWatcher{
+		TaskClient: taskClient,
+		SSCache:    ssCache,
+		Interval:   interval,
+		tasks:      map[string]map[string]taskSnapshot{},
+		log:        logger,
+	}
+}
+
+// Run polls tasks every `Interval`
+// Task notifications are placed on `taskChan` and services whose tasks
+// changed are placed on `serviceEventChan` as create events, which
+// refreshes their `NodeInfo`. Nil channels are skipped
+func (w *TaskWatcher) Run(taskChan chan<- Notification, serviceEventChan chan<- Event) {
+	go func() {
+		ticker := time.NewTicker(w.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			notifications, changedIDs := w.Poll(context.Background())
+			if taskChan != nil {
+				for _, n := range notifications {
+					taskChan <- n
+				}
+			}
+			if serviceEventChan != nil {
+				nowTimeNano := time.Now().UTC().UnixNano()
+				for _, ID := range changedIDs {
+					serviceEventChan <- Event{
+						Type:     EventTypeCreate,
+						ID:       ID,
+						TimeNano: nowTimeNano,
+					}
+				}
+			}
+		}
+	}()
+}
+
+// Poll lists the tasks of every cached service and returns the task
+// notifications and the IDs of services whose tasks changed since the
+// previous poll. The first poll of a service records its tasks without
+// reporting them
+func (w *TaskWatcher) Poll(ctx context.Context) ([]Notification, []string) {
+	w.mux.Lock()
+	services := w.SSCache.GetAll()
+	w.mux.Unlock()
+	sort.Slice(services, func(i, j int) bool { return services[i].ID < services[j].ID })
+
+	// Tasks are listed without holding the lock, since listing the tasks
+	// of many services takes a while
+	taskLists := map[string][]swarm.Task{}
+	for _, ssm := range services {
+		taskList, err := w.TaskClient.TaskList(ctx, ssm.ID)
+		if err != nil {
+			w.log.Printf("ERROR: Unable to list tasks of service %s: %v", ssm.Name, err)
+			metrics.RecordError("taskWatcherTaskList")
+			continue
+		}
+		taskLists[ssm.ID] = taskList
+	}
+
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	nowTimeNano := time.Now().UTC().UnixNano()
+	seen := map[string]struct{}{}
+	notifications := []Notification{}
+	changedIDs := []string{}
+
+	for _, ssm := range services {
+		seen[ssm.ID] = struct{}{}
+		taskList, ok := taskLists[ssm.ID]
+		if !ok {
+			continue
+		}
+
+		current := map[string]taskSnapshot{}
+		for _, task := range taskList {
+			current[task.ID] = newTaskSnapshot(task)
+		}
+		previous, ok := w.tasks[ssm.ID]
+		w.tasks[ssm.ID] = current
+		if !ok {
+			continue
+		}
+
+		ssm := ssm
+		serviceNotifications := diffTasks(ssm, previous, current)
+		for i := range serviceNotifications {
+			serviceNotifications[i].TimeNano = nowTimeNano
+			serviceNotifications[i].Service = &ssm
+		}
+		if len(serviceNotifications) > 0 {
+			notifications = append(notifications, serviceNotifications...)
+			changedIDs = append(changedIDs, ssm.ID)
+		}
+	}
+
+	// Forget services that are no longer cached
+	for ID := range w.tasks {
+		if _, ok := seen[ID]; !ok {
+			delete(w.tasks, ID)
+		}
+	}
+	return notifications, changedIDs
+}
+
+// diffTasks returns the notifications of tasks that started, failed, or
+// moved between `previous` and `current`
+func diffTasks(ssm SwarmServiceMini, previous, current map[string]taskSnapshot) []Notification {
+	taskIDs := []string{}
+	for ID := range current {
+		taskIDs = append(taskIDs, ID)
+	}
+	sort.Strings(taskIDs)
+
+	notifications := []Notification{}
+	for _, ID := range taskIDs {
+		task := current[ID]
+		prevTask, existed := previous[ID]
+
+		if task.State == swarm.TaskStateRunning &&
+			(!existed || prevTask.State != swarm.TaskStateRunning) {
+			eventType := EventTypeTaskStarted
+			params := getTaskParameters(ssm, task)
+			if replaced, ok := replacedTask(previous, current, task); ok {
+				eventType = EventTypeTaskMoved
+				params["previousNodeID"] = replaced.NodeID
+			}
+			notifications = append(notifications, newTaskNotification(eventType, task, params))
+		} else if task.failed() && (!existed || prevTask.State != task.State) {
+			params := getTaskParameters(ssm, task)
+			if len(task.Err) > 0 {
+				params["error"] = task.Err
+			}
+			notifications = append(notifications,
+				newTaskNotification(EventTypeTaskFailed, task, params))
+		}
+	}
+	return notifications
+}
+
+// replacedTask returns the task that ran in the slot of `task` on another
+// node and is no longer running. Global services do not have slots
+func replacedTask(previous, current map[string]taskSnapshot, task taskSnapshot) (taskSnapshot, bool) {
+	if task.Slot == 0 {
+		return taskSnapshot{}, false
+	}
+	for ID, prevTask := range previous {
+		if ID == task.ID || prevTask.Slot != task.Slot ||
+			prevTask.State != swarm.TaskStateRunning || prevTask.NodeID == task.NodeID {
+			continue
+		}
+		if currTask, ok := current[ID]; ok && currTask.State == swarm.TaskStateRunning {
+			continue
+		}
+		return prevTask, true
+	}
+	return taskSnapshot{}, false
+}
+
+func getTaskParameters(ssm SwarmServiceMini, task taskSnapshot) map[string]string {
+	params := map[string]string{
+		"taskID":      task.ID,
+		"serviceID":   ssm.ID,
+		"serviceName": ssm.Name,
+		"nodeID":      task.NodeID,
+		"state":       string(task.State),
+	}
+	if task.Slot > 0 {
+		params["slot"] = strconv.Itoa(task.Slot)
+	}
+	if len(task.Addresses) > 0 {
+		params["addresses"] = strings.Join(task.Addresses, ",")
+	}
+	return params
+}
+
+func newTaskNotification(eventType EventType, task taskSnapshot, params map[string]string) Notification {
+	params[EventParam] = string(eventType)
+	return Notification{
+		EventType:  eventType,
+		ID:         task.ID,
+		Parameters: ConvertMapStringStringToURLValues(params).Encode(),
+	}
+}
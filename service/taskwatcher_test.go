@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type TaskWatcherTestSuite struct {
+	suite.Suite
+	TaskClientMock *taskInspectorMock
+	SSCacheMock    *swarmServiceCacherMock
+	Service        SwarmServiceMini
+	Watcher        *TaskWatcher
+}
+
+func TestTaskWatcherUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(TaskWatcherTestSuite))
+}
+
+func (s *TaskWatcherTestSuite) SetupTest() {
+	s.TaskClientMock = new(taskInspectorMock)
+	s.SSCacheMock = new(swarmServiceCacherMock)
+	s.Service = SwarmServiceMini{ID: "serviceID1", Name: "demo_go"}
+	s.Watcher = NewTaskWatcher(
+		s.TaskClientMock, s.SSCacheMock, time.Second, log.New(ioutil.Discard, "", 0))
+	s.SSCacheMock.On("GetAll").Return([]SwarmServiceMini{s.Service})
+}
+
+func (s *TaskWatcherTestSuite) Test_Poll_FirstPoll_RecordsTasks() {
+	s.TaskClientMock.On("TaskList", mock.Anything, "serviceID1").Return([]swarm.Task{
+		newTask("task1", "node1", 1, swarm.TaskStateRunning),
+	}, nil).Once()
+
+	notifications, changedIDs := s.Watcher.Poll(context.Background())
+	s.Empty(notifications)
+	s.Empty(changedIDs)
+	s.Contains(s.Watcher.tasks, "serviceID1")
+}
+
+func (s *TaskWatcherTestSuite) Test_Poll_ListsTasksWithoutLock() {
+	locked := true
+	s.TaskClientMock.On("TaskList", mock.Anything, "serviceID1").Return([]swarm.Task{}, nil).
+		Run(func(args mock.Arguments) {
+			if s.Watcher.mux.TryLock() {
+				locked = false
+				s.Watcher.mux.Unlock()
+			}
+		}).Once()
+
+	s.Watcher.Poll(context.Background())
+	s.False(locked)
+}
+
+func (s *TaskWatcherTestSuite) Test_Poll_NoChanges_ReturnsEmpty() {
+	tasks := []swarm.Task{newTask("task1", "node1", 1, swarm.TaskStateRunning)}
+	s.TaskClientMock.On("TaskList", mock.Anything, "serviceID1").Return(tasks, nil)
+
+	s.Watcher.Poll(context.Background())
+	notifications, changedIDs := s.Watcher.Poll(context.Background())
+	s.Empty(notifications)
+	s.Empty(changedIDs)
+}
+
+func (s *TaskWatcherTestSuite) Test_Poll_TaskStarted() {
+	s.TaskClientMock.On("TaskList", mock.Anything, "serviceID1").Return([]swarm.Task{
+		newTask("task1", "node1", 1, swarm.TaskStateStarting),
+	}, nil).Once()
+	running := newTask("task1", "node1", 1, swarm.TaskStateRunning)
+	running.NetworksAttachments = []swarm.NetworkAttachment{
+		{Addresses: []string{"10.0.0.5/24"}},
+	}
+	s.TaskClientMock.On("TaskList", mock.Anything, "serviceID1").Return([]swarm.Task{running}, nil).Once()
+
+	s.Watcher.Poll(context.Background())
+	notifications, changedIDs := s.Watcher.Poll(context.Background())
+
+	s.Equal([]string{"serviceID1"}, changedIDs)
+	s.Require().Len(notifications, 1)
+	n := notifications[0]
+	s.Equal(EventTypeTaskStarted, n.EventType)
+	s.Equal("task1", n.ID)
+	s.NotZero(n.TimeNano)
+	s.Equal(&s.Service, n.Service)
+
+	params, err := url.ParseQuery(n.Parameters)
+	s.Require().NoError(err)
+	s.Equal("task-started", params.Get("swarmListener.event"))
+	s.Equal("task1", params.Get("taskID"))
+	s.Equal("serviceID1", params.Get("serviceID"))
+	s.Equal("demo_go", params.Get("serviceName"))
+	s.Equal("node1", params.Get("nodeID"))
+	s.Equal("1", params.Get("slot"))
+	s.Equal("running", params.Get("state"))
+	s.Equal("10.0.0.5", params.Get("addresses"))
+}
+
+func (s *TaskWatcherTestSuite) Test_Poll_TaskFailedAndMoved() {
+	s.TaskClientMock.On("TaskList", mock.Anything, "serviceID1").Return([]swarm.Task{
+		newTask("task1", "node1", 1, swarm.TaskStateRunning),
+	}, nil).Once()
+	failed := newTask("task1", "node1", 1, swarm.TaskStateFailed)
+	failed.Status.Err = "task: non-zero exit (1)"
+	s.TaskClientMock.On("TaskList", mock.Anything, "serviceID1").Return([]swarm.Task{
+		failed,
+		newTask("task2", "node2", 1, swarm.TaskStateRunning),
+	}, nil).Once()
+
+	s.Watcher.Poll(context.Background())
+	notifications, changedIDs := s.Watcher.Poll(context.Background())
+
+	s.Equal([]string{"serviceID1"}, changedIDs)
+	s.Require().Len(notifications, 2)
+
+	s.Equal(EventTypeTaskFailed, notifications[0].EventType)
+	s.Equal("task1", notifications[0].ID)
+	params, err := url.ParseQuery(notifications[0].Parameters)
+	s.Require().NoError(err)
+	s.Equal("task: non-zero exit (1)", params.Get("error"))
+	s.Equal("failed", params.Get("state"))
+
+	s.Equal(EventTypeTaskMoved, notifications[1].EventType)
+	s.Equal("task2", notifications[1].ID)
+	params, err = url.ParseQuery(notifications[1].Parameters)
+	s.Require().NoError(err)
+	s.Equal("node2", params.Get("nodeID"))
+	s.Equal("node1", params.Get("previousNodeID"))
+}
+
+func (s *TaskWatcherTestSuite) Test_Poll_RestartOnSameNode_IsStarted() {
+	s.TaskClientMock.On("TaskList", mock.Anything, "serviceID1").Return([]swarm.Task{
+		newTask("task1", "node1", 1, swarm.TaskStateRunning),
+	}, nil).Once()
+	s.TaskClientMock.On("TaskList", mock.Anything, "serviceID1").Return([]swarm.Task{
+		newTask("task1", "node1", 1, swarm.TaskStateShutdown),
+		newTask("task2", "node1", 1, swarm.TaskStateRunning),
+	}, nil).Once()
+
+	s.Watcher.Poll(context.Background())
+	notifications, _ := s.Watcher.Poll(context.Background())
+
+	s.Require().Len(notifications, 1)
+	s.Equal(EventTypeTaskStarted, notifications[0].EventType)
+	s.Equal("task2", notifications[0].ID)
+}
+
+func (s *TaskWatcherTestSuite) Test_Poll_TaskListError_SkipsService() {
+	s.TaskClientMock.On("TaskList", mock.Anything, "serviceID1").Return(
+		[]swarm.Task{}, errors.New("Docker is down"))
+
+	notifications, changedIDs := s.Watcher.Poll(context.Background())
+	s.Empty(notifications)
+	s.Empty(changedIDs)
+	s.NotContains(s.Watcher.tasks, "serviceID1")
+}
+
+func (s *TaskWatcherTestSuite) Test_Poll_ServiceNoLongerCached_IsForgotten() {
+	s.Watcher.tasks["serviceID2"] = map[string]taskSnapshot{}
+	s.TaskClientMock.On("TaskList", mock.Anything, "serviceID1").Return([]swarm.Task{}, nil)
+
+	s.Watcher.Poll(context.Background())
+	s.NotContains(s.Watcher.tasks, "serviceID2")
+	s.Contains(s.Watcher.tasks, "serviceID1")
+}
+
+func (s *TaskWatcherTestSuite) Test_Run_PlacesNotificationsAndEventsOnChannels() {
+	s.Watcher.Interval = 10 * time.Millisecond
+	s.TaskClientMock.On("TaskList", mock.Anything, "serviceID1").Return([]swarm.Task{
+		newTask("task1", "node1", 1, swarm.TaskStateStarting),
+	}, nil).Once()
+	s.TaskClientMock.On("TaskList", mock.Anything, "serviceID1").Return([]swarm.Task{
+		newTask("task1", "node1", 1, swarm.TaskStateRunning),
+	}, nil)
+
+	taskChan := make(chan Notification)
+	eventChan := make(chan Event)
+	s.Watcher.Run(taskChan, eventChan)
+
+	timer := time.NewTimer(time.Second).C
+	select {
+	case n := <-taskChan:
+		s.Equal(EventTypeTaskStarted, n.EventType)
+	case <-timer:
+		s.Fail("Timeout")
+		return
+	}
+	select {
+	case e := <-eventChan:
+		s.Equal(EventTypeCreate, e.Type)
+		s.Equal("serviceID1", e.ID)
+	case <-timer:
+		s.Fail("Timeout")
+	}
+}
+
+func newTask(ID, nodeID string, slot int, state swarm.TaskState) swarm.Task {
+	return swarm.Task{
+		ID:     ID,
+		NodeID: nodeID,
+		Slot:   slot,
+		Status: swarm.TaskStatus{State: state},
+	}
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
+)
+
+// TemplateConfig defines a template, where it is rendered to, and the
+// command to run when the rendered output changes
+type TemplateConfig struct {
+	Source      string
+	Destination string
+	Command     string
+}
+
+// TemplateData is the data passed to templates
+type TemplateData struct {
+	Services []SwarmServiceMini
+	Nodes    []NodeMini
+}
+
+var templateFuncs = template.FuncMap{
+	"serviceParams": GetSwarmServiceMiniCreateParameters,
+	"nodeParams":    GetNodeMiniCreateParameters,
+	"split":         strings.Split,
+	"join":          strings.Join,
+	"hasPrefix":     strings.HasPrefix,
+}
+
+// TemplateRenderer renders templates from cached services and nodes
+// It implements `NotificationSender` so that it can be placed on a
+// `NotifyEndpoint` and render after every cache change
+type TemplateRenderer struct {
+	templates []TemplateConfig
+	ssCache   SwarmServiceCacher
+	nodeCache NodeCacher
+	rendered  map[string][]byte
+	mux       sync.Mutex
+	log       *log.Logger
+}
+
+// NewTemplateRenderer creates a `TemplateRenderer`
+func NewTemplateRenderer(templates []TemplateConfig, ssCache SwarmServiceCacher, nodeCache NodeCacher, logger *log.Logger) *TemplateRenderer {
+	return &TemplateRenderer{
+		templates: templates,
+		ssCache:   ssCache,
+		nodeCache: nodeCache,
+		rendered:  map[string][]byte{},
+		log:       logger,
+	}
+}
+
+// templateConfigsFromEnv returns a template for every environment variable
+// prefixed with `DF_TEMPLATE_`, sorted by the names of the variables
+func templateConfigsFromEnv() []TemplateConfig {
+	names := []string{}
+	values := map[string]string{}
+	for _, env := range os.Environ() {
+		parts := strings.SplitN(env, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], "DF_TEMPLATE_") {
+			continue
+		}
+		names = append(names, parts[0])
+		values[parts[0]] = parts[1]
+	}
+	sort.Strings(names)
+
+	templates := []TemplateConfig{}
+	for _, name := range names {
+		if tc, ok := parseTemplateConfig(values[name]); ok {
+			templates = append(templates, tc)
+		}
+	}
+	return templates
+}
+
+// parseTemplateConfig parses `source:destination[:command]`
+// The command is the rest of the value, so it can contain any character
+func parseTemplateConfig(value string) (TemplateConfig, bool) {
+	parts := strings.SplitN(strings.TrimSpace(value), ":", 3)
+	if len(parts) < 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return TemplateConfig{}, false
+	}
+	tc := TemplateConfig{Source: parts[0], Destination: parts[1]}
+	if len(parts) == 3 {
+		tc.Command = parts[2]
+	}
+	return tc, true
+}
+
+// Create renders templates
+func (r *TemplateRenderer) Create(ctx context.Context, params string) error {
+	return r.Render()
+}
+
+// Remove renders templates
+func (r *TemplateRenderer) Remove(ctx context.Context, params string) error {
+	return r.Render()
+}
+
+// GetCreateAddr returns the template destinations
+func (r *TemplateRenderer) GetCreateAddr() string {
+	return r.destinations()
+}
+
+// GetRemoveAddr returns the template destinations
+func (r *TemplateRenderer) GetRemoveAddr() string {
+	return r.destinations()
+}
+
+func (r *TemplateRenderer) destinations() string {
+	dests := []string{}
+	for _, t := range r.templates {
+		dests = append(dests, t.Destination)
+	}
+	return strings.Join(dests, ",")
+}
+
+// Render renders all templates and runs the commands of templates whose
+// output changed. Each command is ran at most once per render
+// Destinations are only written when their output differs from the last
+// render
+func (r *TemplateRenderer) Render() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	data := r.getTemplateData()
+
+	var lastErr error
+	commands := []string{}
+	ranCommands := map[string]bool{}
+	for _, t := range r.templates {
+		content, err := renderTemplate(t, data)
+		if err != nil {
+			r.log.Printf("ERROR: Unable to render template %s: %v", t.Source, err)
+			metrics.RecordError("templateRender")
+			lastErr = err
+			continue
+		}
+		if last, ok := r.rendered[t.Destination]; ok && bytes.Equal(last, content) {
+			continue
+		}
+		changed, err := writeFileIfChanged(t.Destination, content)
+		if err != nil {
+			r.log.Printf("ERROR: Unable to write template %s to %s: %v", t.Source, t.Destination, err)
+			metrics.RecordError("templateRender")
+			lastErr = err
+			continue
+		}
+		r.rendered[t.Destination] = content
+		if !changed {
+			continue
+		}
+		r.log.Printf("Rendered template %s to %s", t.Source, t.Destination)
+		if len(t.Command) > 0 && !ranCommands[t.Command] {
+			ranCommands[t.Command] = true
+			commands = append(commands, t.Command)
+		}
+	}
+
+	for _, command := range commands {
+		r.log.Printf("Running template command: %s", command)
+		output, err := exec.Command("sh", "-c", command).CombinedOutput()
+		if err != nil {
+			r.log.Printf("ERROR: Template command %s failed: %v\n%s", command, err, output)
+			metrics.RecordError("templateCommand")
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (r *TemplateRenderer) getTemplateData() TemplateData {
+	services := r.ssCache.GetAll()
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Name == services[j].Name {
+			return services[i].ID < services[j].ID
+		}
+		return services[i].Name < services[j].Name
+	})
+	nodes := r.nodeCache.GetAll()
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Hostname == nodes[j].Hostname {
+			return nodes[i].ID < nodes[j].ID
+		}
+		return nodes[i].Hostname < nodes[j].Hostname
+	})
+	return TemplateData{Services: services, Nodes: nodes}
+}
+
+// renderTemplate renders `t` with `data`
+func renderTemplate(t TemplateConfig, data TemplateData) ([]byte, error) {
+	tmpl, err := template.New(filepath.Base(t.Source)).
+		Funcs(templateFuncs).ParseFiles(t.Source)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeFileIfChanged atomically writes `content` to `path` by writing to a
+// temporary file and renaming it. Returns false when `path` already has
+// `content`. An existing file keeps its mode, new files are created with
+// mode 0644
+func writeFileIfChanged(path string, content []byte) (bool, error) {
+	current, err := ioutil.ReadFile(path)
+	if err == nil && bytes.Equal(current, content) {
+		return false, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	dir, base := filepath.Split(path)
+	if len(dir) == 0 {
+		dir = "."
+	}
+	tmpFile, err := ioutil.TempFile(dir, fmt.Sprintf(".%s.", base))
+	if err != nil {
+		return false, err
+	}
+	tmpName := tmpFile.Name()
+	defer os.Remove(tmpName)
+
+	if _, err := tmpFile.Write(content); err != nil {
+		tmpFile.Close()
+		return false, err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return false, err
+	}
+	if err := os.Chmod(tmpName, mode); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type TemplateRendererTestSuite struct {
+	suite.Suite
+	Dir       string
+	SSCache   *SwarmServiceCache
+	NodeCache *NodeCache
+	Logger    *log.Logger
+	LogBytes  *bytes.Buffer
+}
+
+func TestTemplateRendererUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(TemplateRendererTestSuite))
+}
+
+func (s *TemplateRendererTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "dfsl-template")
+	s.Require().NoError(err)
+	s.Dir = dir
+	s.SSCache = NewSwarmServiceCache()
+	s.NodeCache = NewNodeCache()
+	s.LogBytes = new(bytes.Buffer)
+	s.Logger = log.New(s.LogBytes, "", 0)
+}
+
+func (s *TemplateRendererTestSuite) TearDownTest() {
+	os.RemoveAll(s.Dir)
+}
+
+func (s *TemplateRendererTestSuite) Test_TemplateConfigsFromEnv() {
+	env := map[string]string{
+		"DF_TEMPLATE_B":       "/in/b.tmpl:/out/b.cfg:curl -s http://proxy:8080/reload?a=1,2",
+		"DF_TEMPLATE_A":       "/in/a.tmpl:/out/a.cfg",
+		"DF_TEMPLATE_INVALID": "invalid",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	templates := templateConfigsFromEnv()
+
+	s.Require().Len(templates, 2)
+	s.Equal(TemplateConfig{Source: "/in/a.tmpl", Destination: "/out/a.cfg"}, templates[0])
+	s.Equal(TemplateConfig{
+		Source:      "/in/b.tmpl",
+		Destination: "/out/b.cfg",
+		Command:     "curl -s http://proxy:8080/reload?a=1,2",
+	}, templates[1])
+}
+
+func (s *TemplateRendererTestSuite) Test_Render_WritesServicesAndNodes() {
+	src := s.writeTemplate(
+		`{{range .Services}}{{.Name}} {{index (serviceParams .) "hello"}}
+{{end}}{{range .Nodes}}{{.Hostname}} {{.Addr}}
+{{end}}`)
+	dest := filepath.Join(s.Dir, "out.cfg")
+
+	s.SSCache.InsertAndCheck(getNewSwarmServiceMini())
+	s.NodeCache.InsertAndCheck(getNewNodeMini())
+
+	r := NewTemplateRenderer([]TemplateConfig{{Source: src, Destination: dest}},
+		s.SSCache, s.NodeCache, s.Logger)
+	s.Equal(dest, r.GetCreateAddr())
+
+	err := r.Render()
+	s.Require().NoError(err)
+
+	content, err := ioutil.ReadFile(dest)
+	s.Require().NoError(err)
+	s.Equal("demo-go nyc\nnodehostname nodeaddr\n", string(content))
+}
+
+func (s *TemplateRendererTestSuite) Test_Render_RunsCommandOnlyWhenChanged() {
+	src := s.writeTemplate(`{{range .Services}}{{.Name}}{{end}}`)
+	dest := filepath.Join(s.Dir, "out.cfg")
+	counter := filepath.Join(s.Dir, "counter")
+	command := "echo reload >> " + counter
+
+	s.SSCache.InsertAndCheck(getNewSwarmServiceMini())
+
+	r := NewTemplateRenderer([]TemplateConfig{{Source: src, Destination: dest, Command: command}},
+		s.SSCache, s.NodeCache, s.Logger)
+
+	s.Require().NoError(r.Render())
+	s.Require().NoError(r.Render())
+
+	content, err := ioutil.ReadFile(counter)
+	s.Require().NoError(err)
+	s.Equal(1, strings.Count(string(content), "reload"))
+
+	s.SSCache.Delete("serviceID")
+	s.Require().NoError(r.Remove(context.Background(), ""))
+
+	content, err = ioutil.ReadFile(counter)
+	s.Require().NoError(err)
+	s.Equal(2, strings.Count(string(content), "reload"))
+
+	rendered, err := ioutil.ReadFile(dest)
+	s.Require().NoError(err)
+	s.Empty(rendered)
+}
+
+func (s *TemplateRendererTestSuite) Test_Render_DoesNotWriteUnchangedOutput() {
+	src := s.writeTemplate(`{{range .Services}}{{.Name}}{{end}}`)
+	dest := filepath.Join(s.Dir, "out.cfg")
+	s.SSCache.InsertAndCheck(getNewSwarmServiceMini())
+
+	r := NewTemplateRenderer([]TemplateConfig{{Source: src, Destination: dest}},
+		s.SSCache, s.NodeCache, s.Logger)
+
+	s.Require().NoError(r.Render())
+	before, err := os.Stat(dest)
+	s.Require().NoError(err)
+	s.Require().NoError(r.Render())
+	after, err := os.Stat(dest)
+	s.Require().NoError(err)
+
+	s.True(os.SameFile(before, after))
+}
+
+func (s *TemplateRendererTestSuite) Test_WriteFileIfChanged_KeepsModeOfExistingFile() {
+	existing := filepath.Join(s.Dir, "existing.conf")
+	s.Require().NoError(ioutil.WriteFile(existing, []byte("old"), 0600))
+	s.Require().NoError(os.Chmod(existing, 0600))
+
+	written, err := writeFileIfChanged(existing, []byte("new"))
+	s.Require().NoError(err)
+	s.True(written)
+	info, err := os.Stat(existing)
+	s.Require().NoError(err)
+	s.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	created := filepath.Join(s.Dir, "created.conf")
+	written, err = writeFileIfChanged(created, []byte("new"))
+	s.Require().NoError(err)
+	s.True(written)
+	info, err = os.Stat(created)
+	s.Require().NoError(err)
+	s.Equal(os.FileMode(0644), info.Mode().Perm())
+}
+
+func (s *TemplateRendererTestSuite) Test_Render_ReturnsError_WhenTemplateIsInvalid() {
+	src := s.writeTemplate(`{{.Services`)
+	dest := filepath.Join(s.Dir, "out.cfg")
+
+	r := NewTemplateRenderer([]TemplateConfig{{Source: src, Destination: dest}},
+		s.SSCache, s.NodeCache, s.Logger)
+
+	err := r.Render()
+	s.Error(err)
+	s.Contains(s.LogBytes.String(), "Unable to render template")
+
+	_, err = os.Stat(dest)
+	s.True(os.IsNotExist(err))
+}
+
+func (s *TemplateRendererTestSuite) writeTemplate(content string) string {
+	src := filepath.Join(s.Dir, "in.tmpl")
+	err := ioutil.WriteFile(src, []byte(content), 0644)
+	s.Require().NoError(err)
+	return src
+}
@@ -177,3 +177,16 @@ func getNewNodeMini() NodeMini {
 		Availability: swarm.NodeAvailabilityActive,
 	}
 }
+
+func getNewNetworkMini() NetworkMini {
+	return NetworkMini{
+		ID:     "networkID",
+		Name:   "proxy",
+		Driver: "overlay",
+		Scope:  "swarm",
+		Labels: map[string]string{
+			"com.df.proxy":               "true",
+			"com.docker.stack.namespace": "proxy",
+		},
+	}
+}
@@ -14,6 +14,7 @@ type SwarmServiceMini struct {
 	Global   bool
 	Replicas uint64
 	NodeInfo NodeIPSet
+	Endpoint *EndpointMini
 }
 
 // Equal returns when SwarmServiceMini is equal to `other`
@@ -24,7 +25,53 @@ func (ssm SwarmServiceMini) Equal(other SwarmServiceMini) bool {
 		EqualMapStringString(ssm.Labels, other.Labels) &&
 		(ssm.Global == other.Global) &&
 		(ssm.Replicas == other.Replicas) &&
-		EqualNodeIPSet(ssm.NodeInfo, other.NodeInfo)
+		EqualNodeIPSet(ssm.NodeInfo, other.NodeInfo) &&
+		EqualEndpointMini(ssm.Endpoint, other.Endpoint)
+}
+
+// EndpointMini is a optimized version of `swarm.Endpoint` for caching
+// purposes
+type EndpointMini struct {
+	Mode       swarm.ResolutionMode
+	Ports      []PortMini
+	VirtualIPs []VirtualIPMini
+}
+
+// PortMini is a port of a service endpoint
+type PortMini struct {
+	Protocol      swarm.PortConfigProtocol    `json:"protocol"`
+	TargetPort    uint32                      `json:"targetPort"`
+	PublishedPort uint32                      `json:"publishedPort"`
+	PublishMode   swarm.PortConfigPublishMode `json:"publishMode"`
+}
+
+// VirtualIPMini is the virtual IP of a service endpoint on a network
+type VirtualIPMini struct {
+	NetworkID string `json:"networkID"`
+	Addr      string `json:"addr"`
+}
+
+// EqualEndpointMini returns true when the endpoints are equal
+func EqualEndpointMini(l *EndpointMini, r *EndpointMini) bool {
+	if l == nil || r == nil {
+		return l == nil && r == nil
+	}
+	if l.Mode != r.Mode ||
+		len(l.Ports) != len(r.Ports) ||
+		len(l.VirtualIPs) != len(r.VirtualIPs) {
+		return false
+	}
+	for i := range l.Ports {
+		if l.Ports[i] != r.Ports[i] {
+			return false
+		}
+	}
+	for i := range l.VirtualIPs {
+		if l.VirtualIPs[i] != r.VirtualIPs[i] {
+			return false
+		}
+	}
+	return true
 }
 
 // NodeMini is a optimized version of `swarm.Node` for caching purposes
@@ -52,6 +99,25 @@ func (ns NodeMini) Equal(other NodeMini) bool {
 		(ns.Availability == other.Availability)
 }
 
+// NetworkMini is a optimized version of `types.NetworkResource` for caching
+// purposes
+type NetworkMini struct {
+	ID     string
+	Name   string
+	Driver string
+	Scope  string
+	Labels map[string]string
+}
+
+// Equal returns true when NetworkMini is equal to `other`
+func (nm NetworkMini) Equal(other NetworkMini) bool {
+	return (nm.ID == other.ID) &&
+		(nm.Name == other.Name) &&
+		(nm.Driver == other.Driver) &&
+		(nm.Scope == other.Scope) &&
+		EqualMapStringString(nm.Labels, other.Labels)
+}
+
 // EqualMapStringString Returns true when the two maps are equal
 func EqualMapStringString(l map[string]string, r map[string]string) bool {
 	if len(l) != len(r) {
@@ -80,6 +146,37 @@ const (
 	EventTypeCreate EventType = "create"
 	// EventTypeRemove is for remove events
 	EventTypeRemove EventType = "remove"
+	// EventTypeTaskStarted is for tasks that started running
+	EventTypeTaskStarted EventType = "task-started"
+	// EventTypeTaskFailed is for tasks that failed or were rejected
+	EventTypeTaskFailed EventType = "task-failed"
+	// EventTypeTaskMoved is for tasks that replaced a running task on
+	// another node
+	EventTypeTaskMoved EventType = "task-moved"
+	// EventTypeUpdateStarted is for service updates that started
+	EventTypeUpdateStarted EventType = "update-started"
+	// EventTypeUpdatePaused is for service updates that paused
+	EventTypeUpdatePaused EventType = "update-paused"
+	// EventTypeUpdateCompleted is for service updates that completed
+	EventTypeUpdateCompleted EventType = "update-completed"
+	// EventTypeUpdateRollbackStarted is for service rollbacks that started
+	EventTypeUpdateRollbackStarted EventType = "update-rollback-started"
+	// EventTypeUpdateRollbackPaused is for service rollbacks that paused
+	EventTypeUpdateRollbackPaused EventType = "update-rollback-paused"
+	// EventTypeUpdateRollbackCompleted is for service rollbacks that
+	// completed
+	EventTypeUpdateRollbackCompleted EventType = "update-rollback-completed"
+	// EventTypeConvergenceFailed is for services that did not converge
+	// within their convergence timeout
+	EventTypeConvergenceFailed EventType = "convergence-failed"
+	// EventTypeServiceScaled is the `swarmListener.event` parameter of
+	// updated services where only the number of replicas changed. Their
+	// notifications are create events
+	EventTypeServiceScaled EventType = "service-scaled"
+	// EventTypeStackDeployed is for stacks whose services all converged
+	EventTypeStackDeployed EventType = "stack-deployed"
+	// EventTypeStackRemoved is for stacks whose last service was removed
+	EventTypeStackRemoved EventType = "stack-removed"
 )
 
 // Event contains information about docker events
@@ -132,6 +229,16 @@ func (ns NodeIPSet) Cardinality() int {
 	return len(ns)
 }
 
+// HasNodeID returns true when the set contains an address on node `id`
+func (ns NodeIPSet) HasNodeID(id string) bool {
+	for ip := range ns {
+		if ip.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
 // MarshalJSON creates JSON array from NodeIPSet
 func (ns NodeIPSet) MarshalJSON() ([]byte, error) {
 	items := make([][]string, 0, ns.Cardinality())
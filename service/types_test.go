@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/docker/docker/api/types/swarm"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -50,6 +51,45 @@ func (s *TypesTestSuite) Test_EqualMapStringString_DifferentNumberOfValues() {
 	s.False(EqualMapStringString(b, a))
 }
 
+func (s *TypesTestSuite) Test_EqualEndpointMini() {
+	endpoint := func() *EndpointMini {
+		return &EndpointMini{
+			Mode: swarm.ResolutionModeVIP,
+			Ports: []PortMini{
+				{Protocol: swarm.PortConfigProtocolTCP, TargetPort: 80, PublishedPort: 8080},
+			},
+			VirtualIPs: []VirtualIPMini{{NetworkID: "network1", Addr: "10.0.0.5/24"}},
+		}
+	}
+	s.True(EqualEndpointMini(nil, nil))
+	s.False(EqualEndpointMini(endpoint(), nil))
+	s.False(EqualEndpointMini(nil, endpoint()))
+	s.True(EqualEndpointMini(endpoint(), endpoint()))
+
+	modeChanged := endpoint()
+	modeChanged.Mode = swarm.ResolutionModeDNSRR
+	s.False(EqualEndpointMini(endpoint(), modeChanged))
+
+	portChanged := endpoint()
+	portChanged.Ports[0].PublishedPort = 8081
+	s.False(EqualEndpointMini(endpoint(), portChanged))
+
+	vipAdded := endpoint()
+	vipAdded.VirtualIPs = append(vipAdded.VirtualIPs,
+		VirtualIPMini{NetworkID: "network2", Addr: "10.0.1.5/24"})
+	s.False(EqualEndpointMini(endpoint(), vipAdded))
+}
+
+func (s *TypesTestSuite) Test_SwarmServiceMiniEqual_EndpointChanged() {
+	ssm := SwarmServiceMini{ID: "serviceID", Name: "demo",
+		Endpoint: &EndpointMini{Mode: swarm.ResolutionModeVIP}}
+	other := ssm
+	s.True(ssm.Equal(other))
+
+	other.Endpoint = &EndpointMini{Mode: swarm.ResolutionModeDNSRR}
+	s.False(ssm.Equal(other))
+}
+
 func (s *TypesTestSuite) Test_Cardinality_DifferentElms() {
 	a := NodeIPSet{}
 	a.Add("node-1", "1.0.0.1", "id1")
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const unixSocketScheme = "unix://"
+
+// splitUnixSocketAddr splits a `unix://<socket path>:<request path>`
+// address into the socket path and the http address of the request.
+// `ok` is false when `addr` is not a unix socket address
+func splitUnixSocketAddr(addr string) (socketPath string, httpAddr string, ok bool) {
+	if !strings.HasPrefix(addr, unixSocketScheme) {
+		return "", addr, false
+	}
+	socketPath = strings.TrimPrefix(addr, unixSocketScheme)
+	requestPath := "/"
+	if i := strings.Index(socketPath, ":"); i >= 0 {
+		socketPath, requestPath = socketPath[:i], socketPath[i+1:]
+	}
+	if !strings.HasPrefix(requestPath, "/") {
+		requestPath = "/" + requestPath
+	}
+	// The host is ignored by the socket dialer
+	return socketPath, "http://unix" + requestPath, true
+}
+
+// newNotificationClient returns the http client used to send
+// notifications to `addr`. Unix socket addresses get a client that dials
+// the socket
+func newNotificationClient(addr string) *http.Client {
+	socketPath, _, ok := splitUnixSocketAddr(addr)
+	if !ok {
+		return http.DefaultClient
+	}
+	dialer := net.Dialer{}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type UnixSocketTestSuite struct {
+	suite.Suite
+}
+
+func TestUnixSocketUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnixSocketTestSuite))
+}
+
+func (s *UnixSocketTestSuite) Test_SplitUnixSocketAddr_SplitsSocketAndPath() {
+	socketPath, httpAddr, ok := splitUnixSocketAddr("unix:///run/receiver.sock:/reconfigure?a=b")
+	s.True(ok)
+	s.Equal("/run/receiver.sock", socketPath)
+	s.Equal("http://unix/reconfigure?a=b", httpAddr)
+}
+
+func (s *UnixSocketTestSuite) Test_SplitUnixSocketAddr_NoPath_UsesRoot() {
+	socketPath, httpAddr, ok := splitUnixSocketAddr("unix:///run/receiver.sock")
+	s.True(ok)
+	s.Equal("/run/receiver.sock", socketPath)
+	s.Equal("http://unix/", httpAddr)
+}
+
+func (s *UnixSocketTestSuite) Test_SplitUnixSocketAddr_HTTPAddr_ReturnsAddr() {
+	socketPath, httpAddr, ok := splitUnixSocketAddr("http://proxy:8080/reconfigure")
+	s.False(ok)
+	s.Empty(socketPath)
+	s.Equal("http://proxy:8080/reconfigure", httpAddr)
+}
+
+func (s *UnixSocketTestSuite) Test_NewNotificationClient_HTTPAddr_ReturnsDefaultClient() {
+	s.Equal(http.DefaultClient, newNotificationClient("http://proxy:8080/reconfigure"))
+	s.NotEqual(http.DefaultClient, newNotificationClient("unix:///run/receiver.sock:/reconfigure"))
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+)
+
+var updateEventTypes = map[swarm.UpdateState]EventType{
+	swarm.UpdateStateUpdating:          EventTypeUpdateStarted,
+	swarm.UpdateStatePaused:            EventTypeUpdatePaused,
+	swarm.UpdateStateCompleted:         EventTypeUpdateCompleted,
+	swarm.UpdateStateRollbackStarted:   EventTypeUpdateRollbackStarted,
+	swarm.UpdateStateRollbackPaused:    EventTypeUpdateRollbackPaused,
+	swarm.UpdateStateRollbackCompleted: EventTypeUpdateRollbackCompleted,
+}
+
+// updateSnapshot is the update status of a service observed by
+// `UpdateTracker`
+type updateSnapshot struct {
+	State     swarm.UpdateState
+	StartedAt time.Time
+}
+
+// UpdateTracker reports transitions of the update status of services
+// `GetTaskList` polls services until they converge, which walks the update
+// status through updating, paused, and rollback states. The tracker is
+// called with every polled service and places a notification on its
+// channel when the update state changed
+type UpdateTracker struct {
+	notiChan chan<- Notification
+	states   map[string]updateSnapshot
+	mux      sync.RWMutex
+}
+
+// NewUpdateTracker creates an `UpdateTracker`
+func NewUpdateTracker() *UpdateTracker {
+	return &UpdateTracker{
+		states: map[string]updateSnapshot{},
+	}
+}
+
+// Run places update notifications on `notiChan`
+// Transitions are only recorded before `Run` is called
+func (t *UpdateTracker) Run(notiChan chan<- Notification) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.notiChan = notiChan
+}
+
+// Observe records the update status of `service` and notifies transitions
+// The first observation of a service records its status without
+// notifying it, so that past updates are not reported on startup
+func (t *UpdateTracker) Observe(service swarm.Service) {
+	n, ok := t.transition(service)
+	if !ok {
+		return
+	}
+	t.mux.RLock()
+	notiChan := t.notiChan
+	t.mux.RUnlock()
+	if notiChan != nil {
+		notiChan <- n
+	}
+}
+
+// Forget removes the recorded update status of service `serviceID`
+func (t *UpdateTracker) Forget(serviceID string) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	delete(t.states, serviceID)
+}
+
+func (t *UpdateTracker) transition(service swarm.Service) (Notification, bool) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	current := updateSnapshot{}
+	if service.UpdateStatus != nil {
+		current.State = service.UpdateStatus.State
+		if service.UpdateStatus.StartedAt != nil {
+			current.StartedAt = *service.UpdateStatus.StartedAt
+		}
+	}
+	previous, ok := t.states[service.ID]
+	t.states[service.ID] = current
+	if !ok || current == previous {
+		return Notification{}, false
+	}
+
+	eventType, ok := updateEventTypes[current.State]
+	if !ok {
+		return Notification{}, false
+	}
+	params := getUpdateParameters(service)
+	params["event"] = string(eventType)
+	ssm := MinifySwarmService(SwarmService{service, nil}, "", "")
+	return Notification{
+		EventType:  eventType,
+		ID:         service.ID,
+		Parameters: ConvertMapStringStringToURLValues(params).Encode(),
+		TimeNano:   time.Now().UTC().UnixNano(),
+		Service:    &ssm,
+	}, true
+}
+
+func getUpdateParameters(service swarm.Service) map[string]string {
+	status := service.UpdateStatus
+	params := map[string]string{
+		"serviceID":   service.ID,
+		"serviceName": service.Spec.Name,
+		"state":       string(status.State),
+	}
+	if len(status.Message) > 0 {
+		params["message"] = status.Message
+	}
+	if status.StartedAt != nil && !status.StartedAt.IsZero() {
+		params["startedAt"] = status.StartedAt.UTC().Format(time.RFC3339)
+		if status.CompletedAt != nil && !status.CompletedAt.IsZero() {
+			params["completedAt"] = status.CompletedAt.UTC().Format(time.RFC3339)
+			duration := status.CompletedAt.Sub(*status.StartedAt)
+			params["duration"] = strconv.FormatFloat(duration.Seconds(), 'f', -1, 64)
+		}
+	}
+	return params
+}
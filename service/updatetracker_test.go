@@ -0,0 +1,125 @@
+package service
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/suite"
+)
+
+type UpdateTrackerTestSuite struct {
+	suite.Suite
+	Tracker  *UpdateTracker
+	NotiChan chan Notification
+	Started  time.Time
+}
+
+func TestUpdateTrackerUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UpdateTrackerTestSuite))
+}
+
+func (s *UpdateTrackerTestSuite) SetupTest() {
+	s.Tracker = NewUpdateTracker()
+	s.NotiChan = make(chan Notification, 10)
+	s.Tracker.Run(s.NotiChan)
+	s.Started = time.Date(2018, 2, 12, 10, 0, 0, 0, time.UTC)
+}
+
+func (s *UpdateTrackerTestSuite) Test_Observe_FirstObservation_IsSilent() {
+	s.Tracker.Observe(newUpdatingService(swarm.UpdateStateUpdating, s.Started, nil, ""))
+	s.Empty(s.NotiChan)
+}
+
+func (s *UpdateTrackerTestSuite) Test_Observe_UpdateStarted() {
+	s.Tracker.Observe(newUpdatingService("", time.Time{}, nil, ""))
+	s.Tracker.Observe(newUpdatingService(swarm.UpdateStateUpdating, s.Started, nil, "update in progress"))
+
+	s.Require().Len(s.NotiChan, 1)
+	n := <-s.NotiChan
+	s.Equal(EventTypeUpdateStarted, n.EventType)
+	s.Equal("serviceID1", n.ID)
+	s.NotZero(n.TimeNano)
+	s.Require().NotNil(n.Service)
+	s.Equal("demo_go", n.Service.Name)
+
+	params, err := url.ParseQuery(n.Parameters)
+	s.Require().NoError(err)
+	s.Equal("update-started", params.Get("event"))
+	s.Equal("serviceID1", params.Get("serviceID"))
+	s.Equal("demo_go", params.Get("serviceName"))
+	s.Equal("updating", params.Get("state"))
+	s.Equal("update in progress", params.Get("message"))
+	s.Equal("2018-02-12T10:00:00Z", params.Get("startedAt"))
+	s.Empty(params.Get("completedAt"))
+}
+
+func (s *UpdateTrackerTestSuite) Test_Observe_SameState_IsSilent() {
+	s.Tracker.Observe(newUpdatingService("", time.Time{}, nil, ""))
+	s.Tracker.Observe(newUpdatingService(swarm.UpdateStateUpdating, s.Started, nil, ""))
+	s.Tracker.Observe(newUpdatingService(swarm.UpdateStateUpdating, s.Started, nil, ""))
+	s.Len(s.NotiChan, 1)
+}
+
+func (s *UpdateTrackerTestSuite) Test_Observe_PausedAndRolledBack() {
+	completed := s.Started.Add(90 * time.Second)
+	s.Tracker.Observe(newUpdatingService(swarm.UpdateStateUpdating, s.Started, nil, ""))
+	s.Tracker.Observe(newUpdatingService(swarm.UpdateStatePaused, s.Started, nil, "update paused due to failure"))
+	s.Tracker.Observe(newUpdatingService(swarm.UpdateStateRollbackStarted, s.Started, nil, ""))
+	s.Tracker.Observe(newUpdatingService(swarm.UpdateStateRollbackCompleted, s.Started, &completed, "rollback completed"))
+
+	s.Require().Len(s.NotiChan, 3)
+	s.Equal(EventTypeUpdatePaused, (<-s.NotiChan).EventType)
+	s.Equal(EventTypeUpdateRollbackStarted, (<-s.NotiChan).EventType)
+
+	n := <-s.NotiChan
+	s.Equal(EventTypeUpdateRollbackCompleted, n.EventType)
+	params, err := url.ParseQuery(n.Parameters)
+	s.Require().NoError(err)
+	s.Equal("rollback_completed", params.Get("state"))
+	s.Equal("rollback completed", params.Get("message"))
+	s.Equal("2018-02-12T10:01:30Z", params.Get("completedAt"))
+	s.Equal("90", params.Get("duration"))
+}
+
+func (s *UpdateTrackerTestSuite) Test_Observe_NewUpdateWithSameState_Notifies() {
+	completed := s.Started.Add(time.Minute)
+	s.Tracker.Observe(newUpdatingService(swarm.UpdateStateCompleted, s.Started, &completed, ""))
+
+	started := completed.Add(time.Hour)
+	s.Tracker.Observe(newUpdatingService(swarm.UpdateStateCompleted, started, &started, ""))
+
+	s.Require().Len(s.NotiChan, 1)
+	s.Equal(EventTypeUpdateCompleted, (<-s.NotiChan).EventType)
+}
+
+func (s *UpdateTrackerTestSuite) Test_Forget_ResetsService() {
+	s.Tracker.Observe(newUpdatingService("", time.Time{}, nil, ""))
+	s.Tracker.Forget("serviceID1")
+	s.Tracker.Observe(newUpdatingService(swarm.UpdateStateUpdating, s.Started, nil, ""))
+	s.Empty(s.NotiChan)
+}
+
+func (s *UpdateTrackerTestSuite) Test_Observe_NotRunning_RecordsOnly() {
+	tracker := NewUpdateTracker()
+	tracker.Observe(newUpdatingService("", time.Time{}, nil, ""))
+	tracker.Observe(newUpdatingService(swarm.UpdateStateUpdating, s.Started, nil, ""))
+	s.Equal(swarm.UpdateStateUpdating, tracker.states["serviceID1"].State)
+}
+
+func newUpdatingService(state swarm.UpdateState, startedAt time.Time, completedAt *time.Time, message string) swarm.Service {
+	service := swarm.Service{
+		ID:   "serviceID1",
+		Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "demo_go"}},
+	}
+	if len(state) > 0 {
+		service.UpdateStatus = &swarm.UpdateStatus{
+			State:       state,
+			StartedAt:   &startedAt,
+			CompletedAt: completedAt,
+			Message:     message,
+		}
+	}
+	return service
+}
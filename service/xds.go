@@ -0,0 +1,411 @@
+package service
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	router "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/router/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	clusterservice "github.com/envoyproxy/go-control-plane/envoy/service/cluster/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	endpointservice "github.com/envoyproxy/go-control-plane/envoy/service/endpoint/v3"
+	listenerservice "github.com/envoyproxy/go-control-plane/envoy/service/listener/v3"
+	routeservice "github.com/envoyproxy/go-control-plane/envoy/service/route/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	cache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	xds "github.com/envoyproxy/go-control-plane/pkg/server/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
+)
+
+const (
+	xdsSnapshotNode   = "swarm"
+	xdsRouteName      = "swarm"
+	xdsListenerName   = "swarm_http"
+	xdsConnectTimeout = 5 * time.Second
+)
+
+// xdsNodeHash serves the same snapshot to every Envoy node
+type xdsNodeHash struct{}
+
+func (xdsNodeHash) ID(node *core.Node) string {
+	return xdsSnapshotNode
+}
+
+// XDSServer serves Envoy v3 xDS (CDS/EDS/LDS/RDS) over gRPC from cached
+// services
+// Every service with the `com.df.port` label becomes a cluster with the task
+// addresses of the service as endpoints. Services without task addresses
+// become DNS clusters that resolve the name of the service to its virtual
+// IP. Services with the `com.df.servicePath` label are routed from a single
+// HTTP listener, where `com.df.serviceDomain` selects the virtual host. A new snapshot version is
+// created after every cache change. It implements `NotificationSender` so
+// that it can be placed on a `NotifyEndpoint`
+type XDSServer struct {
+	Addr         string
+	ListenerPort uint32
+	ssCache      SwarmServiceCacher
+	cache        cache.SnapshotCache
+	version      uint64
+	mux          sync.Mutex
+	log          *log.Logger
+}
+
+// NewXDSServer creates a `XDSServer`
+func NewXDSServer(addr string, listenerPort uint32, ssCache SwarmServiceCacher, logger *log.Logger) *XDSServer {
+	return &XDSServer{
+		Addr:         addr,
+		ListenerPort: listenerPort,
+		ssCache:      ssCache,
+		cache:        cache.NewSnapshotCache(true, xdsNodeHash{}, nil),
+		log:          logger,
+	}
+}
+
+// xdsConfigFromEnv returns the address of the xDS server and the port
+// Envoy listens on. The port defaults to 80
+func xdsConfigFromEnv() (addr string, listenerPort uint32) {
+	listenerPort = 80
+	if portStr := os.Getenv("DF_XDS_LISTENER_PORT"); len(portStr) > 0 {
+		if port, err := strconv.ParseUint(portStr, 10, 16); err == nil {
+			listenerPort = uint32(port)
+		}
+	}
+	return os.Getenv("DF_XDS_ADDR"), listenerPort
+}
+
+// Run sets the initial snapshot and serves xDS on `Addr`
+// An error is returned when `Addr` can not be listened on
+func (s *XDSServer) Run() error {
+	if err := s.Update(); err != nil {
+		return err
+	}
+	lis, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	grpcServer := grpc.NewServer()
+	server := xds.NewServer(context.Background(), s.cache, nil)
+	discovery.RegisterAggregatedDiscoveryServiceServer(grpcServer, server)
+	endpointservice.RegisterEndpointDiscoveryServiceServer(grpcServer, server)
+	clusterservice.RegisterClusterDiscoveryServiceServer(grpcServer, server)
+	routeservice.RegisterRouteDiscoveryServiceServer(grpcServer, server)
+	listenerservice.RegisterListenerDiscoveryServiceServer(grpcServer, server)
+
+	s.log.Printf("Serving xDS on %s", lis.Addr())
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			s.log.Printf("ERROR: xDS server stopped: %v", err)
+			metrics.RecordError("xdsServe")
+		}
+	}()
+	return nil
+}
+
+// Create updates the snapshot
+func (s *XDSServer) Create(ctx context.Context, params string) error {
+	return s.Update()
+}
+
+// Remove updates the snapshot
+func (s *XDSServer) Remove(ctx context.Context, params string) error {
+	return s.Update()
+}
+
+// GetCreateAddr returns the xDS address
+func (s *XDSServer) GetCreateAddr() string {
+	return s.Addr
+}
+
+// GetRemoveAddr returns the xDS address
+func (s *XDSServer) GetRemoveAddr() string {
+	return s.Addr
+}
+
+// Update creates a new snapshot version from the cached services
+func (s *XDSServer) Update() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.version++
+	snapshot, err := getXDSSnapshot(
+		strconv.FormatUint(s.version, 10), s.ListenerPort, s.ssCache.GetAll())
+	if err != nil {
+		metrics.RecordError("xdsSnapshot")
+		return err
+	}
+	if err := s.cache.SetSnapshot(context.Background(), xdsSnapshotNode, snapshot); err != nil {
+		metrics.RecordError("xdsSnapshot")
+		return err
+	}
+	return nil
+}
+
+// xdsService is a service exposed through xDS
+// `name` is the full name of the service, which is unique in the swarm.
+// It names the cluster and is resolved by Envoy when the service does not
+// have `addrs`
+type xdsService struct {
+	name    string
+	port    uint32
+	addrs   []string
+	paths   []string
+	domains []string
+}
+
+func getXDSServices(services []SwarmServiceMini) []xdsService {
+	xdsServices := []xdsService{}
+	for _, ssm := range services {
+		port, err := strconv.ParseUint(ssm.Labels["com.df.port"], 10, 16)
+		if err != nil {
+			continue
+		}
+		xs := xdsService{
+			name:    ssm.Name,
+			port:    uint32(port),
+			addrs:   []string{},
+			paths:   splitLabel(ssm.Labels["com.df.servicePath"]),
+			domains: splitLabel(ssm.Labels["com.df.serviceDomain"]),
+		}
+		for nodeIP := range ssm.NodeInfo {
+			xs.addrs = append(xs.addrs, nodeIP.Addr)
+		}
+		sort.Strings(xs.addrs)
+		xdsServices = append(xdsServices, xs)
+	}
+	sort.Slice(xdsServices, func(i, j int) bool {
+		return xdsServices[i].name < xdsServices[j].name
+	})
+	return xdsServices
+}
+
+func getXDSSnapshot(version string, listenerPort uint32, services []SwarmServiceMini) (*cache.Snapshot, error) {
+	xdsServices := getXDSServices(services)
+
+	clusters := []types.Resource{}
+	endpoints := []types.Resource{}
+	for _, xs := range xdsServices {
+		clusters = append(clusters, makeXDSCluster(xs))
+		if len(xs.addrs) > 0 {
+			endpoints = append(endpoints, makeXDSEndpoints(xs))
+		}
+	}
+
+	listener, err := makeXDSListener(listenerPort)
+	if err != nil {
+		return nil, err
+	}
+	return cache.NewSnapshot(version, map[resource.Type][]types.Resource{
+		resource.EndpointType: endpoints,
+		resource.ClusterType:  clusters,
+		resource.RouteType:    {makeXDSRoute(xdsServices)},
+		resource.ListenerType: {listener},
+	})
+}
+
+func makeXDSAddress(addr string, port uint32) *core.Address {
+	return &core.Address{
+		Address: &core.Address_SocketAddress{
+			SocketAddress: &core.SocketAddress{
+				Protocol: core.SocketAddress_TCP,
+				Address:  addr,
+				PortSpecifier: &core.SocketAddress_PortValue{
+					PortValue: port,
+				},
+			},
+		},
+	}
+}
+
+// makeXDSCluster creates an EDS cluster for services with task addresses
+// Other services are resolved by their name, which docker resolves to the
+// virtual IP of the service
+func makeXDSCluster(xs xdsService) *cluster.Cluster {
+	c := &cluster.Cluster{
+		Name:           xs.name,
+		ConnectTimeout: durationpb.New(xdsConnectTimeout),
+	}
+	if len(xs.addrs) == 0 {
+		c.ClusterDiscoveryType = &cluster.Cluster_Type{Type: cluster.Cluster_STRICT_DNS}
+		c.LoadAssignment = makeXDSLoadAssignment(xs.name, []string{xs.name}, xs.port)
+		return c
+	}
+	c.ClusterDiscoveryType = &cluster.Cluster_Type{Type: cluster.Cluster_EDS}
+	c.EdsClusterConfig = &cluster.Cluster_EdsClusterConfig{
+		EdsConfig: &core.ConfigSource{
+			ResourceApiVersion: core.ApiVersion_V3,
+			ConfigSourceSpecifier: &core.ConfigSource_Ads{
+				Ads: &core.AggregatedConfigSource{},
+			},
+		},
+	}
+	return c
+}
+
+func makeXDSEndpoints(xs xdsService) *endpoint.ClusterLoadAssignment {
+	return makeXDSLoadAssignment(xs.name, xs.addrs, xs.port)
+}
+
+func makeXDSLoadAssignment(clusterName string, addrs []string, port uint32) *endpoint.ClusterLoadAssignment {
+	lbEndpoints := []*endpoint.LbEndpoint{}
+	for _, addr := range addrs {
+		lbEndpoints = append(lbEndpoints, &endpoint.LbEndpoint{
+			HostIdentifier: &endpoint.LbEndpoint_Endpoint{
+				Endpoint: &endpoint.Endpoint{
+					Address: makeXDSAddress(addr, port),
+				},
+			},
+		})
+	}
+	return &endpoint.ClusterLoadAssignment{
+		ClusterName: clusterName,
+		Endpoints: []*endpoint.LocalityLbEndpoints{{
+			LbEndpoints: lbEndpoints,
+		}},
+	}
+}
+
+// makeXDSRoute creates the route configuration of the HTTP listener
+// Every domain of a service gets its own virtual host, since Envoy rejects
+// domains that appear in more than one virtual host. Services without
+// domains are placed in the `*` virtual host
+func makeXDSRoute(xdsServices []xdsService) *route.RouteConfiguration {
+	type xdsPath struct {
+		prefix  string
+		cluster string
+	}
+	pathsByDomain := map[string][]xdsPath{}
+	for _, xs := range xdsServices {
+		if len(xs.paths) == 0 {
+			continue
+		}
+		domains := []string{"*"}
+		if len(xs.domains) > 0 {
+			domains = xs.domains
+		}
+		seen := map[string]bool{}
+		for _, domain := range domains {
+			if seen[domain] {
+				continue
+			}
+			seen[domain] = true
+			for _, p := range xs.paths {
+				pathsByDomain[domain] = append(pathsByDomain[domain], xdsPath{prefix: p, cluster: xs.name})
+			}
+		}
+	}
+
+	domains := []string{}
+	for domain := range pathsByDomain {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+
+	virtualHosts := []*route.VirtualHost{}
+	for _, domain := range domains {
+		paths := pathsByDomain[domain]
+		// Longer prefixes are matched first
+		sort.SliceStable(paths, func(i, j int) bool {
+			return len(paths[i].prefix) > len(paths[j].prefix)
+		})
+		routes := []*route.Route{}
+		for _, p := range paths {
+			routes = append(routes, &route.Route{
+				Match: &route.RouteMatch{
+					PathSpecifier: &route.RouteMatch_Prefix{Prefix: p.prefix},
+				},
+				Action: &route.Route_Route{
+					Route: &route.RouteAction{
+						ClusterSpecifier: &route.RouteAction_Cluster{Cluster: p.cluster},
+					},
+				},
+			})
+		}
+		name := domain
+		if domain == "*" {
+			name = "default"
+		}
+		virtualHosts = append(virtualHosts, &route.VirtualHost{
+			Name:    name,
+			Domains: []string{domain},
+			Routes:  routes,
+		})
+	}
+
+	return &route.RouteConfiguration{
+		Name:         xdsRouteName,
+		VirtualHosts: virtualHosts,
+	}
+}
+
+func makeXDSListener(port uint32) (*listener.Listener, error) {
+	routerConfig, err := anypb.New(&router.Router{})
+	if err != nil {
+		return nil, err
+	}
+	manager := &hcm.HttpConnectionManager{
+		CodecType:  hcm.HttpConnectionManager_AUTO,
+		StatPrefix: "swarm",
+		RouteSpecifier: &hcm.HttpConnectionManager_Rds{
+			Rds: &hcm.Rds{
+				ConfigSource: &core.ConfigSource{
+					ResourceApiVersion: core.ApiVersion_V3,
+					ConfigSourceSpecifier: &core.ConfigSource_Ads{
+						Ads: &core.AggregatedConfigSource{},
+					},
+				},
+				RouteConfigName: xdsRouteName,
+			},
+		},
+		HttpFilters: []*hcm.HttpFilter{{
+			Name: wellknown.Router,
+			ConfigType: &hcm.HttpFilter_TypedConfig{
+				TypedConfig: routerConfig,
+			},
+		}},
+	}
+	config, err := anypb.New(manager)
+	if err != nil {
+		return nil, err
+	}
+	return &listener.Listener{
+		Name:    xdsListenerName,
+		Address: makeXDSAddress("0.0.0.0", port),
+		FilterChains: []*listener.FilterChain{{
+			Filters: []*listener.Filter{{
+				Name: wellknown.HTTPConnectionManager,
+				ConfigType: &listener.Filter_TypedConfig{
+					TypedConfig: config,
+				},
+			}},
+		}},
+	}, nil
+}
+
+// splitLabel splits a comma separated label value
+func splitLabel(value string) []string {
+	values := []string{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			values = append(values, v)
+		}
+	}
+	return values
+}
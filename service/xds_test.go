@@ -0,0 +1,175 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"testing"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/stretchr/testify/suite"
+)
+
+type XDSServerTestSuite struct {
+	suite.Suite
+	SSCache  *SwarmServiceCache
+	Server   *XDSServer
+	Logger   *log.Logger
+	LogBytes *bytes.Buffer
+}
+
+func TestXDSServerUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(XDSServerTestSuite))
+}
+
+func (s *XDSServerTestSuite) SetupTest() {
+	s.SSCache = NewSwarmServiceCache()
+	s.LogBytes = new(bytes.Buffer)
+	s.Logger = log.New(s.LogBytes, "", 0)
+	s.Server = NewXDSServer("127.0.0.1:0", 8080, s.SSCache, s.Logger)
+}
+
+func (s *XDSServerTestSuite) Test_GetXDSSnapshot_CreatesResources() {
+	ssm := getNewSwarmServiceMini()
+	ssm.Labels["com.df.port"] = "8080"
+	ssm.Labels["com.df.servicePath"] = "/demo,/demo/api"
+	ssm.NodeInfo.Add("node-2", "1.0.0.2", "id2")
+	noPort := getNewSwarmServiceMini()
+	noPort.ID = "noPortID"
+	noPort.Name = "no-port"
+
+	snapshot, err := getXDSSnapshot("1", 8080, []SwarmServiceMini{ssm, noPort})
+	s.Require().NoError(err)
+	s.NoError(snapshot.Consistent())
+
+	clusters := snapshot.GetResources(resource.ClusterType)
+	s.Require().Len(clusters, 1)
+	s.Contains(clusters, "demo-go")
+	s.Equal(cluster.Cluster_EDS, clusters["demo-go"].(*cluster.Cluster).GetType())
+
+	endpoints := snapshot.GetResources(resource.EndpointType)
+	s.Require().Len(endpoints, 1)
+	cla := endpoints["demo-go"].(*endpoint.ClusterLoadAssignment)
+	s.Require().Len(cla.Endpoints, 1)
+	lbEndpoints := cla.Endpoints[0].LbEndpoints
+	s.Require().Len(lbEndpoints, 2)
+	addr := lbEndpoints[0].GetEndpoint().Address.GetSocketAddress()
+	s.Equal("1.0.0.1", addr.Address)
+	s.Equal(uint32(8080), addr.GetPortValue())
+
+	routes := snapshot.GetResources(resource.RouteType)
+	s.Require().Len(routes, 1)
+	routeConfig := routes["swarm"].(*route.RouteConfiguration)
+	s.Require().Len(routeConfig.VirtualHosts, 1)
+	s.Equal([]string{"*"}, routeConfig.VirtualHosts[0].Domains)
+	s.Require().Len(routeConfig.VirtualHosts[0].Routes, 2)
+	s.Equal("/demo/api", routeConfig.VirtualHosts[0].Routes[0].Match.GetPrefix())
+	s.Equal("demo-go", routeConfig.VirtualHosts[0].Routes[0].GetRoute().GetCluster())
+
+	listeners := snapshot.GetResources(resource.ListenerType)
+	s.Require().Len(listeners, 1)
+	l := listeners["swarm_http"].(*listener.Listener)
+	s.Equal(uint32(8080), l.Address.GetSocketAddress().GetPortValue())
+}
+
+func (s *XDSServerTestSuite) Test_GetXDSSnapshot_ResolvesServicesWithoutNodeInfoByName() {
+	ssm := getNewSwarmServiceMini()
+	ssm.Labels["com.df.port"] = "8080"
+	ssm.NodeInfo = nil
+
+	snapshot, err := getXDSSnapshot("1", 8080, []SwarmServiceMini{ssm})
+	s.Require().NoError(err)
+	s.NoError(snapshot.Consistent())
+
+	s.Empty(snapshot.GetResources(resource.EndpointType))
+	clusters := snapshot.GetResources(resource.ClusterType)
+	s.Require().Len(clusters, 1)
+	c := clusters["demo-go"].(*cluster.Cluster)
+	s.Equal(cluster.Cluster_STRICT_DNS, c.GetType())
+	lbEndpoints := c.LoadAssignment.Endpoints[0].LbEndpoints
+	s.Require().Len(lbEndpoints, 1)
+	addr := lbEndpoints[0].GetEndpoint().Address.GetSocketAddress()
+	s.Equal(ssm.Name, addr.Address)
+	s.Equal(uint32(8080), addr.GetPortValue())
+}
+
+func (s *XDSServerTestSuite) Test_GetXDSSnapshot_NamesClustersAfterFullServiceNames() {
+	services := []SwarmServiceMini{}
+	for _, stack := range []string{"stackA", "stackB"} {
+		ssm := getNewSwarmServiceMini()
+		ssm.ID = stack + "ID"
+		ssm.Name = stack + "_api"
+		ssm.Labels["com.df.port"] = "8080"
+		ssm.Labels["com.df.servicePath"] = "/" + stack
+		ssm.Labels["com.df.shortName"] = "true"
+		ssm.Labels["com.docker.stack.namespace"] = stack
+		services = append(services, ssm)
+	}
+
+	snapshot, err := getXDSSnapshot("1", 8080, services)
+	s.Require().NoError(err)
+	s.NoError(snapshot.Consistent())
+
+	clusters := snapshot.GetResources(resource.ClusterType)
+	s.Require().Len(clusters, 2)
+	s.Contains(clusters, "stackA_api")
+	s.Contains(clusters, "stackB_api")
+	s.Len(snapshot.GetResources(resource.EndpointType), 2)
+}
+
+func (s *XDSServerTestSuite) Test_MakeXDSRoute_GroupsServicesByDomain() {
+	routeConfig := makeXDSRoute([]xdsService{
+		{name: "a", paths: []string{"/a"}, domains: []string{"a.com"}},
+		{name: "b", paths: []string{"/b"}, domains: []string{"a.com"}},
+		{name: "c", paths: []string{"/c"}},
+		{name: "d"},
+	})
+
+	s.Require().Len(routeConfig.VirtualHosts, 2)
+	s.Equal([]string{"*"}, routeConfig.VirtualHosts[0].Domains)
+	s.Len(routeConfig.VirtualHosts[0].Routes, 1)
+	s.Equal([]string{"a.com"}, routeConfig.VirtualHosts[1].Domains)
+	s.Len(routeConfig.VirtualHosts[1].Routes, 2)
+}
+
+func (s *XDSServerTestSuite) Test_MakeXDSRoute_OverlappingDomains_CreatesVirtualHostPerDomain() {
+	routeConfig := makeXDSRoute([]xdsService{
+		{name: "a", paths: []string{"/a"}, domains: []string{"a.com"}},
+		{name: "b", paths: []string{"/b"}, domains: []string{"a.com", "b.com"}},
+	})
+
+	s.Require().Len(routeConfig.VirtualHosts, 2)
+	s.Equal("a.com", routeConfig.VirtualHosts[0].Name)
+	s.Equal([]string{"a.com"}, routeConfig.VirtualHosts[0].Domains)
+	s.Require().Len(routeConfig.VirtualHosts[0].Routes, 2)
+	s.Equal("a", routeConfig.VirtualHosts[0].Routes[0].GetRoute().GetCluster())
+	s.Equal("b", routeConfig.VirtualHosts[0].Routes[1].GetRoute().GetCluster())
+	s.Equal("b.com", routeConfig.VirtualHosts[1].Name)
+	s.Equal([]string{"b.com"}, routeConfig.VirtualHosts[1].Domains)
+	s.Require().Len(routeConfig.VirtualHosts[1].Routes, 1)
+	s.Equal("b", routeConfig.VirtualHosts[1].Routes[0].GetRoute().GetCluster())
+	s.NoError(routeConfig.Validate())
+}
+
+func (s *XDSServerTestSuite) Test_Create_IncrementsVersion() {
+	ssm := getNewSwarmServiceMini()
+	ssm.Labels["com.df.port"] = "8080"
+	s.SSCache.InsertAndCheck(ssm)
+
+	s.Require().NoError(s.Server.Create(context.Background(), ""))
+	snapshot, err := s.Server.cache.GetSnapshot(xdsSnapshotNode)
+	s.Require().NoError(err)
+	s.Equal("1", snapshot.GetVersion(resource.ClusterType))
+	s.Len(snapshot.GetResources(resource.ClusterType), 1)
+
+	s.SSCache.Delete(ssm.ID)
+	s.Require().NoError(s.Server.Remove(context.Background(), ""))
+	snapshot, err = s.Server.cache.GetSnapshot(xdsSnapshotNode)
+	s.Require().NoError(err)
+	s.Equal("2", snapshot.GetVersion(resource.ClusterType))
+	s.Empty(snapshot.GetResources(resource.ClusterType))
+}